@@ -1,5 +1,6 @@
 // API service entry point and lifecycle wiring.
 // This file boots the HTTP server and validates core dependencies.
+// Running "api migrate" applies database migrations and exits.
 package main
 
 import (
@@ -9,53 +10,107 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/t0gun/spacescale/internal/adapters/runtime/docker"
 	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/adapters/store/migrations"
+	"github.com/t0gun/spacescale/internal/config"
+	"github.com/t0gun/spacescale/internal/contracts"
 	"github.com/t0gun/spacescale/internal/http_api"
 	"github.com/t0gun/spacescale/internal/service"
 )
 
 // main starts the API server and waits for a shutdown signal.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate()
+		return
+	}
+
 	// Read runtime config from env with defaults so local dev works out of the box.
-	addr := env("ADDR", ":8080")
-	workerToken := env("WORKER_TOKEN", "")
-	baseDomain := env("BASE_DOMAIN", "example.com")
+	addr := config.Env("ADDR", ":8080")
+	workerToken := config.Env("WORKER_TOKEN", "")
+	baseDomain := config.Env("BASE_DOMAIN", "example.com")
 
-	// Database URL is required
-	databaseURL := env("DATABASE_URL", "")
-	if databaseURL == "" {
-		log.Fatal("DATABASE_URL is required")
-	}
+	// Use Postgres when DATABASE_URL is set, otherwise fall back to the in-memory store
+	// so local dev works without a database.
+	var st contracts.Store
+	databaseURL := config.Env("DATABASE_URL", "")
+	if databaseURL != "" {
+		// Open a pgx connection pool and verify the DB is reachable.
+		dbPool, err := openDB(context.Background(), databaseURL)
+		if err != nil {
+			log.Fatalf("database init: %v", err)
+		}
+		defer dbPool.Close()
 
-	// Open a pgx connection pool and verify the DB is reachable.
-	// We keep the pool open for future store integration.
-	dbPool, err := openDB(context.Background(), databaseURL)
-	if err != nil {
-		log.Fatalf("database init: %v", err)
+		// Migrate on startup by default; with MIGRATE_ON_START=0 the schema must already
+		// match this binary (run "api migrate" separately). Either way a newer schema is fatal.
+		if config.Env("MIGRATE_ON_START", "1") == "1" {
+			applied, err := migrations.Up(context.Background(), dbPool)
+			if err != nil {
+				log.Fatalf("database migrate: %v", err)
+			}
+			log.Printf("database migrations applied: %d", applied)
+		} else if err := migrations.Check(context.Background(), dbPool); err != nil {
+			log.Fatalf("database schema: %v", err)
+		}
+		st = store.NewPostgresStore(dbPool)
+	} else {
+		log.Printf("DATABASE_URL not set, using in-memory store")
+		st = store.NewMemoryStore()
 	}
-	defer dbPool.Close()
 
-	st := store.NewMemoryStore()
-	rt, err := docker.New(docker.WithEdge(
-		docker.EdgeConfig{
+	rt, err := docker.New(
+		docker.WithEdge(docker.EdgeConfig{
 			BaseDomain: baseDomain,
-			TraefikNet: env("TRAEFIK_NET", "traefik"),
-			Scheme:     env("TRAEFIK_ENTRYPOINT", "web"),
-			EnableTLS:  env("ENABLE_TLS", "") == "1",
+			TraefikNet: config.Env("TRAEFIK_NET", "traefik"),
+			Scheme:     config.Env("TRAEFIK_ENTRYPOINT", "web"),
+			EnableTLS:  config.Env("ENABLE_TLS", "") == "1",
 			// CertResolver optional later:
-			// CertResolver: env("CERT_RESOLVER", ""),
-		},
-	))
+			// CertResolver: config.Env("CERT_RESOLVER", ""),
+		}),
+		docker.WithMaintenanceImage(config.Env("MAINTENANCE_IMAGE", "nginx:alpine")),
+		docker.WithSettle(config.EnvDuration("DEPLOY_SETTLE", 3*time.Second)),
+	)
 	if err != nil {
 		log.Fatalf("docker runtime init: %v", err)
 	}
 
-	svc := service.NewAppServiceWithRuntime(st, rt)
+	svc := service.NewAppServiceWithRuntime(st, rt,
+		service.WithReaper(service.ReaperConfig{
+			Deadline: config.EnvDuration("REAPER_DEADLINE", 15*time.Minute),
+			Interval: config.EnvDuration("REAPER_INTERVAL", time.Minute),
+		}),
+		service.WithMaxAttempts(config.EnvInt("DEPLOY_MAX_ATTEMPTS", 3)),
+	)
+
+	// Recover deployments a previous process left BUILDING/DEPLOYING, then keep checking.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go svc.RunReaper(bgCtx)
+
+	// With DEPLOY_WORKERS set, deployments run here as they are queued instead of waiting
+	// for next:process calls. On shutdown they get DEPLOY_DRAIN_TIMEOUT to finish before
+	// they are interrupted and requeued.
+	var workers sync.WaitGroup
+	if n := config.EnvInt("DEPLOY_WORKERS", 0); n > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			log.Printf("running %d deployment workers", n)
+			svc.RunWorkers(bgCtx, service.WorkerPoolConfig{
+				Concurrency:  n,
+				IdleBackoff:  config.EnvDuration("DEPLOY_WORKER_IDLE_BACKOFF", 500*time.Millisecond),
+				MaxBackoff:   config.EnvDuration("DEPLOY_WORKER_MAX_BACKOFF", 10*time.Second),
+				DrainTimeout: config.EnvDuration("DEPLOY_DRAIN_TIMEOUT", 8*time.Second),
+			})
+		}()
+	}
 	api := http_api.NewServer(svc, workerToken)
 
 	// Configure the HTTP server with a read header timeout to avoid slowloris-style abuse.
@@ -64,6 +119,9 @@ func main() {
 		Handler:           api.Router(),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	// Shutdown waits for open requests, so end the event and log streams that would otherwise hold it
+	// until the deadline and eat into the workers' drain time.
+	srv.RegisterOnShutdown(api.CloseStreams)
 
 	// Start the server in a goroutine so main can wait for shutdown signals.
 	go func() {
@@ -87,18 +145,31 @@ func main() {
 	defer cancel()
 
 	log.Printf("shutting down...")
+	stopBackground()
 	// Shutdown stops accepting new connections, and it won't close active requests. we are using contexts to give active
 	// requests a deadline either completed or not it would shut down when deadline is met.
 	_ = srv.Shutdown(ctx)
+	// Workers stopped claiming when the background context ended; wait for their deploys to finish or be requeued.
+	workers.Wait()
 }
 
-// env returns an environment variable or a default value.
-func env(key, def string) string {
-	// Return the env var value if set, otherwise fall back to the default.
-	if v := os.Getenv(key); v != "" {
-		return v
+// runMigrate applies pending migrations against DATABASE_URL and exits.
+func runMigrate() {
+	databaseURL := config.Env("DATABASE_URL", "")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL is required for migrate")
+	}
+	dbPool, err := openDB(context.Background(), databaseURL)
+	if err != nil {
+		log.Fatalf("database init: %v", err)
+	}
+	defer dbPool.Close()
+
+	applied, err := migrations.Up(context.Background(), dbPool)
+	if err != nil {
+		log.Fatalf("database migrate: %v", err)
 	}
-	return def
+	log.Printf("database migrations applied: %d", applied)
 }
 
 // openDB opens a pgx pool and verifies it with a ping.
@@ -0,0 +1,56 @@
+// Deploy worker entry point and lifecycle wiring.
+// This file claims deployments from the API and runs them on the local docker daemon.
+// Stopping it lets in-flight deploys finish, then hands the rest back to the queue.
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/adapters/runtime/docker"
+	"github.com/t0gun/spacescale/internal/config"
+	"github.com/t0gun/spacescale/internal/worker"
+)
+
+// main starts the worker and runs until a shutdown signal.
+func main() {
+	// Read config from env with defaults that match a local API.
+	apiURL := config.Env("API_URL", "http://localhost:8080")
+	workerToken := config.Env("WORKER_TOKEN", "")
+	workerID := config.Env("WORKER_ID", config.DefaultWorkerID())
+	baseDomain := config.Env("BASE_DOMAIN", "example.com")
+
+	// The runtime settings must match the API's so apps are routed the same way wherever they run.
+	rt, err := docker.New(
+		docker.WithEdge(docker.EdgeConfig{
+			BaseDomain: baseDomain,
+			TraefikNet: config.Env("TRAEFIK_NET", "traefik"),
+			Scheme:     config.Env("TRAEFIK_ENTRYPOINT", "web"),
+			EnableTLS:  config.Env("ENABLE_TLS", "") == "1",
+		}),
+		docker.WithMaintenanceImage(config.Env("MAINTENANCE_IMAGE", "nginx:alpine")),
+		docker.WithSettle(config.EnvDuration("DEPLOY_SETTLE", 3*time.Second)),
+	)
+	if err != nil {
+		log.Fatalf("docker runtime init: %v", err)
+	}
+
+	runner := worker.NewRunner(worker.NewClient(apiURL, workerToken), rt, workerID, worker.Config{
+		Concurrency:       config.EnvInt("WORKER_CONCURRENCY", 1),
+		IdleBackoff:       config.EnvDuration("WORKER_IDLE_BACKOFF", 500*time.Millisecond),
+		MaxBackoff:        config.EnvDuration("WORKER_MAX_BACKOFF", 10*time.Second),
+		DrainTimeout:      config.EnvDuration("WORKER_DRAIN_TIMEOUT", 8*time.Second),
+		HeartbeatInterval: config.EnvDuration("WORKER_HEARTBEAT_INTERVAL", 0),
+	})
+
+	// Stop claiming on SIGINT (Ctrl+C) or SIGTERM (container/service stop).
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("worker %s claiming from %s (base_domain=%s)", workerID, apiURL, baseDomain)
+	runner.Run(ctx)
+	log.Printf("worker stopped")
+}
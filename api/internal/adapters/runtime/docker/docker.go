@@ -2,13 +2,20 @@
 // It pulls images, creates containers, and configures routing labels.
 // Ports come from app input or image metadata.
 // URLs are returned only when apps are exposed.
+// Deployed containers can be stopped, started, restarted and inspected.
+// Paused apps are stopped and their host is served a maintenance response.
+// Deploys are blue/green: the old container serves until the new one is ready.
+// Ready means healthy when the app or its image defines a health check.
 
 package docker
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,6 +25,7 @@ import (
 	"github.com/moby/moby/api/types/image"
 	"github.com/moby/moby/api/types/network"
 	"github.com/moby/moby/client"
+	"github.com/t0gun/spacescale/internal/contracts"
 	"github.com/t0gun/spacescale/internal/domain"
 )
 
@@ -27,6 +35,10 @@ type Runtime struct {
 	advertiseHost string
 	namePrefix    string
 	timeout       time.Duration
+	// image serving the maintenance response for paused apps
+	maintenanceImage string
+	// how long a new container without a health check must stay up before traffic switches to it
+	settle time.Duration
 
 	// edge routing config
 	edge EdgeConfig
@@ -43,6 +55,45 @@ type EdgeConfig struct {
 
 const errPortRequiredMsg = "port required or image must expose exactly one port"
 
+// errProbeToolsMsg explains what an image needs for the http and tcp health checks.
+const errProbeToolsMsg = "http and tcp checks need /bin/sh and wget, curl or nc in the image; use a command check or the image's HEALTHCHECK instead"
+
+// probeNotFound is the exit code of a health probe whose command is missing, as sh reports it.
+const probeNotFound = 127
+
+// Container labels that tie a container back to its app.
+const (
+	labelAppName = "spacescale.app"
+	labelAppID   = "spacescale.app.id"
+	labelRole    = "spacescale.role"
+)
+
+// Values of labelRole.
+const (
+	roleApp         = "app"
+	roleMaintenance = "maintenance"
+)
+
+// readyPoll is how often a starting container is inspected while waiting for it to be ready.
+const readyPoll = 250 * time.Millisecond
+
+// Bounds on the container logs attached to a failed deploy.
+const (
+	failureLogLines = 50
+	failureLogBytes = 4 << 10
+)
+
+// maintenanceConf is the nginx config that answers every request for a paused app with 503.
+const maintenanceConf = `server {
+    listen 80 default_server;
+    default_type text/plain;
+    location / {
+        add_header Retry-After 120 always;
+        return 503 "app is paused\n";
+    }
+}
+`
+
 // Option configures Runtime construction.
 type Option func(*Runtime)
 
@@ -55,6 +106,12 @@ func WithNamePrefix(prefix string) Option { return func(r *Runtime) { r.namePref
 // WithTimeout sets the deploy timeout.
 func WithTimeout(d time.Duration) Option { return func(r *Runtime) { r.timeout = d } }
 
+// WithMaintenanceImage sets the nginx image that serves paused apps.
+func WithMaintenanceImage(ref string) Option { return func(r *Runtime) { r.maintenanceImage = ref } }
+
+// WithSettle sets how long a new container without a health check must stay running before it takes over.
+func WithSettle(d time.Duration) Option { return func(r *Runtime) { r.settle = d } }
+
 // WithEdge overrides edge routing settings.
 func WithEdge(cfg EdgeConfig) Option { return func(r *Runtime) { r.edge = cfg } }
 
@@ -73,6 +130,9 @@ func New(opts ...Option) (*Runtime, error) {
 		advertiseHost: "127.0.0.1",
 		namePrefix:    "sample-app-",
 		timeout:       2 * time.Minute,
+		// maintenance and blue/green defaults
+		maintenanceImage: "nginx:alpine",
+		settle:           3 * time.Second,
 		edge: EdgeConfig{
 			BaseDomain: "localtest.me",
 			TraefikNet: "traefik",
@@ -83,12 +143,25 @@ func New(opts ...Option) (*Runtime, error) {
 	for _, opt := range opts {
 		opt(r)
 	}
+	// Deploys run concurrently, so the edge config is settled here and only read afterwards
+	if strings.TrimSpace(r.edge.Scheme) == "" {
+		// default Traefik entrypoint name
+		r.edge.Scheme = "web"
+	}
 	return r, nil
 }
 
-// Deploy pulls the image, creates the container, and returns a URL when exposed.
-func (r *Runtime) Deploy(ctx context.Context, app domain.App) (*string, error) {
-	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+// Deploy pulls the image, starts a new container beside the current one, and returns a URL when exposed.
+// Traffic moves to the new container only once it is ready; if it never gets there it is removed
+// and the current container keeps serving.
+// A configured health check replaces the image's HEALTHCHECK; failing it attaches the container logs to the error.
+// Each phase is reported to progress as one of the standard deploy steps.
+func (r *Runtime) Deploy(ctx context.Context, app domain.App, progress contracts.DeployProgress) (*string, error) {
+	if progress == nil {
+		progress = contracts.NoProgress{}
+	}
+	// a slow health check gets its full budget on top of the usual timeout
+	ctx, cancel := context.WithTimeout(ctx, r.timeout+healthBudget(app.HealthCheck))
 	defer cancel()
 	// validate input
 	if strings.TrimSpace(app.Image) == "" {
@@ -101,29 +174,142 @@ func (r *Runtime) Deploy(ctx context.Context, app domain.App) (*string, error) {
 		if strings.TrimSpace(r.edge.TraefikNet) == "" {
 			return nil, fmt.Errorf("docker runtime: empty traefik network")
 		}
-		if strings.TrimSpace(r.edge.Scheme) == "" {
-			// default Traefik entrypoint name
-			r.edge.Scheme = "web"
-		}
 	}
 
 	// pull image
-	if err := r.pull(ctx, app.Image); err != nil {
-		return nil, fmt.Errorf("docker runtime: pull: %w", err)
+	err := runStep(progress, domain.StepPullImage, func() error {
+		if err := r.pull(ctx, app.Image, progress); err != nil {
+			return fmt.Errorf("docker runtime: pull: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	port, err := r.resolvePort(ctx, app)
+	// The new container comes up beside the current one under a suffixed name
+	name := r.namePrefix + app.Name
+	candidate := name + "-" + randomSuffix()
+
+	var cfg *container.Config
+	var gated bool
+	err = runStep(progress, domain.StepResolvePort, func() error {
+		port, err := r.resolvePort(ctx, app)
+		if err != nil {
+			return err
+		}
+		if port != nil {
+			progress.PortResolved(*port)
+		}
+		if cfg, err = r.containerConfig(app, port); err != nil {
+			return err
+		}
+		if app.Expose {
+			gated, err = r.healthChecked(ctx, cfg)
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// replace existing container
-	name := r.namePrefix + app.Name
-	_ = r.removeIfExists(ctx, name)
+	hostcfg := &container.HostConfig{
+		PublishAllPorts: false,
+		RestartPolicy: container.RestartPolicy{
+			Name: "unless-stopped",
+		},
+	}
+	if app.Expose {
+		// Traefik routes to any container it can reach on its network, except one whose health check has not passed.
+		// A container without a health check starts off every network and joins Traefik's once it is ready.
+		hostcfg.NetworkMode = container.NetworkMode(r.edge.TraefikNet)
+		if !gated {
+			hostcfg.NetworkMode = network.NetworkNone
+		}
+	}
 
+	// Until the switch, any failure drops the new container and leaves the current one serving
+	var id string
+	err = runStep(progress, domain.StepStartContainer, func() error {
+		created, err := r.cli.ContainerCreate(ctx, client.ContainerCreateOptions{
+			Config:     cfg,
+			HostConfig: hostcfg,
+			Name:       candidate,
+		})
+		if err != nil {
+			// A create cut short by a cancel may still have gone through on the daemon
+			_ = r.removeIfExists(context.WithoutCancel(ctx), candidate)
+			return fmt.Errorf("docker runtime: create: %w", err)
+		}
+		id = created.ID
+		if _, err := r.cli.ContainerStart(ctx, id, client.ContainerStartOptions{}); err != nil {
+			_ = r.removeIfExists(context.WithoutCancel(ctx), id)
+			return fmt.Errorf("docker runtime: start container: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = runStep(progress, domain.StepHealthCheck, func() error { return r.waitReady(ctx, id) })
+	if err != nil {
+		logs := r.tailLogs(context.WithoutCancel(ctx), id, progress)
+		_ = r.removeIfExists(context.WithoutCancel(ctx), id)
+		if logs != "" {
+			return nil, fmt.Errorf("docker runtime: %w\ncontainer logs:\n%s", err, logs)
+		}
+		return nil, fmt.Errorf("docker runtime: %w", err)
+	}
+
+	// Switch: route to the new container, retire every other container of the app, then take over the stable name
+	err = runStep(progress, domain.StepReplaceContainer, func() error {
+		// Last point a cancel can stop the deploy; past it the old container goes away
+		if err := ctx.Err(); err != nil {
+			_ = r.removeIfExists(context.WithoutCancel(ctx), id)
+			return fmt.Errorf("docker runtime: canceled before switching: %w", err)
+		}
+		// A half-done switch is worse than a late one, so it runs to the end under its own deadline
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.timeout)
+		defer cancel()
+		if app.Expose && !gated {
+			if err := r.joinEdge(ctx, id); err != nil {
+				_ = r.removeIfExists(ctx, id)
+				return fmt.Errorf("docker runtime: route new container: %w", err)
+			}
+		}
+		if err := r.retireOthers(ctx, app, id); err != nil {
+			// The new container already serves; a leftover is retired by the next deploy or delete
+			log.Printf("docker runtime: retire old containers of %s: %v", app.Name, err)
+		}
+		if _, err := r.cli.ContainerRename(ctx, id, client.ContainerRenameOptions{NewName: name}); err != nil {
+			// Still serving; lookups fall back to the app id label
+			log.Printf("docker runtime: rename %s to %s: %v", candidate, name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// return stable URL
+	if !app.Expose {
+		return nil, nil
+	}
+	scheme := "http"
+	if r.edge.EnableTLS {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s.%s", scheme, app.Name, r.edge.BaseDomain)
+	return &url, nil
+}
+
+// containerConfig builds the config of an app container listening on port, which may be nil.
+func (r *Runtime) containerConfig(app domain.App, port *int) (*container.Config, error) {
 	// base labels
 	lbls := map[string]string{
-		"spacescale.app": app.Name,
+		labelAppName: app.Name,
+		labelAppID:   app.ID,
+		labelRole:    roleApp,
 	}
 	if app.Expose {
 		if port == nil {
@@ -147,66 +333,487 @@ func (r *Runtime) Deploy(ctx context.Context, app domain.App) (*string, error) {
 		}
 		cfg.ExposedPorts = network.PortSet{cPort: struct{}{}}
 	}
+	hc, err := healthConfig(app.HealthCheck, port)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Healthcheck = hc
+	return cfg, nil
+}
 
-	hostcfg := &container.HostConfig{
-		PublishAllPorts: false,
-		RestartPolicy: container.RestartPolicy{
-			Name: "unless-stopped",
-		},
+// healthChecked reports whether Docker health-checks a container made from cfg, by the app's check or the image's.
+// Traefik leaves such a container alone until it turns healthy.
+func (r *Runtime) healthChecked(ctx context.Context, cfg *container.Config) (bool, error) {
+	if cfg.Healthcheck != nil {
+		return !disabledHealthcheck(cfg.Healthcheck.Test), nil
+	}
+	inspect, err := r.cli.ImageInspect(ctx, cfg.Image)
+	if err != nil {
+		return false, fmt.Errorf("docker runtime: inspect image: %w", err)
+	}
+	if inspect.Config == nil || inspect.Config.Healthcheck == nil {
+		return false, nil
+	}
+	return !disabledHealthcheck(inspect.Config.Healthcheck.Test), nil
+}
+
+// disabledHealthcheck reports whether a HEALTHCHECK test is empty or turns checks off.
+func disabledHealthcheck(test []string) bool {
+	return len(test) == 0 || test[0] == "NONE"
+}
+
+// joinEdge moves a ready container from no network onto the Traefik network, where Traefik starts routing to it.
+func (r *Runtime) joinEdge(ctx context.Context, id string) error {
+	if _, err := r.cli.NetworkDisconnect(ctx, network.NetworkNone, client.NetworkDisconnectOptions{Container: id}); err != nil {
+		return err
+	}
+	_, err := r.cli.NetworkConnect(ctx, r.edge.TraefikNet, client.NetworkConnectOptions{Container: id})
+	return err
+}
+
+// runStep runs fn as one deploy step and reports its start and outcome.
+// A failure is also written to the build log so it reads in line with the step's own output.
+func runStep(progress contracts.DeployProgress, step domain.StepID, fn func() error) error {
+	progress.StepStarted(step)
+	err := fn()
+	if err != nil {
+		progress.Log(domain.LogEntry{
+			Timestamp: time.Now().UTC(),
+			Level:     domain.LogLevelError,
+			Message:   err.Error(),
+			Source:    string(step),
+		})
+	}
+	progress.StepFinished(step, err)
+	return err
+}
+
+// waitReady waits until a started container is fit to take traffic.
+// With a health check that means healthy; without one it must stay running for the settle period.
+// Exiting, restarting or turning unhealthy first is an error.
+func (r *Runtime) waitReady(ctx context.Context, id string) error {
+	settled := time.Now().Add(r.settle)
+	ticker := time.NewTicker(readyPoll)
+	defer ticker.Stop()
+	for {
+		res, err := r.cli.ContainerInspect(ctx, id, client.ContainerInspectOptions{})
+		if err != nil {
+			return fmt.Errorf("inspect new container: %w", err)
+		}
+		state := res.Container.State
+		switch {
+		case state == nil:
+			return fmt.Errorf("new container has no state")
+		case state.Restarting || !state.Running:
+			return fmt.Errorf("new container exited with code %d", state.ExitCode)
+		case state.Health != nil && probeCannotRun(state.Health):
+			return fmt.Errorf("new container cannot run its health check (%s): %s", lastHealthOutput(state.Health), errProbeToolsMsg)
+		case state.Health != nil && state.Health.Status == container.Unhealthy:
+			if out := lastHealthOutput(state.Health); out != "" {
+				return fmt.Errorf("new container is unhealthy: %s", out)
+			}
+			return fmt.Errorf("new container is unhealthy")
+		case state.Health != nil && state.Health.Status == container.Healthy:
+			return nil
+		case state.Health == nil && !time.Now().Before(settled):
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("new container not ready: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeCannotRun reports whether the latest health probe could not run at all, rather than failed.
+// The shell exits with probeNotFound when the probe's tool is missing, and Docker records -1 when it cannot
+// start the probe, such as without /bin/sh. A timed out probe also records -1 but says so in its output.
+func probeCannotRun(h *container.Health) bool {
+	if len(h.Log) == 0 || h.Log[len(h.Log)-1] == nil {
+		return false
+	}
+	last := h.Log[len(h.Log)-1]
+	switch last.ExitCode {
+	case probeNotFound:
+		return true
+	case -1:
+		return !strings.Contains(last.Output, "exceeded timeout")
+	default:
+		return false
+	}
+}
+
+// lastHealthOutput returns the trimmed output of the most recent health probe, if any.
+func lastHealthOutput(h *container.Health) string {
+	if len(h.Log) == 0 || h.Log[len(h.Log)-1] == nil {
+		return ""
+	}
+	return strings.TrimSpace(h.Log[len(h.Log)-1].Output)
+}
+
+// healthConfig turns an app health check into a container HEALTHCHECK; nil keeps the image's own.
+// HTTP and TCP checks probe the container port from inside the container, so the image needs /bin/sh with
+// wget or curl, or nc. A probe that finds its tool missing exits with probeNotFound.
+func healthConfig(hc *domain.HealthCheck, port *int) (*container.HealthConfig, error) {
+	if hc == nil {
+		return nil, nil
+	}
+	var test []string
+	switch hc.Type {
+	case domain.HealthCheckHTTP, domain.HealthCheckTCP:
+		if port == nil {
+			return nil, fmt.Errorf("docker runtime: %s health check: %s", hc.Type, errPortRequiredMsg)
+		}
+		if hc.Type == domain.HealthCheckTCP {
+			test = []string{"CMD-SHELL", fmt.Sprintf("nc -z 127.0.0.1 %d", *port)}
+			break
+		}
+		url := shellQuote(fmt.Sprintf("http://127.0.0.1:%d%s", *port, hc.Path))
+		// busybox images ship wget, most others ship curl; a failing wget must not fall through to a missing curl
+		test = []string{"CMD-SHELL", fmt.Sprintf(
+			"if command -v wget >/dev/null 2>&1; then wget -q -O /dev/null %s; "+
+				"elif command -v curl >/dev/null 2>&1; then curl -fsS -o /dev/null %s; "+
+				"else echo 'wget or curl not found' >&2; exit %d; fi", url, url, probeNotFound)}
+	case domain.HealthCheckCommand:
+		test = append([]string{"CMD"}, hc.Command...)
+	default:
+		return nil, fmt.Errorf("docker runtime: unknown health check type %q", hc.Type)
+	}
+	return &container.HealthConfig{
+		Test:     test,
+		Interval: hc.Interval,
+		Timeout:  hc.Timeout,
+		Retries:  hc.Retries,
+	}, nil
+}
+
+// healthBudget is the longest a health check can take to mark a container unhealthy.
+func healthBudget(hc *domain.HealthCheck) time.Duration {
+	if hc == nil {
+		return 0
+	}
+	return time.Duration(hc.Retries) * (hc.Interval + hc.Timeout)
+}
+
+// shellQuote quotes s as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// tailLogs copies the last lines of a container's output to the build log and returns them
+// as text capped in size, or "" when they cannot be read.
+func (r *Runtime) tailLogs(ctx context.Context, id string, progress contracts.DeployProgress) string {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var lines []string
+	_ = r.readLogs(ctx, id, contracts.LogQuery{Tail: failureLogLines}, func(e domain.LogEntry) error {
+		progress.Log(e)
+		lines = append(lines, e.Message)
+		return nil
+	})
+	out := strings.TrimSpace(strings.Join(lines, "\n"))
+	if len(out) > failureLogBytes {
+		out = "..." + out[len(out)-failureLogBytes:]
+	}
+	return out
+}
+
+// retireOthers stops and removes every app container of the app except keep, along with its maintenance page.
+// Stopping first lets in-flight requests finish before the container goes away.
+func (r *Runtime) retireOthers(ctx context.Context, app domain.App, keep string) error {
+	list, err := r.cli.ContainerList(ctx, client.ContainerListOptions{
+		All:     true,
+		Filters: make(client.Filters).Add("label", labelAppID+"="+app.ID),
+	})
+	if err != nil {
+		return err
+	}
+	ids := []string{r.namePrefix + app.Name} // containers created before the id label was added
+	for _, c := range list.Items {
+		if c.ID != keep {
+			ids = append(ids, c.ID)
+		}
+	}
+	var errs []error
+	for _, id := range ids {
+		if _, err := r.cli.ContainerStop(ctx, id, client.ContainerStopOptions{}); err != nil && !isNotFound(err) {
+			errs = append(errs, err)
+			continue
+		}
+		if err := r.removeIfExists(ctx, id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// randomSuffix returns a short random name suffix for a new container.
+func randomSuffix() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Remove force-removes every container of the app; routing goes with it since it lives in labels.
+// Containers are found by app id so ones left under an old name after a rename are removed too.
+func (r *Runtime) Remove(ctx context.Context, app domain.App) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	list, err := r.cli.ContainerList(ctx, client.ContainerListOptions{
+		All:     true,
+		Filters: make(client.Filters).Add("label", labelAppID+"="+app.ID),
+	})
+	if err != nil {
+		return fmt.Errorf("docker runtime: list containers: %w", err)
+	}
+	var errs []error
+	for _, c := range list.Items {
+		if err := r.removeIfExists(ctx, c.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// containers created before the id label was added are only known by name
+	if err := r.removeIfExists(ctx, r.namePrefix+app.Name); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("docker runtime: remove: %w", err)
+	}
+	return nil
+}
+
+// Stop stops the app's container; the unless-stopped policy keeps it down until Start.
+func (r *Runtime) Stop(ctx context.Context, app domain.App) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	id, err := r.containerID(ctx, app)
+	if err != nil {
+		return err
+	}
+	if _, err := r.cli.ContainerStop(ctx, id, client.ContainerStopOptions{}); err != nil {
+		return fmt.Errorf("docker runtime: stop: %w", err)
+	}
+	return nil
+}
+
+// Start starts the app's existing container without pulling or recreating it.
+func (r *Runtime) Start(ctx context.Context, app domain.App) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	id, err := r.containerID(ctx, app)
+	if err != nil {
+		return err
+	}
+	if _, err := r.cli.ContainerStart(ctx, id, client.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("docker runtime: start container: %w", err)
+	}
+	return nil
+}
+
+// Restart restarts the app's container.
+func (r *Runtime) Restart(ctx context.Context, app domain.App) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	id, err := r.containerID(ctx, app)
+	if err != nil {
+		return err
+	}
+	if _, err := r.cli.ContainerRestart(ctx, id, client.ContainerRestartOptions{}); err != nil {
+		return fmt.Errorf("docker runtime: restart: %w", err)
+	}
+	return nil
+}
+
+// Pause stops the app's container and, when the app is exposed, serves a maintenance response on its host.
+// The maintenance container is up before the app stops so the host never goes unrouted.
+func (r *Runtime) Pause(ctx context.Context, app domain.App) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	id, err := r.containerID(ctx, app)
+	if err != nil {
+		return err
 	}
 	if app.Expose {
-		// attach to Traefik network
-		hostcfg.NetworkMode = container.NetworkMode(r.edge.TraefikNet)
+		if err := r.startMaintenance(ctx, app); err != nil {
+			return err
+		}
+	}
+	if _, err := r.cli.ContainerStop(ctx, id, client.ContainerStopOptions{}); err != nil {
+		return fmt.Errorf("docker runtime: stop: %w", err)
 	}
+	return nil
+}
+
+// Resume starts the paused container as it was and drops the maintenance response.
+func (r *Runtime) Resume(ctx context.Context, app domain.App) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	id, err := r.containerID(ctx, app)
+	if err != nil {
+		return err
+	}
+	if _, err := r.cli.ContainerStart(ctx, id, client.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("docker runtime: start container: %w", err)
+	}
+	if err := r.removeIfExists(ctx, r.maintenanceName(app)); err != nil {
+		return fmt.Errorf("docker runtime: remove maintenance: %w", err)
+	}
+	return nil
+}
+
+// startMaintenance runs an nginx container that routes the app's host to a 503 page.
+// Its router has the lowest priority so the app's own router wins while both are up.
+func (r *Runtime) startMaintenance(ctx context.Context, app domain.App) error {
+	if strings.TrimSpace(r.edge.BaseDomain) == "" {
+		return fmt.Errorf("docker runtime: empty base domain")
+	}
+	if strings.TrimSpace(r.edge.TraefikNet) == "" {
+		return fmt.Errorf("docker runtime: empty traefik network")
+	}
+	if err := r.pull(ctx, r.maintenanceImage, contracts.NoProgress{}); err != nil {
+		return fmt.Errorf("docker runtime: pull maintenance image: %w", err)
+	}
+
+	name := r.maintenanceName(app)
+	_ = r.removeIfExists(ctx, name)
+
+	router := "maint-" + app.Name
+	lbls := routeLabels(router, "svc-maint-"+app.Name, appHost(app, r.edge), 80, r.edge)
+	lbls[fmt.Sprintf("traefik.http.routers.%s.priority", router)] = "1"
+	lbls[labelAppName] = app.Name
+	lbls[labelAppID] = app.ID
+	lbls[labelRole] = roleMaintenance
 
 	created, err := r.cli.ContainerCreate(ctx, client.ContainerCreateOptions{
-		Config:     cfg,
-		HostConfig: hostcfg,
-		Name:       name,
+		Config: &container.Config{
+			Image:  r.maintenanceImage,
+			Labels: lbls,
+			Env:    []string{"MAINTENANCE_CONF=" + maintenanceConf},
+			Cmd: []string{"sh", "-c",
+				`printf '%s' "$MAINTENANCE_CONF" > /etc/nginx/conf.d/default.conf && exec nginx -g 'daemon off;'`},
+		},
+		HostConfig: &container.HostConfig{
+			RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
+			NetworkMode:   container.NetworkMode(r.edge.TraefikNet),
+		},
+		Name: name,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("docker runtime: create: %w", err)
+		return fmt.Errorf("docker runtime: create maintenance: %w", err)
 	}
 	if _, err := r.cli.ContainerStart(ctx, created.ID, client.ContainerStartOptions{}); err != nil {
-		return nil, fmt.Errorf("docker runtime: start container: %w", err)
+		_ = r.removeIfExists(ctx, name)
+		return fmt.Errorf("docker runtime: start maintenance: %w", err)
 	}
+	return nil
+}
 
-	// return stable URL
-	if !app.Expose {
-		return nil, nil
+// maintenanceName is the container name of an app's maintenance page.
+func (r *Runtime) maintenanceName(app domain.App) string {
+	return r.namePrefix + app.Name + "-maintenance"
+}
+
+// Status inspects the app's container and reports its state.
+func (r *Runtime) Status(ctx context.Context, app domain.App) (domain.RuntimeStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	ins, err := r.inspect(ctx, app)
+	if err != nil {
+		return domain.RuntimeStatus{}, err
 	}
-	scheme := "http"
-	if r.edge.EnableTLS {
-		scheme = "https"
+	return statusFromInspect(ins), nil
+}
+
+// containerID returns the id of the app's container.
+func (r *Runtime) containerID(ctx context.Context, app domain.App) (string, error) {
+	ins, err := r.inspect(ctx, app)
+	if err != nil {
+		return "", err
 	}
-	url := fmt.Sprintf("%s://%s.%s", scheme, app.Name, r.edge.BaseDomain)
-	return &url, nil
+	return ins.ID, nil
 }
 
-// pull pulls an image and drains the response stream.
-func (r *Runtime) pull(ctx context.Context, ref string) error {
-	rc, err := r.cli.ImagePull(ctx, ref, client.ImagePullOptions{})
+// inspect looks up the app's container by name and checks it carries the app's label.
+// When the name is free, such as after a deploy could not rename its container, the newest
+// app container with the app's id label is used. Finding neither is contracts.ErrNotFound.
+func (r *Runtime) inspect(ctx context.Context, app domain.App) (container.InspectResponse, error) {
+	res, err := r.cli.ContainerInspect(ctx, r.namePrefix+app.Name, client.ContainerInspectOptions{})
+	if err != nil && !isNotFound(err) {
+		return container.InspectResponse{}, fmt.Errorf("docker runtime: inspect: %w", err)
+	}
+	if err == nil && res.Container.Config != nil && res.Container.Config.Labels[labelAppName] == app.Name {
+		return res.Container, nil
+	}
+
+	list, err := r.cli.ContainerList(ctx, client.ContainerListOptions{
+		All: true,
+		Filters: make(client.Filters).
+			Add("label", labelAppID+"="+app.ID, labelRole+"="+roleApp),
+	})
 	if err != nil {
-		return err
+		return container.InspectResponse{}, fmt.Errorf("docker runtime: list containers: %w", err)
 	}
-	defer rc.Close()
-	_, _ = io.Copy(io.Discard, rc)
-	return nil
+	if len(list.Items) == 0 {
+		return container.InspectResponse{}, contracts.ErrNotFound
+	}
+	newest := list.Items[0]
+	for _, c := range list.Items[1:] {
+		if c.Created > newest.Created {
+			newest = c
+		}
+	}
+	res, err = r.cli.ContainerInspect(ctx, newest.ID, client.ContainerInspectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return container.InspectResponse{}, contracts.ErrNotFound
+		}
+		return container.InspectResponse{}, fmt.Errorf("docker runtime: inspect: %w", err)
+	}
+	return res.Container, nil
+}
+
+// statusFromInspect reduces a container inspect to a runtime status.
+func statusFromInspect(ins container.InspectResponse) domain.RuntimeStatus {
+	st := domain.RuntimeStatus{
+		State:        domain.RuntimeStateExited,
+		RestartCount: ins.RestartCount,
+	}
+	if ins.State == nil {
+		return st
+	}
+	switch {
+	case ins.State.Restarting:
+		st.State = domain.RuntimeStateRestarting
+	case ins.State.Running:
+		st.State = domain.RuntimeStateRunning
+	}
+	st.ExitCode = ins.State.ExitCode
+	// docker reports a zero time for containers that never started
+	if started, err := time.Parse(time.RFC3339Nano, ins.State.StartedAt); err == nil && !started.IsZero() {
+		started = started.UTC()
+		st.StartedAt = &started
+	}
+	return st
 }
 
 // removeIfExists removes a container and ignores not found errors.
 func (r *Runtime) removeIfExists(ctx context.Context, name string) error {
 	_, err := r.cli.ContainerRemove(ctx, name, client.ContainerRemoveOptions{Force: true})
-	if err == nil {
-		return nil
-	}
-	msg := strings.ToLower(err.Error())
-	if strings.Contains(msg, "no such container") || strings.Contains(msg, "not found") {
+	if err == nil || isNotFound(err) {
 		return nil
 	}
 	return err
 }
 
+// isNotFound reports whether a docker error means the container does not exist.
+func isNotFound(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "no such container") || strings.Contains(msg, "not found")
+}
+
 // resolvePort chooses the port from the app or image when exposed.
 func (r *Runtime) resolvePort(ctx context.Context, app domain.App) (*int, error) {
 	if app.Port != nil {
@@ -311,13 +918,16 @@ func (r *Runtime) hostPort(ctx context.Context, containerID string, cPort networ
 // It expects port to be the internal container port.
 // CertResolver is set only when TLS is enabled.
 func labelsForApp(app domain.App, port int, cfg EdgeConfig) map[string]string {
-	// build hostname
-	host := fmt.Sprintf("%s.%s", app.Name, cfg.BaseDomain)
+	return routeLabels("app-"+app.Name, "svc-"+app.Name, appHost(app, cfg), port, cfg)
+}
 
-	// router and service names
-	router := "app-" + app.Name
-	svc := "svc-" + app.Name
+// appHost returns the hostname an app is served on.
+func appHost(app domain.App, cfg EdgeConfig) string {
+	return fmt.Sprintf("%s.%s", app.Name, cfg.BaseDomain)
+}
 
+// routeLabels builds the Traefik labels that route host to port through router and svc.
+func routeLabels(router, svc, host string, port int, cfg EdgeConfig) map[string]string {
 	labels := map[string]string{
 		// Traefik v2 labels
 		// enable Traefik
@@ -349,3 +959,6 @@ func labelsForApp(app domain.App, port int, cfg EdgeConfig) map[string]string {
 
 	return labels
 }
+
+// Compile-time check: ensure Runtime implements the Runtime contract.
+var _ contracts.Runtime = (*Runtime)(nil)
@@ -8,10 +8,33 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/t0gun/spacescale/internal/adapters/runtime/docker"
+	"github.com/t0gun/spacescale/internal/contracts"
 	"github.com/t0gun/spacescale/internal/domain"
 )
 
+// TestProbeCannotRun verifies a probe missing its shell or tool is told apart from one that failed or timed out.
+func TestProbeCannotRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode int
+		output   string
+		want     bool
+	}{
+		{"tool missing", 127, "wget or curl not found", true},
+		{"no shell", -1, `exec: "/bin/sh": stat /bin/sh: no such file or directory`, true},
+		{"timed out", -1, "Health check exceeded timeout (5s)", false},
+		{"failed", 1, "connection refused", false},
+		{"healthy", 0, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, docker.ProbeCannotRun(tt.exitCode, tt.output))
+		})
+	}
+}
+
 // TestDockerRuntime_Deploy runs a Docker-backed deploy with explicit port.
 func TestDockerRuntime_Deploy(t *testing.T) {
 	if os.Getenv("RUN_DOCKER_TESTS") != "1" {
@@ -38,7 +61,7 @@ func TestDockerRuntime_Deploy(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	url, err := rt.Deploy(ctx, app)
+	url, err := rt.Deploy(ctx, app, nil)
 	assert.NoError(t, err)
 
 	assert.NotNil(t, url)
@@ -55,7 +78,7 @@ func TestDockerRuntime_Deploy_EmptyImage(t *testing.T) {
 	rt, err := docker.New()
 	assert.NoError(t, err)
 	app := domain.App{Name: "app", Image: "", Expose: false}
-	url, err := rt.Deploy(context.Background(), app)
+	url, err := rt.Deploy(context.Background(), app, nil)
 	assert.Nil(t, url)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "docker runtime: empty image")
@@ -70,7 +93,7 @@ func TestDockerRuntime_Deploy_EmptyBaseDomain(t *testing.T) {
 	}))
 	assert.NoError(t, err)
 	app := domain.App{Name: "app", Image: "nginx:latest", Expose: true}
-	url, err := rt.Deploy(context.Background(), app)
+	url, err := rt.Deploy(context.Background(), app, nil)
 	assert.Nil(t, url)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "docker runtime: empty base domain")
@@ -85,7 +108,7 @@ func TestDockerRuntime_Deploy_EmptyTraefikNet(t *testing.T) {
 	}))
 	assert.NoError(t, err)
 	app := domain.App{Name: "app", Image: "nginx:latest", Expose: true}
-	url, err := rt.Deploy(context.Background(), app)
+	url, err := rt.Deploy(context.Background(), app, nil)
 	assert.Nil(t, url)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "docker runtime: empty traefik network")
@@ -110,10 +133,12 @@ func TestDockerRuntime_Deploy_ImplicitPort(t *testing.T) {
 	assert.NoError(t, err)
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
-	url, err := rt.Deploy(ctx, app)
+	var steps stepLog
+	url, err := rt.Deploy(ctx, app, &steps)
 	assert.NoError(t, err)
 	assert.NotNil(t, url)
 	assert.Equal(t, "http://hello-implicit.localtest.me", *url)
+	assert.Equal(t, 80, steps.port)
 }
 
 // TestDockerRuntime_Deploy_NoExpose returns nil URL when not exposed.
@@ -135,7 +160,254 @@ func TestDockerRuntime_Deploy_NoExpose(t *testing.T) {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
-	url, err := rt.Deploy(ctx, app)
+	url, err := rt.Deploy(ctx, app, nil)
 	assert.NoError(t, err)
 	assert.Nil(t, url)
 }
+
+// TestDockerRuntime_Lifecycle stops, starts, restarts, pauses and removes a deployed container.
+func TestDockerRuntime_Lifecycle(t *testing.T) {
+	if os.Getenv("RUN_DOCKER_TESTS") != "1" {
+		t.Skip("set RUN_DOCKER_TESTS=1 to run docker integration tests")
+	}
+	rt, err := docker.New()
+	require.NoError(t, err)
+	app, err := domain.NewApp(domain.NewAppParams{
+		Name:   "hello-lifecycle",
+		Image:  "nginx:latest",
+		Expose: ptrBool(false),
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	t.Cleanup(func() { _ = rt.Remove(context.Background(), app) })
+
+	_, err = rt.Status(ctx, app)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+
+	_, err = rt.Deploy(ctx, app, nil)
+	require.NoError(t, err)
+	st, err := rt.Status(ctx, app)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStateRunning, st.State)
+	assert.NotNil(t, st.StartedAt)
+
+	require.NoError(t, rt.Stop(ctx, app))
+	st, err = rt.Status(ctx, app)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStateExited, st.State)
+
+	require.NoError(t, rt.Start(ctx, app))
+	require.NoError(t, rt.Restart(ctx, app))
+	st, err = rt.Status(ctx, app)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStateRunning, st.State)
+
+	require.NoError(t, rt.Pause(ctx, app))
+	st, err = rt.Status(ctx, app)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStateExited, st.State)
+	require.NoError(t, rt.Resume(ctx, app))
+	st, err = rt.Status(ctx, app)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStateRunning, st.State)
+
+	require.NoError(t, rt.Remove(ctx, app))
+	require.NoError(t, rt.Remove(ctx, app), "removing twice is not an error")
+	assert.ErrorIs(t, rt.Stop(ctx, app), contracts.ErrNotFound)
+}
+
+// TestDockerRuntime_Logs reads, tails and follows a deployed container's output.
+func TestDockerRuntime_Logs(t *testing.T) {
+	if os.Getenv("RUN_DOCKER_TESTS") != "1" {
+		t.Skip("set RUN_DOCKER_TESTS=1 to run docker integration tests")
+	}
+	rt, err := docker.New()
+	require.NoError(t, err)
+	app, err := domain.NewApp(domain.NewAppParams{
+		Name:   "hello-logs",
+		Image:  "nginx:latest",
+		Expose: ptrBool(false),
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	t.Cleanup(func() { _ = rt.Remove(context.Background(), app) })
+
+	collect := func(ctx context.Context, q contracts.LogQuery) ([]domain.LogEntry, error) {
+		var out []domain.LogEntry
+		err := rt.Logs(ctx, app, q, func(e domain.LogEntry) error {
+			out = append(out, e)
+			return nil
+		})
+		return out, err
+	}
+	_, err = collect(ctx, contracts.LogQuery{})
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+
+	_, err = rt.Deploy(ctx, app, nil)
+	require.NoError(t, err)
+
+	// nginx announces its startup on stdout and stderr
+	all, err := collect(ctx, contracts.LogQuery{})
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+	for _, e := range all {
+		assert.False(t, e.Timestamp.IsZero())
+		assert.Contains(t, []string{domain.LogSourceStdout, domain.LogSourceStderr}, e.Source)
+		assert.NotContains(t, e.Message, "\n")
+	}
+
+	tail, err := collect(ctx, contracts.LogQuery{Tail: 1})
+	require.NoError(t, err)
+	assert.Len(t, tail, 1)
+
+	future := time.Now().Add(time.Hour)
+	none, err := collect(ctx, contracts.LogQuery{Since: &future})
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	// Following holds the stream open until the caller stops
+	followCtx, stop := context.WithTimeout(ctx, 2*time.Second)
+	defer stop()
+	followed, err := collect(followCtx, contracts.LogQuery{Tail: 1, Follow: true})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, followed, 1)
+}
+
+// ptrBool returns a pointer to the provided bool.
+func ptrBool(v bool) *bool {
+	return &v
+}
+
+// TestDockerRuntime_Deploy_KeepsOldOnFailure verifies a replacement that exits leaves the running container alone.
+func TestDockerRuntime_Deploy_KeepsOldOnFailure(t *testing.T) {
+	if os.Getenv("RUN_DOCKER_TESTS") != "1" {
+		t.Skip("set RUN_DOCKER_TESTS=1 to run docker integration tests")
+	}
+	rt, err := docker.New(docker.WithSettle(2 * time.Second))
+	require.NoError(t, err)
+	app, err := domain.NewApp(domain.NewAppParams{
+		Name:   "hello-bluegreen",
+		Image:  "nginx:latest",
+		Expose: ptrBool(false),
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	t.Cleanup(func() { _ = rt.Remove(context.Background(), app) })
+
+	_, err = rt.Deploy(ctx, app, nil)
+	require.NoError(t, err)
+	before, err := rt.Status(ctx, app)
+	require.NoError(t, err)
+
+	// alpine has no long running process, so it exits right after starting.
+	bad := app
+	bad.Image = "alpine:latest"
+	_, err = rt.Deploy(ctx, bad, nil)
+	assert.ErrorContains(t, err, "exited")
+
+	after, err := rt.Status(ctx, app)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStateRunning, after.State)
+	assert.Equal(t, before.StartedAt, after.StartedAt)
+
+	// A good replacement takes over the stable name.
+	_, err = rt.Deploy(ctx, app, nil)
+	require.NoError(t, err)
+	after, err = rt.Status(ctx, app)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStateRunning, after.State)
+	assert.NotEqual(t, before.StartedAt, after.StartedAt)
+}
+
+// TestDockerRuntime_Deploy_HealthCheckFails verifies a replacement that never turns healthy fails with its logs.
+func TestDockerRuntime_Deploy_HealthCheckFails(t *testing.T) {
+	if os.Getenv("RUN_DOCKER_TESTS") != "1" {
+		t.Skip("set RUN_DOCKER_TESTS=1 to run docker integration tests")
+	}
+	rt, err := docker.New()
+	require.NoError(t, err)
+	app, err := domain.NewApp(domain.NewAppParams{
+		Name:   "hello-health",
+		Image:  "nginx:latest",
+		Expose: ptrBool(false),
+		HealthCheck: &domain.HealthCheck{
+			Type:     domain.HealthCheckHTTP,
+			Path:     "/missing",
+			Interval: time.Second,
+			Timeout:  time.Second,
+			Retries:  2,
+		},
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	t.Cleanup(func() { _ = rt.Remove(context.Background(), app) })
+
+	var steps stepLog
+	_, err = rt.Deploy(ctx, app, &steps)
+	assert.ErrorContains(t, err, "unhealthy")
+	assert.ErrorContains(t, err, "container logs")
+	// The health check is the step that failed and the switch never started
+	assert.Equal(t, []string{
+		"pull_image started", "pull_image ok",
+		"resolve_port started", "resolve_port ok",
+		"start_container started", "start_container ok",
+		"health_check started", "health_check failed",
+	}, steps.events)
+	// The build log has the pull, the failure and what the container printed before it was removed
+	sources := steps.sources()
+	assert.Positive(t, sources["pull_image"])
+	assert.Equal(t, 1, sources["health_check"])
+	assert.Positive(t, sources["stdout"]+sources["stderr"])
+
+	// Nothing is left behind.
+	_, err = rt.Status(ctx, app)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+
+	app.HealthCheck.Path = "/"
+	_, err = rt.Deploy(ctx, app, nil)
+	assert.NoError(t, err)
+}
+
+// stepLog records the step reports and build log of a deploy.
+type stepLog struct {
+	events []string
+	lines  []domain.LogEntry
+	port   int
+}
+
+// StepStarted records a step start.
+func (l *stepLog) StepStarted(step domain.StepID) {
+	l.events = append(l.events, string(step)+" started")
+}
+
+// StepFinished records a step outcome.
+func (l *stepLog) StepFinished(step domain.StepID, err error) {
+	outcome := " ok"
+	if err != nil {
+		outcome = " failed"
+	}
+	l.events = append(l.events, string(step)+outcome)
+}
+
+// Log records a build log line.
+func (l *stepLog) Log(line domain.LogEntry) {
+	l.lines = append(l.lines, line)
+}
+
+// PortResolved records the port.
+func (l *stepLog) PortResolved(port int) {
+	l.port = port
+}
+
+// sources counts the build log lines of each source.
+func (l *stepLog) sources() map[string]int {
+	out := make(map[string]int)
+	for _, line := range l.lines {
+		out[line.Source]++
+	}
+	return out
+}
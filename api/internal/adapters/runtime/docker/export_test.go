@@ -0,0 +1,29 @@
+// Test hooks into the docker runtime's unexported log parsing and health probe checks.
+
+package docker
+
+import (
+	"github.com/moby/moby/api/types/container"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// ProbeCannotRun reports whether a container whose latest health probe ended this way could not run the probe.
+func ProbeCannotRun(exitCode int, output string) bool {
+	return probeCannotRun(&container.Health{Log: []*container.HealthcheckResult{{ExitCode: exitCode, Output: output}}})
+}
+
+// SplitLogFrames runs frames of one stream through the log line writer, as Docker would deliver them,
+// and returns the entries it emits.
+func SplitLogFrames(source string, frames ...string) ([]domain.LogEntry, error) {
+	var out []domain.LogEntry
+	w := &logLineWriter{source: source, emit: func(e domain.LogEntry) error {
+		out = append(out, e)
+		return nil
+	}}
+	for _, f := range frames {
+		if _, err := w.Write([]byte(f)); err != nil {
+			return nil, err
+		}
+	}
+	return out, w.flush()
+}
@@ -0,0 +1,142 @@
+// Docker runtime log reading.
+// Container stdout and stderr are demultiplexed into separate lines.
+// Docker stamps every line, so entries carry the time they were written.
+// Long lines split by Docker are joined back, keeping only the first piece's timestamp.
+// Following keeps the stream open until the caller stops or the container goes away.
+
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/api/pkg/stdcopy"
+	"github.com/moby/moby/client"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// Logs reads the app container's stdout and stderr in the order they were written.
+func (r *Runtime) Logs(ctx context.Context, app domain.App, q contracts.LogQuery, emit func(domain.LogEntry) error) error {
+	lookupCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	id, err := r.containerID(lookupCtx, app)
+	cancel()
+	if err != nil {
+		return err
+	}
+	return r.readLogs(ctx, id, q, emit)
+}
+
+// readLogs reads the output of the container with id.
+func (r *Runtime) readLogs(ctx context.Context, id string, q contracts.LogQuery, emit func(domain.LogEntry) error) error {
+	if !q.Follow {
+		// Following runs for as long as the caller reads; a plain read is bounded like any other call
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	opts := client.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Follow:     q.Follow,
+	}
+	if q.Since != nil {
+		opts.Since = fmt.Sprintf("%d.%09d", q.Since.Unix(), q.Since.Nanosecond())
+	}
+	if q.Tail > 0 {
+		opts.Tail = strconv.Itoa(q.Tail)
+	}
+	rc, err := r.cli.ContainerLogs(ctx, id, opts)
+	if err != nil {
+		if isNotFound(err) {
+			return contracts.ErrNotFound
+		}
+		return fmt.Errorf("docker runtime: logs: %w", err)
+	}
+	defer rc.Close()
+
+	stdout := &logLineWriter{source: domain.LogSourceStdout, emit: emit}
+	stderr := &logLineWriter{source: domain.LogSourceStderr, emit: emit}
+	if _, err := stdcopy.StdCopy(stdout, stderr, rc); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	if err := stdout.flush(); err != nil {
+		return err
+	}
+	return stderr.flush()
+}
+
+// logLineWriter turns one demultiplexed stream into log entries, a line at a time.
+type logLineWriter struct {
+	source string
+	emit   func(domain.LogEntry) error
+	buf    []byte
+}
+
+// Write buffers one frame of output and emits every complete line in it.
+// Docker sends each 16KiB piece of a longer line as its own frame with its own timestamp,
+// so a frame that continues a buffered line has its timestamp dropped.
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(w.buf) > 0 {
+		p = trimLogStamp(p)
+	}
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return n, nil
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		if err := w.emit(parseLogLine(w.source, line)); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// trimLogStamp drops the timestamp Docker prefixes to a frame, if it has one.
+func trimLogStamp(p []byte) []byte {
+	stamp, rest, ok := bytes.Cut(p, []byte(" "))
+	if !ok {
+		return p
+	}
+	if _, err := time.Parse(time.RFC3339Nano, string(stamp)); err != nil {
+		return p
+	}
+	return rest
+}
+
+// flush emits a last line that did not end in a newline.
+func (w *logLineWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := string(w.buf)
+	w.buf = nil
+	return w.emit(parseLogLine(w.source, line))
+}
+
+// parseLogLine splits the timestamp Docker prefixes to a line from the message.
+// A line without one is stamped with the time it was read.
+func parseLogLine(source, line string) domain.LogEntry {
+	line = strings.TrimSuffix(line, "\r")
+	entry := domain.LogEntry{Timestamp: time.Now().UTC(), Message: line, Source: source}
+	if stamp, msg, ok := strings.Cut(line, " "); ok {
+		if ts, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+			entry.Timestamp = ts.UTC()
+			entry.Message = msg
+		}
+	}
+	entry.Level = domain.DetectLogLevel(source, entry.Message)
+	return entry
+}
@@ -0,0 +1,46 @@
+// Docker runtime tests for log line parsing.
+package docker_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/runtime/docker"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// TestLogLines_LongLine verifies a line Docker split into 16KiB frames comes back whole with its first timestamp.
+func TestLogLines_LongLine(t *testing.T) {
+	long := strings.Repeat("a", 16<<10) + strings.Repeat("b", 16<<10) + "tail"
+	entries, err := docker.SplitLogFrames(domain.LogSourceStdout,
+		"2024-05-01T10:00:00.000000001Z "+long[:16<<10],
+		"2024-05-01T10:00:00.000000002Z "+long[16<<10:32<<10],
+		"2024-05-01T10:00:00.000000003Z "+long[32<<10:]+"\n",
+		"2024-05-01T10:00:01Z short line\n",
+	)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, long, entries[0].Message)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 1, time.UTC), entries[0].Timestamp)
+	assert.Equal(t, domain.LogSourceStdout, entries[0].Source)
+	assert.Equal(t, "short line", entries[1].Message)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC), entries[1].Timestamp)
+}
+
+// TestLogLines_Unstamped verifies a frame continuing a line without a timestamp is kept as is.
+func TestLogLines_Unstamped(t *testing.T) {
+	entries, err := docker.SplitLogFrames(domain.LogSourceStderr,
+		"2024-05-01T10:00:00Z first half ",
+		"second half\n",
+		"no newline at the end",
+	)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "first half second half", entries[0].Message)
+	assert.Equal(t, "no newline at the end", entries[1].Message)
+	assert.False(t, entries[1].Timestamp.IsZero())
+}
@@ -0,0 +1,113 @@
+// Docker runtime image pulls.
+// The registry's JSON progress stream is turned into build log lines.
+// Layers report each status change and download or extract progress in quarters.
+// Digest and final status lines are passed through as they are.
+// An error the registry reports part way through fails the pull.
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/moby/moby/api/types/jsonstream"
+	"github.com/moby/moby/client"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// pullProgressStep is how far a layer must move, in percent, before its progress is logged again.
+const pullProgressStep = 25
+
+// pull pulls an image and writes the registry's progress to the build log.
+func (r *Runtime) pull(ctx context.Context, ref string, progress contracts.DeployProgress) error {
+	res, err := r.cli.ImagePull(ctx, ref, client.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+	log := newPullLog(progress)
+	for msg, err := range res.JSONMessages(ctx) {
+		if err != nil {
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		log.message(msg)
+	}
+	return nil
+}
+
+// pullLog turns pull messages into build log lines without repeating a layer's every progress tick.
+type pullLog struct {
+	progress contracts.DeployProgress
+	layers   map[string]layerProgress
+}
+
+// layerProgress is what was last logged for a layer.
+type layerProgress struct {
+	status  string
+	percent int
+}
+
+// newPullLog returns a pullLog writing to progress.
+func newPullLog(progress contracts.DeployProgress) *pullLog {
+	return &pullLog{progress: progress, layers: make(map[string]layerProgress)}
+}
+
+// message logs msg when it says something new.
+func (l *pullLog) message(msg jsonstream.Message) {
+	if msg.Status == "" {
+		return
+	}
+	if msg.ID == "" {
+		// Digest and final status lines
+		l.log(msg.Status)
+		return
+	}
+
+	last, seen := l.layers[msg.ID]
+	next := layerProgress{status: msg.Status}
+	p := msg.Progress
+	if p != nil && p.Total > 0 {
+		next.percent = int(p.Current * 100 / p.Total)
+		if seen && last.status == next.status && next.percent-last.percent < pullProgressStep {
+			return
+		}
+		// Round down so later ticks are compared against a whole step
+		next.percent -= next.percent % pullProgressStep
+		l.layers[msg.ID] = next
+		l.log(fmt.Sprintf("%s: %s %d%% (%s/%s)", msg.ID, msg.Status, next.percent, humanBytes(p.Current), humanBytes(p.Total)))
+		return
+	}
+	if seen && last.status == next.status {
+		return
+	}
+	l.layers[msg.ID] = next
+	l.log(msg.ID + ": " + msg.Status)
+}
+
+// log writes one info line from the pull step.
+func (l *pullLog) log(message string) {
+	l.progress.Log(domain.LogEntry{
+		Timestamp: time.Now().UTC(),
+		Level:     domain.LogLevelInfo,
+		Message:   message,
+		Source:    string(domain.StepPullImage),
+	})
+}
+
+// humanBytes formats a byte count with decimal units the way the docker CLI does.
+func humanBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,10 @@
+// Test hooks into the postgres store's unexported column lists.
+
+package store
+
+// Column lists the postgres store reads and writes, keyed by table.
+var PostgresColumns = map[string]string{
+	"apps":            appColumns,
+	"deployments":     deploymentColumns + `, seq`,
+	"deployment_logs": `seq, deployment_id, logged_at, level, source, message`,
+}
@@ -3,7 +3,11 @@ package store
 
 import (
 	"context"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/t0gun/spacescale/internal/contracts"
 	"github.com/t0gun/spacescale/internal/domain"
@@ -24,6 +28,7 @@ type MemoryStore struct {
 	deploymentByID       map[string]domain.Deployment
 	deploymentIDsByAppID map[string][]string
 	queuedDeploymentIDs  []string
+	deploymentLogs       map[string][]domain.LogEntry
 }
 
 // NewMemoryStore returns a ready to use in memory store.
@@ -35,6 +40,7 @@ func NewMemoryStore() *MemoryStore {
 		deploymentByID:       make(map[string]domain.Deployment),
 		deploymentIDsByAppID: make(map[string][]string),
 		queuedDeploymentIDs:  make([]string, 0),
+		deploymentLogs:       make(map[string][]domain.LogEntry),
 	}
 }
 
@@ -77,16 +83,104 @@ func (s *MemoryStore) GetAppByName(ctx context.Context, name string) (domain.App
 	return app, nil
 }
 
-// ListApps returns all apps in the store.
-func (s *MemoryStore) ListApps(ctx context.Context) ([]domain.App, error) {
+// ListApps returns one page of matching apps sorted by creation time then id.
+// Map iteration order is random, so we always sort before paging.
+func (s *MemoryStore) ListApps(ctx context.Context, f contracts.AppFilter) ([]domain.App, int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	out := make([]domain.App, 0, len(s.appByID))
 	for _, a := range s.appByID {
+		if f.Status != "" && a.Status != f.Status {
+			continue
+		}
+		if !strings.HasPrefix(a.Name, f.NamePrefix) {
+			continue
+		}
 		out = append(out, a)
 	}
-	return out, nil
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return paginate(out, f.Page), len(out), nil
+}
+
+// UpdateApp replaces a stored app when the caller read the current version.
+// A rename moves the name index entry, so GetAppByName follows the app.
+func (s *MemoryStore) UpdateApp(ctx context.Context, app domain.App) (domain.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.appByID[app.ID]
+	if !exists {
+		return domain.App{}, contracts.ErrNotFound
+	}
+	if current.Version != app.Version {
+		return domain.App{}, contracts.ErrVersionMismatch
+	}
+	if app.Name != current.Name {
+		if _, taken := s.appByName[app.Name]; taken {
+			return domain.App{}, contracts.ErrConflict
+		}
+		delete(s.appByName, current.Name)
+	}
+
+	app.Version++
+	s.appByID[app.ID] = app
+	s.appByName[app.Name] = app
+	return app, nil
+}
+
+// UpdateAppStatus sets an app's status and bumps its version unless the app is being deleted.
+func (s *MemoryStore) UpdateAppStatus(ctx context.Context, id string, status domain.AppStatus) (domain.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, exists := s.appByID[id]
+	if !exists {
+		return domain.App{}, contracts.ErrNotFound
+	}
+	if app.Status == domain.AppStatusDeleting {
+		return app, nil
+	}
+	app.Status = status
+	app.Version++
+	app.UpdatedAt = time.Now().UTC()
+	s.appByID[id] = app
+	s.appByName[app.Name] = app
+	return app, nil
+}
+
+// DeleteApp removes an app, its deployments and their queue entries.
+func (s *MemoryStore) DeleteApp(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, exists := s.appByID[id]
+	if !exists {
+		return contracts.ErrNotFound
+	}
+	delete(s.appByID, id)
+	delete(s.appByName, app.Name)
+
+	for _, depID := range s.deploymentIDsByAppID[id] {
+		delete(s.deploymentByID, depID)
+		delete(s.deploymentLogs, depID)
+	}
+	delete(s.deploymentIDsByAppID, id)
+
+	// Drop queue entries whose deployment is gone.
+	queued := s.queuedDeploymentIDs[:0]
+	for _, depID := range s.queuedDeploymentIDs {
+		if _, ok := s.deploymentByID[depID]; ok {
+			queued = append(queued, depID)
+		}
+	}
+	s.queuedDeploymentIDs = queued
+	return nil
 }
 
 // CreateDeployment stores a deployment and enqueues it when queued.
@@ -128,14 +222,14 @@ func (s *MemoryStore) GetDeploymentByID(ctx context.Context, id string) (domain.
 	return dep, nil
 }
 
-// ListDeploymentsByAppID returns deployments for a single app.
-func (s *MemoryStore) ListDeploymentsByAppID(ctx context.Context, appID string) ([]domain.Deployment, error) {
+// ListDeploymentsByAppID returns one page of an app's matching deployments.
+func (s *MemoryStore) ListDeploymentsByAppID(ctx context.Context, appID string, f contracts.DeploymentFilter) ([]domain.Deployment, int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	ids := s.deploymentIDsByAppID[appID]
 	if len(ids) == 0 {
-		return []domain.Deployment{}, nil
+		return []domain.Deployment{}, 0, nil
 	}
 
 	out := make([]domain.Deployment, 0, len(ids))
@@ -148,19 +242,56 @@ func (s *MemoryStore) ListDeploymentsByAppID(ctx context.Context, appID string)
 			// We skip instead of failing the whole list call.
 			continue
 		}
+		if f.Status != "" && dep.Status != f.Status {
+			continue
+		}
 		out = append(out, dep)
 	}
 
+	return paginate(out, f.Page), len(out), nil
+}
+
+// LatestDeployments returns the last created deployment of each app.
+func (s *MemoryStore) LatestDeployments(ctx context.Context, appIDs []string) (map[string]domain.Deployment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make(map[string]domain.Deployment, len(appIDs))
+	for _, appID := range appIDs {
+		ids := s.deploymentIDsByAppID[appID]
+		if len(ids) == 0 {
+			continue
+		}
+		if dep, ok := s.deploymentByID[ids[len(ids)-1]]; ok {
+			out[appID] = dep
+		}
+	}
 	return out, nil
 }
 
-// TakeNextQueuedDeployment returns and removes the next QUEUED deployment from the FIFO queue.
-// We defensively skip stale queue entries (missing deployment) and skip deployments that are
-// no longer QUEUED (e.g. already processed but ID still remained in queue).
-func (s *MemoryStore) TakeNextQueuedDeployment(ctx context.Context) (domain.Deployment, error) {
+// paginate returns the window of items selected by p.
+func paginate[T any](items []T, p contracts.Page) []T {
+	if p.Offset >= len(items) {
+		return items[:0]
+	}
+	items = items[p.Offset:]
+	if p.Limit > 0 && p.Limit < len(items) {
+		items = items[:p.Limit]
+	}
+	return items
+}
+
+// TakeNextQueuedDeployment claims the next QUEUED deployment from the FIFO queue for workerID.
+// Expired leases are first put back at the front of the queue in create order, since they were
+// queued before anything still waiting. We defensively skip stale queue entries (missing deployment)
+// and skip deployments that are no longer QUEUED (e.g. already processed but ID still remained in queue).
+func (s *MemoryStore) TakeNextQueuedDeployment(ctx context.Context, workerID string, lease time.Duration) (domain.Deployment, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := time.Now().UTC()
+	s.requeueExpiredLocked(now)
+
 	for len(s.queuedDeploymentIDs) > 0 {
 		nextID := s.queuedDeploymentIDs[0]
 		s.queuedDeploymentIDs = s.queuedDeploymentIDs[1:]
@@ -176,27 +307,202 @@ func (s *MemoryStore) TakeNextQueuedDeployment(ctx context.Context) (domain.Depl
 			continue
 		}
 
+		expires := now.Add(lease)
+		dep.ClaimedBy = &workerID
+		dep.LeaseExpiresAt = &expires
+		dep.Attempts++
+		dep.Version++
+		s.deploymentByID[dep.ID] = dep
 		return dep, nil
 	}
 
 	return domain.Deployment{}, contracts.ErrNotFound
 }
 
-// UpdateDeployment updates the stored deployment source of truth
-// Because our app-history index stores only IDs, we do NOT need to update any slices here.
-func (s *MemoryStore) UpdateDeployment(ctx context.Context, dep domain.Deployment) error {
+// QueuePosition walks the FIFO queue, skipping stale entries the way TakeNextQueuedDeployment does.
+func (s *MemoryStore) QueuePosition(ctx context.Context, id string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pos := 0
+	for _, depID := range s.queuedDeploymentIDs {
+		dep, ok := s.deploymentByID[depID]
+		if !ok || dep.Status != domain.DeploymentStatusQueued {
+			continue
+		}
+		pos++
+		if depID == id {
+			return pos, nil
+		}
+	}
+	return 0, nil
+}
+
+// RenewDeploymentLease extends the lease when workerID still holds an in-progress claim.
+func (s *MemoryStore) RenewDeploymentLease(ctx context.Context, id, workerID string, lease time.Duration) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, exists := s.deploymentByID[dep.ID]
+	dep, exists := s.deploymentByID[id]
 	if !exists {
 		return contracts.ErrNotFound
 	}
+	if dep.ClaimedBy == nil || *dep.ClaimedBy != workerID || !dep.Status.InProgress() {
+		return contracts.ErrLeaseLost
+	}
+	now := time.Now().UTC()
+	if dep.LeaseExpiresAt != nil && dep.LeaseExpiresAt.Before(now) {
+		// Too late: the deployment may already be back in the queue.
+		return contracts.ErrLeaseLost
+	}
+
+	expires := now.Add(lease)
+	dep.LeaseExpiresAt = &expires
+	s.deploymentByID[id] = dep
+	return nil
+}
+
+// ListStuckDeployments returns started deployments with no live lease that have not changed since updatedBefore.
+func (s *MemoryStore) ListStuckDeployments(ctx context.Context, updatedBefore time.Time) ([]domain.Deployment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	// Source of truth update only.
+	now := time.Now().UTC()
+	out := make([]domain.Deployment, 0)
+	for _, dep := range s.deploymentByID {
+		if dep.Status != domain.DeploymentStatusBuilding && dep.Status != domain.DeploymentStatusDeploying {
+			continue
+		}
+		if !dep.UpdatedAt.Before(updatedBefore) {
+			continue
+		}
+		if dep.LeaseExpiresAt != nil && !dep.LeaseExpiresAt.Before(now) {
+			// A worker is still heartbeating; slow is not stuck.
+			continue
+		}
+		out = append(out, dep)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// RequeueDeployment stores a requeued deployment and appends it to the queue when the caller read the current version.
+func (s *MemoryStore) RequeueDeployment(ctx context.Context, want domain.Deployment) (domain.Deployment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dep, exists := s.deploymentByID[want.ID]
+	if !exists {
+		return domain.Deployment{}, contracts.ErrNotFound
+	}
+	if dep.Version != want.Version {
+		return domain.Deployment{}, contracts.ErrVersionMismatch
+	}
+	if want.Status != domain.DeploymentStatusQueued ||
+		(dep.Status != domain.DeploymentStatusBuilding && dep.Status != domain.DeploymentStatusDeploying) {
+		return domain.Deployment{}, contracts.ErrConflict
+	}
+
+	// Only what Deployment.Requeue changes is written; the rest stays as stored.
+	dep.Status = want.Status
+	dep.UpdatedAt = want.UpdatedAt
+	dep.StartedAt = want.StartedAt
+	dep.CompletedAt = want.CompletedAt
+	dep.URL = want.URL
+	dep.Error = want.Error
+	dep.Steps = want.Steps
+	dep.RuntimePort = want.RuntimePort
+	dep.ClaimedBy = nil
+	dep.LeaseExpiresAt = nil
+	dep.Version++
 	s.deploymentByID[dep.ID] = dep
+	s.queuedDeploymentIDs = append(s.queuedDeploymentIDs, dep.ID)
+	return dep, nil
+}
+
+// requeueExpiredLocked returns in-progress deployments with an expired lease to the queue.
+// Callers must hold the write lock.
+func (s *MemoryStore) requeueExpiredLocked(now time.Time) {
+	expired := make([]domain.Deployment, 0)
+	for _, dep := range s.deploymentByID {
+		if dep.LeaseExpiresAt == nil || !dep.LeaseExpiresAt.Before(now) || !dep.Status.InProgress() {
+			continue
+		}
+		expired = append(expired, dep)
+	}
+	if len(expired) == 0 {
+		return
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].CreatedAt.Before(expired[j].CreatedAt) })
+
+	ids := make([]string, 0, len(expired)+len(s.queuedDeploymentIDs))
+	for _, dep := range expired {
+		if dep.Status == domain.DeploymentStatusQueued {
+			// Claimed but never started; only the claim goes
+			dep.ClaimedBy = nil
+			dep.LeaseExpiresAt = nil
+		} else if err := dep.Requeue(); err != nil {
+			continue
+		}
+		dep.Version++
+		s.deploymentByID[dep.ID] = dep
+		ids = append(ids, dep.ID)
+	}
+	s.queuedDeploymentIDs = append(ids, s.queuedDeploymentIDs...)
+}
+
+// UpdateDeployment updates the stored deployment source of truth when the caller read the current version.
+// Because our app-history index stores only IDs, only the queue may need updating here.
+func (s *MemoryStore) UpdateDeployment(ctx context.Context, dep domain.Deployment) (domain.Deployment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.deploymentByID[dep.ID]
+	if !exists {
+		return domain.Deployment{}, contracts.ErrNotFound
+	}
+	if current.Version != dep.Version {
+		return domain.Deployment{}, contracts.ErrVersionMismatch
+	}
+
+	// Claim fields belong to the store; keep them from the stored record.
+	dep.ClaimedBy = current.ClaimedBy
+	dep.LeaseExpiresAt = current.LeaseExpiresAt
+	dep.Attempts = current.Attempts
+	dep.Version++
+
+	// Source of truth update, plus the queue entry of a deployment that left QUEUED without a claim.
+	s.deploymentByID[dep.ID] = dep
+	if current.Status == domain.DeploymentStatusQueued && dep.Status != domain.DeploymentStatusQueued {
+		s.dropQueuedLocked(dep.ID)
+	}
+	return dep, nil
+}
+
+// dropQueuedLocked removes id from the queue. Callers must hold the write lock.
+func (s *MemoryStore) dropQueuedLocked(id string) {
+	s.queuedDeploymentIDs = slices.DeleteFunc(s.queuedDeploymentIDs, func(q string) bool { return q == id })
+}
+
+// AppendDeploymentLogs adds lines to the end of a deployment's build log.
+func (s *MemoryStore) AppendDeploymentLogs(ctx context.Context, deploymentID string, lines []domain.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.deploymentByID[deploymentID]; !exists {
+		return contracts.ErrNotFound
+	}
+	s.deploymentLogs[deploymentID] = append(s.deploymentLogs[deploymentID], lines...)
 	return nil
 }
 
+// ListDeploymentLogs returns one page of a deployment's build log.
+func (s *MemoryStore) ListDeploymentLogs(ctx context.Context, deploymentID string, p contracts.Page) ([]domain.LogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]domain.LogEntry{}, paginate(s.deploymentLogs[deploymentID], p)...), nil
+}
+
 // Compile-time check: ensure MemoryStore implements the Store contract.
 var _ contracts.Store = (*MemoryStore)(nil)
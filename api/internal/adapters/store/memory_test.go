@@ -9,14 +9,23 @@ package store_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/adapters/store/storetest"
 	"github.com/t0gun/spacescale/internal/contracts"
 	"github.com/t0gun/spacescale/internal/domain"
 )
 
+// TestMemoryStore_Conformance runs the shared store contract suite.
+func TestMemoryStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) contracts.Store {
+		return store.NewMemoryStore()
+	})
+}
+
 // TestMemoryStore_CreateApp_OK verifies app creation succeeds.
 func TestMemoryStore_CreateApp_OK(t *testing.T) {
 	ctx := context.Background()
@@ -79,7 +88,7 @@ func TestMemoryStore_ListApps_Count(t *testing.T) {
 	assert.NoError(t, st.CreateApp(ctx, a))
 	assert.NoError(t, st.CreateApp(ctx, b))
 
-	apps, err := st.ListApps(ctx)
+	apps, _, err := st.ListApps(ctx, contracts.AppFilter{})
 	assert.NoError(t, err)
 	assert.Len(t, apps, 2)
 }
@@ -133,9 +142,10 @@ func TestMemoryStore_ListDeploymentsByAppID_OrderAndReflectsUpdates(t *testing.T
 	updatedURL := "https://example.com"
 	d1.Status = domain.DeploymentStatusRunning
 	d1.URL = &updatedURL
-	require.NoError(t, st.UpdateDeployment(ctx, d1))
+	_, err = st.UpdateDeployment(ctx, d1)
+	require.NoError(t, err)
 
-	deps, err := st.ListDeploymentsByAppID(ctx, app.ID)
+	deps, _, err := st.ListDeploymentsByAppID(ctx, app.ID, contracts.DeploymentFilter{})
 	require.NoError(t, err)
 	require.Len(t, deps, 2)
 	assert.Equal(t, d1.ID, deps[0].ID)
@@ -150,7 +160,7 @@ func TestMemoryStore_ListDeploymentsByAppID_EmptySlice(t *testing.T) {
 	ctx := context.Background()
 	st := store.NewMemoryStore()
 
-	deps, err := st.ListDeploymentsByAppID(ctx, "missing-app")
+	deps, _, err := st.ListDeploymentsByAppID(ctx, "missing-app", contracts.DeploymentFilter{})
 	assert.NoError(t, err)
 	assert.Empty(t, deps)
 }
@@ -169,15 +179,15 @@ func TestMemoryStore_TakeNextQueuedDeployment_FIFO(t *testing.T) {
 	require.NoError(t, st.CreateDeployment(ctx, first))
 	require.NoError(t, st.CreateDeployment(ctx, second))
 
-	dep, err := st.TakeNextQueuedDeployment(ctx)
+	dep, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
 	require.NoError(t, err)
 	assert.Equal(t, first.ID, dep.ID)
 
-	dep, err = st.TakeNextQueuedDeployment(ctx)
+	dep, err = st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
 	require.NoError(t, err)
 	assert.Equal(t, second.ID, dep.ID)
 
-	_, err = st.TakeNextQueuedDeployment(ctx)
+	_, err = st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
 	assert.ErrorIs(t, err, contracts.ErrNotFound)
 }
 
@@ -196,13 +206,14 @@ func TestMemoryStore_TakeNextQueuedDeployment_SkipsNonQueued(t *testing.T) {
 	require.NoError(t, st.CreateDeployment(ctx, next))
 
 	skip.Status = domain.DeploymentStatusRunning
-	require.NoError(t, st.UpdateDeployment(ctx, skip))
+	_, err = st.UpdateDeployment(ctx, skip)
+	require.NoError(t, err)
 
-	dep, err := st.TakeNextQueuedDeployment(ctx)
+	dep, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
 	require.NoError(t, err)
 	assert.Equal(t, next.ID, dep.ID)
 
-	_, err = st.TakeNextQueuedDeployment(ctx)
+	_, err = st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
 	assert.ErrorIs(t, err, contracts.ErrNotFound)
 }
 
@@ -213,7 +224,7 @@ func TestMemoryStore_UpdateDeployment_NotFound(t *testing.T) {
 
 	dep := domain.NewDeployment("missing-app")
 
-	err := st.UpdateDeployment(ctx, dep)
+	_, err := st.UpdateDeployment(ctx, dep)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, contracts.ErrNotFound)
 }
@@ -0,0 +1,199 @@
+// Package migrations ships the versioned database schema inside the binary.
+// SQL files live in sql/ and are named <version>_<name>.sql.
+// Applied versions are tracked in the schema_migrations table.
+// Migrations only run forward; each one runs in its own transaction.
+// A database newer than the binary is refused so old code never touches a newer schema.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey is the advisory lock id that serializes migration runs across replicas.
+const lockKey int64 = 0x5370_6163_6553_63 // "SpaceSc"
+
+// Migration errors returned by Up and Check.
+var (
+	ErrDatabaseAhead = errors.New("database schema is newer than this binary")
+	ErrPending       = errors.New("database schema has pending migrations")
+)
+
+// Migration is one embedded schema change.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// All returns the embedded migrations sorted by version.
+func All() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Migration, 0, len(entries))
+	seen := make(map[int]string, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		version, name, err := parseFileName(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations: duplicate version %d (%s, %s)", version, prev, e.Name())
+		}
+		seen[version] = e.Name()
+
+		body, err := fs.ReadFile(files, path.Join("sql", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Migration{Version: version, Name: name, SQL: string(body)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Latest returns the highest version known to this binary.
+func Latest() (int, error) {
+	all, err := All()
+	if err != nil {
+		return 0, err
+	}
+	if len(all) == 0 {
+		return 0, nil
+	}
+	return all[len(all)-1].Version, nil
+}
+
+// Up applies every migration newer than the database version and returns how many ran.
+// It returns ErrDatabaseAhead when the database was migrated by a newer binary.
+func Up(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	all, err := All()
+	if err != nil {
+		return 0, err
+	}
+
+	// Pin one connection so the session advisory lock covers every step.
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return 0, fmt.Errorf("migrations: lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockKey)
+	}()
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+		return 0, fmt.Errorf("migrations: create table: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return 0, fmt.Errorf("migrations: read version: %w", err)
+	}
+	if err := compare(current, all); err != nil && !errors.Is(err, ErrPending) {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range all {
+		if m.Version <= current {
+			continue
+		}
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return applied, err
+		}
+		// No arguments means pgx uses the simple protocol, which allows multiple statements.
+		if _, err := tx.Exec(ctx, m.SQL); err != nil {
+			_ = tx.Rollback(ctx)
+			return applied, fmt.Errorf("migrations: apply %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+			_ = tx.Rollback(ctx)
+			return applied, fmt.Errorf("migrations: record %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return applied, fmt.Errorf("migrations: commit %04d_%s: %w", m.Version, m.Name, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// Check verifies the database is exactly at the version this binary expects.
+// It returns ErrDatabaseAhead or ErrPending without changing anything.
+func Check(ctx context.Context, pool *pgxpool.Pool) error {
+	all, err := All()
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return fmt.Errorf("migrations: read version: %w", err)
+	}
+	current := 0
+	if exists {
+		if err := pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+			return fmt.Errorf("migrations: read version: %w", err)
+		}
+	}
+	return compare(current, all)
+}
+
+// compare reports how the database version relates to the embedded migrations.
+func compare(current int, all []Migration) error {
+	latest := 0
+	if len(all) > 0 {
+		latest = all[len(all)-1].Version
+	}
+	switch {
+	case current > latest:
+		return fmt.Errorf("%w: database at %d, binary knows %d", ErrDatabaseAhead, current, latest)
+	case current < latest:
+		return fmt.Errorf("%w: database at %d, binary knows %d", ErrPending, current, latest)
+	default:
+		return nil
+	}
+}
+
+// parseFileName splits "0001_init.sql" into its version and name.
+func parseFileName(file string) (int, string, error) {
+	base := strings.TrimSuffix(file, ".sql")
+	prefix, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", fmt.Errorf("migrations: bad file name %q, want <version>_<name>.sql", file)
+	}
+	version, err := strconv.Atoi(prefix)
+	if err != nil || version < 1 {
+		return 0, "", fmt.Errorf("migrations: bad version in %q", file)
+	}
+	return version, name, nil
+}
@@ -0,0 +1,60 @@
+// Tests for embedded schema migrations
+// Tests check file naming and version ordering
+// Database tests run when TEST_DATABASE_URL is set
+// Tests verify repeated runs are no-ops
+// These tests keep the schema history consistent
+
+package migrations_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store/migrations"
+)
+
+// TestAll_Ordered verifies embedded migrations parse and are strictly increasing.
+func TestAll_Ordered(t *testing.T) {
+	all, err := migrations.All()
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	for i, m := range all {
+		assert.NotEmpty(t, m.Name)
+		assert.NotEmpty(t, m.SQL)
+		if i > 0 {
+			assert.Greater(t, m.Version, all[i-1].Version)
+		}
+	}
+
+	latest, err := migrations.Latest()
+	require.NoError(t, err)
+	assert.Equal(t, all[len(all)-1].Version, latest)
+}
+
+// TestUp_Idempotent applies migrations twice against a real database.
+func TestUp_Idempotent(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("set TEST_DATABASE_URL to run migration tests")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, url)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	_, err = migrations.Up(ctx, pool)
+	require.NoError(t, err)
+
+	applied, err := migrations.Up(ctx, pool)
+	require.NoError(t, err)
+	assert.Equal(t, 0, applied)
+	assert.NoError(t, migrations.Check(ctx, pool))
+}
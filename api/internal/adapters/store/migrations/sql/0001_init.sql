@@ -0,0 +1,89 @@
+-- Initial schema derived from db/erd/erd-v0-image-only.drawio.
+-- Ids are text so they round trip the uuid strings the domain generates.
+-- Projects are not modelled in the domain yet, so apps.project_id is nullable.
+
+CREATE TABLE users (
+    id          TEXT PRIMARY KEY,
+    github_id   BIGINT NOT NULL UNIQUE,
+    email       TEXT NOT NULL,
+    name        TEXT,
+    avatar_url  TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE projects (
+    id             TEXT PRIMARY KEY,
+    owner_user_id  TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name           TEXT NOT NULL,
+    slug           TEXT NOT NULL UNIQUE,
+    region         TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE apps (
+    id            TEXT PRIMARY KEY,
+    project_id    TEXT REFERENCES projects (id) ON DELETE CASCADE,
+    name          TEXT NOT NULL UNIQUE,
+    slug          TEXT,
+    subdomain     TEXT,
+    image_ref     TEXT NOT NULL,
+    runtime_port  INTEGER CHECK (runtime_port BETWEEN 1 AND 65535),
+    expose        BOOLEAN NOT NULL DEFAULT TRUE,
+    env           JSONB,
+    status        TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (project_id, slug),
+    UNIQUE (project_id, subdomain)
+);
+
+CREATE TABLE deployments (
+    id             TEXT PRIMARY KEY,
+    -- seq breaks ties between deployments created in the same microsecond.
+    seq            BIGINT GENERATED ALWAYS AS IDENTITY UNIQUE,
+    app_id         TEXT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    status         TEXT NOT NULL,
+    image_ref      TEXT,
+    runtime_port   INTEGER,
+    public_url     TEXT,
+    error_message  TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX deployments_app_id_idx ON deployments (app_id, created_at, seq);
+CREATE INDEX deployments_queue_idx ON deployments (created_at, seq)
+    WHERE status = 'QUEUED';
+
+CREATE TABLE app_env_vars (
+    id               TEXT PRIMARY KEY,
+    app_id           TEXT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    key              TEXT NOT NULL,
+    value_encrypted  BYTEA NOT NULL,
+    is_secret        BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (app_id, key)
+);
+
+CREATE TABLE registry_credentials (
+    id               TEXT PRIMARY KEY,
+    project_id       TEXT NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name             TEXT NOT NULL,
+    registry_url     TEXT NOT NULL,
+    username         TEXT NOT NULL,
+    token_encrypted  BYTEA NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used        TIMESTAMPTZ
+);
+
+CREATE TABLE app_registry_credentials (
+    app_id                  TEXT NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    registry_credential_id  TEXT NOT NULL REFERENCES registry_credentials (id) ON DELETE CASCADE,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used               TIMESTAMPTZ,
+    PRIMARY KEY (app_id, registry_credential_id)
+);
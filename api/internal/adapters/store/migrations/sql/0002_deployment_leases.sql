@@ -0,0 +1,13 @@
+-- Deployment claims are leases so several workers can share the queue.
+-- claimed_by/lease_expires_at mark a taken row; an expired lease puts it back in the queue.
+
+ALTER TABLE deployments
+    ADD COLUMN claimed_by TEXT,
+    ADD COLUMN lease_expires_at TIMESTAMPTZ,
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+
+DROP INDEX deployments_queue_idx;
+CREATE INDEX deployments_queue_idx ON deployments (created_at, seq)
+    WHERE status = 'QUEUED' AND claimed_by IS NULL;
+CREATE INDEX deployments_lease_idx ON deployments (lease_expires_at)
+    WHERE claimed_by IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Indexes backing the sorted, filtered app and deployment lists.
+
+CREATE INDEX apps_created_at_idx ON apps (created_at, id);
+CREATE INDEX apps_status_idx ON apps (status, created_at, id);
+CREATE INDEX deployments_app_status_idx ON deployments (app_id, status, created_at, seq);
@@ -0,0 +1,5 @@
+-- Record versions for optimistic concurrency. Every update must name the version it read
+-- and bumps it by one, so a writer holding a stale copy is refused instead of overwriting.
+
+ALTER TABLE apps ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE deployments ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+-- When a deployment attempt started and when it reached a final state.
+
+ALTER TABLE deployments
+    ADD COLUMN started_at   TIMESTAMPTZ,
+    ADD COLUMN completed_at TIMESTAMPTZ;
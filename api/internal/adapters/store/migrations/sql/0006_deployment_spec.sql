@@ -0,0 +1,15 @@
+-- Deployments keep the spec they were requested with, so edits to the app
+-- while a deployment waits in the queue do not change what it runs.
+-- Existing deployments get the current app settings; their env hash stays empty.
+
+ALTER TABLE deployments
+    ADD COLUMN expose   BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN env      JSONB,
+    ADD COLUMN env_hash TEXT NOT NULL DEFAULT '';
+
+UPDATE deployments d
+SET image_ref = a.image_ref, runtime_port = a.runtime_port, expose = a.expose, env = a.env
+FROM apps a
+WHERE d.app_id = a.id AND d.image_ref IS NULL;
+
+ALTER TABLE deployments ALTER COLUMN image_ref SET NOT NULL;
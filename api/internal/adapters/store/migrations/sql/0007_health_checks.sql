@@ -0,0 +1,6 @@
+-- Apps may configure a health check that gates a deployment going RUNNING.
+-- Deployments freeze the check with the rest of their spec.
+-- NULL means the image's own HEALTHCHECK, if any, decides.
+
+ALTER TABLE apps ADD COLUMN health_check JSONB;
+ALTER TABLE deployments ADD COLUMN health_check JSONB;
@@ -0,0 +1,4 @@
+-- A rollback deployment records the earlier deployment whose spec it redeploys.
+-- The link is cleared rather than blocking when that deployment is deleted.
+
+ALTER TABLE deployments ADD COLUMN rollback_of TEXT REFERENCES deployments (id) ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+-- A retried deployment records the FAILED or CANCELED deployment it was cloned from.
+-- The link is cleared rather than blocking when that deployment is deleted.
+
+ALTER TABLE deployments ADD COLUMN retry_of TEXT REFERENCES deployments (id) ON DELETE SET NULL;
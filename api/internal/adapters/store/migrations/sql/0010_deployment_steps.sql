@@ -0,0 +1,4 @@
+-- Deploy steps with their status, timings and error, in run order.
+-- Deployments made before steps were tracked have an empty list.
+
+ALTER TABLE deployments ADD COLUMN steps JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,13 @@
+-- Build log lines a deployment's worker reported, such as image pull progress.
+-- seq keeps lines in the order they were appended; they go away with their deployment.
+
+CREATE TABLE deployment_logs (
+    seq            BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    deployment_id  TEXT NOT NULL REFERENCES deployments (id) ON DELETE CASCADE,
+    logged_at      TIMESTAMPTZ NOT NULL,
+    level          TEXT NOT NULL,
+    source         TEXT NOT NULL,
+    message        TEXT NOT NULL
+);
+
+CREATE INDEX deployment_logs_deployment_idx ON deployment_logs (deployment_id, seq);
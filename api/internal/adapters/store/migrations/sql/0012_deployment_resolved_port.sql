@@ -0,0 +1,4 @@
+-- The port the runtime routed to, once a deploy has worked it out.
+-- runtime_port keeps the spec's port, which is null when the image supplies it.
+
+ALTER TABLE deployments ADD COLUMN resolved_port INTEGER;
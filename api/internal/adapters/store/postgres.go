@@ -0,0 +1,596 @@
+// PostgreSQL store adapter for apps and deployments.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// PostgreSQL error codes we translate into contract errors.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+// PostgresStore is a PostgreSQL implementation of contracts.Store.
+// It expects the schema from the migrations package to be applied.
+//
+//   - Unique violations map to contracts.ErrConflict.
+//   - Missing rows and missing foreign keys map to contracts.ErrNotFound.
+//   - Timestamps are normalized to UTC on read so records match the domain.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStore returns a store backed by the given connection pool.
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+const appColumns = `id, name, image_ref, runtime_port, expose, env, health_check, status, version, created_at, updated_at`
+
+const deploymentColumns = `id, app_id, image_ref, runtime_port, expose, env, env_hash, health_check, rollback_of, retry_of, steps, resolved_port, status, public_url, error_message, claimed_by, lease_expires_at, attempts, version, created_at, updated_at, started_at, completed_at`
+
+// CreateApp inserts a new app and enforces unique names.
+func (s *PostgresStore) CreateApp(ctx context.Context, app domain.App) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO apps (`+appColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		app.ID, app.Name, app.Image, app.Port, app.Expose, app.Env, toHealthCheckDoc(app.HealthCheck),
+		app.Status, app.Version, app.CreatedAt, app.UpdatedAt,
+	)
+	return mapPgErr(err)
+}
+
+// GetAppByID returns an app by its id.
+func (s *PostgresStore) GetAppByID(ctx context.Context, id string) (domain.App, error) {
+	row := s.pool.QueryRow(ctx, `SELECT `+appColumns+` FROM apps WHERE id = $1`, id)
+	return scanApp(row)
+}
+
+// GetAppByName returns an app by its name.
+func (s *PostgresStore) GetAppByName(ctx context.Context, name string) (domain.App, error) {
+	row := s.pool.QueryRow(ctx, `SELECT `+appColumns+` FROM apps WHERE name = $1`, name)
+	return scanApp(row)
+}
+
+// ListApps returns one page of matching apps sorted by creation time then id.
+// Empty filter values match everything; a zero limit becomes LIMIT NULL, which means no limit.
+func (s *PostgresStore) ListApps(ctx context.Context, f contracts.AppFilter) ([]domain.App, int, error) {
+	const where = `WHERE ($1 = '' OR status = $1) AND starts_with(name, $2)`
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM apps `+where, f.Status, f.NamePrefix).Scan(&total); err != nil {
+		return nil, 0, mapPgErr(err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+appColumns+` FROM apps `+where+`
+		ORDER BY created_at, id
+		LIMIT NULLIF($3, 0) OFFSET $4`,
+		f.Status, f.NamePrefix, f.Limit, f.Offset,
+	)
+	if err != nil {
+		return nil, 0, mapPgErr(err)
+	}
+	defer rows.Close()
+
+	out := make([]domain.App, 0)
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, mapPgErr(err)
+	}
+	return out, total, nil
+}
+
+// UpdateApp replaces an app when app.Version still matches the stored row.
+// Renames are checked by the unique name constraint.
+func (s *PostgresStore) UpdateApp(ctx context.Context, app domain.App) (domain.App, error) {
+	row := s.pool.QueryRow(ctx, `
+		UPDATE apps
+		SET name = $2, image_ref = $3, runtime_port = $4, expose = $5, env = $6, status = $7,
+			updated_at = $8, health_check = $10, version = version + 1
+		WHERE id = $1 AND version = $9
+		RETURNING `+appColumns,
+		app.ID, app.Name, app.Image, app.Port, app.Expose, app.Env, app.Status, app.UpdatedAt, app.Version,
+		toHealthCheckDoc(app.HealthCheck),
+	)
+	updated, err := scanApp(row)
+	if !errors.Is(err, contracts.ErrNotFound) {
+		return updated, err
+	}
+	// No row matched: tell a missing app apart from a stale version.
+	if _, err := s.GetAppByID(ctx, app.ID); err != nil {
+		return domain.App{}, err
+	}
+	return domain.App{}, contracts.ErrVersionMismatch
+}
+
+// UpdateAppStatus sets an app's status and bumps its version unless the app is being deleted.
+func (s *PostgresStore) UpdateAppStatus(ctx context.Context, id string, status domain.AppStatus) (domain.App, error) {
+	row := s.pool.QueryRow(ctx, `
+		UPDATE apps
+		SET status = $2, updated_at = now(), version = version + 1
+		WHERE id = $1 AND status <> $3
+		RETURNING `+appColumns,
+		id, status, domain.AppStatusDeleting,
+	)
+	updated, err := scanApp(row)
+	if !errors.Is(err, contracts.ErrNotFound) {
+		return updated, err
+	}
+	// No row matched: the app is missing or being deleted.
+	return s.GetAppByID(ctx, id)
+}
+
+// DeleteApp deletes an app; its deployments go with it through ON DELETE CASCADE.
+func (s *PostgresStore) DeleteApp(ctx context.Context, id string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM apps WHERE id = $1`, id)
+	if err != nil {
+		return mapPgErr(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return contracts.ErrNotFound
+	}
+	return nil
+}
+
+// CreateDeployment inserts a deployment for an existing app.
+// Queued deployments become visible to TakeNextQueuedDeployment right away.
+func (s *PostgresStore) CreateDeployment(ctx context.Context, dep domain.Deployment) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO deployments (id, app_id, image_ref, runtime_port, expose, env, env_hash, health_check, rollback_of,
+			retry_of, steps, resolved_port, status, public_url, error_message, version, created_at, updated_at, started_at,
+			completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`,
+		dep.ID, dep.AppID, dep.Spec.Image, dep.Spec.Port, dep.Spec.Expose, dep.Spec.Env, dep.Spec.EnvHash,
+		toHealthCheckDoc(dep.Spec.HealthCheck), dep.RollbackOf, dep.RetryOf, toStepDocs(dep.Steps), dep.RuntimePort,
+		dep.Status, dep.URL, dep.Error, dep.Version, dep.CreatedAt, dep.UpdatedAt, dep.StartedAt, dep.CompletedAt,
+	)
+	return mapPgErr(err)
+}
+
+// GetDeploymentByID returns a deployment by its id.
+func (s *PostgresStore) GetDeploymentByID(ctx context.Context, id string) (domain.Deployment, error) {
+	row := s.pool.QueryRow(ctx, `SELECT `+deploymentColumns+` FROM deployments WHERE id = $1`, id)
+	return scanDeployment(row)
+}
+
+// ListDeploymentsByAppID returns one page of an app's matching deployments in create order.
+func (s *PostgresStore) ListDeploymentsByAppID(ctx context.Context, appID string, f contracts.DeploymentFilter) ([]domain.Deployment, int, error) {
+	const where = `WHERE app_id = $1 AND ($2 = '' OR status = $2)`
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM deployments `+where, appID, f.Status).Scan(&total); err != nil {
+		return nil, 0, mapPgErr(err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+deploymentColumns+` FROM deployments `+where+`
+		ORDER BY created_at, seq
+		LIMIT NULLIF($3, 0) OFFSET $4`,
+		appID, f.Status, f.Limit, f.Offset,
+	)
+	if err != nil {
+		return nil, 0, mapPgErr(err)
+	}
+	defer rows.Close()
+
+	out := make([]domain.Deployment, 0)
+	for rows.Next() {
+		dep, err := scanDeployment(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, dep)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, mapPgErr(err)
+	}
+	return out, total, nil
+}
+
+// LatestDeployments returns the newest deployment of each app in one query.
+func (s *PostgresStore) LatestDeployments(ctx context.Context, appIDs []string) (map[string]domain.Deployment, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ON (app_id) `+deploymentColumns+` FROM deployments
+		WHERE app_id = ANY($1)
+		ORDER BY app_id, created_at DESC, seq DESC`,
+		appIDs,
+	)
+	if err != nil {
+		return nil, mapPgErr(err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]domain.Deployment, len(appIDs))
+	for rows.Next() {
+		dep, err := scanDeployment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[dep.AppID] = dep
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapPgErr(err)
+	}
+	return out, nil
+}
+
+// TakeNextQueuedDeployment claims the oldest claimable deployment for workerID.
+// A row is claimable when it is QUEUED and unclaimed, or when it is still in progress but its
+// lease has run out; the latter is requeued first so the caller starts it over.
+// FOR UPDATE SKIP LOCKED lets concurrent workers claim different rows without blocking, and
+// lease times use the database clock so replicas never disagree about expiry.
+func (s *PostgresStore) TakeNextQueuedDeployment(ctx context.Context, workerID string, lease time.Duration) (domain.Deployment, error) {
+	var claimed domain.Deployment
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		dep, err := scanDeployment(tx.QueryRow(ctx, `
+			SELECT `+deploymentColumns+` FROM deployments
+			WHERE (status = $1 AND claimed_by IS NULL)
+				OR (status IN ($1, $2, $3) AND lease_expires_at < now())
+			ORDER BY created_at, seq
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED`,
+			domain.DeploymentStatusQueued, domain.DeploymentStatusBuilding, domain.DeploymentStatusDeploying,
+		))
+		if err != nil {
+			return err
+		}
+		if dep.Status != domain.DeploymentStatusQueued {
+			if err := dep.Requeue(); err != nil {
+				return err
+			}
+		}
+		claimed, err = scanDeployment(tx.QueryRow(ctx, `
+			UPDATE deployments
+			SET status = $2, public_url = $3, error_message = $4, updated_at = $5,
+				started_at = $6, completed_at = $7, steps = $8,
+				claimed_by = $9,
+				lease_expires_at = now() + make_interval(secs => $10),
+				attempts = attempts + 1,
+				version = version + 1
+			WHERE id = $1
+			RETURNING `+deploymentColumns,
+			dep.ID, dep.Status, dep.URL, dep.Error, dep.UpdatedAt, dep.StartedAt, dep.CompletedAt,
+			toStepDocs(dep.Steps), workerID, lease.Seconds(),
+		))
+		return err
+	})
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	return claimed, nil
+}
+
+// QueuePosition counts the unclaimed QUEUED deployments ahead of id in claim order, plus id itself.
+func (s *PostgresStore) QueuePosition(ctx context.Context, id string) (int, error) {
+	var pos int
+	err := s.pool.QueryRow(ctx, `
+		SELECT count(*) FROM deployments q
+		JOIN deployments d ON d.id = $1 AND d.status = $2 AND d.claimed_by IS NULL
+		WHERE q.status = $2 AND q.claimed_by IS NULL AND (q.created_at, q.seq) <= (d.created_at, d.seq)`,
+		id, domain.DeploymentStatusQueued,
+	).Scan(&pos)
+	return pos, mapPgErr(err)
+}
+
+// RenewDeploymentLease extends the lease when workerID still holds an unexpired in-progress claim.
+func (s *PostgresStore) RenewDeploymentLease(ctx context.Context, id, workerID string, lease time.Duration) error {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE deployments
+		SET lease_expires_at = now() + make_interval(secs => $3)
+		WHERE id = $1 AND claimed_by = $2 AND lease_expires_at >= now() AND status IN ($4, $5, $6)`,
+		id, workerID, lease.Seconds(),
+		domain.DeploymentStatusQueued, domain.DeploymentStatusBuilding, domain.DeploymentStatusDeploying,
+	)
+	if err != nil {
+		return mapPgErr(err)
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+	// Tell a missing deployment apart from a claim held by someone else.
+	if _, err := s.GetDeploymentByID(ctx, id); err != nil {
+		return err
+	}
+	return contracts.ErrLeaseLost
+}
+
+// ListStuckDeployments returns started deployments with no live lease that have not changed since updatedBefore.
+func (s *PostgresStore) ListStuckDeployments(ctx context.Context, updatedBefore time.Time) ([]domain.Deployment, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+deploymentColumns+` FROM deployments
+		WHERE status IN ($1, $2)
+			AND updated_at < $3
+			AND (lease_expires_at IS NULL OR lease_expires_at < now())
+		ORDER BY created_at, seq`,
+		domain.DeploymentStatusBuilding, domain.DeploymentStatusDeploying, updatedBefore,
+	)
+	if err != nil {
+		return nil, mapPgErr(err)
+	}
+	defer rows.Close()
+
+	out := make([]domain.Deployment, 0)
+	for rows.Next() {
+		dep, err := scanDeployment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, dep)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapPgErr(err)
+	}
+	return out, nil
+}
+
+// RequeueDeployment stores a requeued deployment and drops its claim when dep.Version
+// still matches the stored row, so a row reclaimed since it was read is left to its new holder.
+func (s *PostgresStore) RequeueDeployment(ctx context.Context, dep domain.Deployment) (domain.Deployment, error) {
+	if dep.Status != domain.DeploymentStatusQueued {
+		return domain.Deployment{}, contracts.ErrConflict
+	}
+	row := s.pool.QueryRow(ctx, `
+		UPDATE deployments
+		SET status = $3, public_url = $4, error_message = $5, updated_at = $6,
+			started_at = $7, completed_at = $8, steps = $9, resolved_port = NULL,
+			claimed_by = NULL, lease_expires_at = NULL, version = version + 1
+		WHERE id = $1 AND version = $2 AND status IN ($10, $11)
+		RETURNING `+deploymentColumns,
+		dep.ID, dep.Version, dep.Status, dep.URL, dep.Error, dep.UpdatedAt, dep.StartedAt, dep.CompletedAt,
+		toStepDocs(dep.Steps), domain.DeploymentStatusBuilding, domain.DeploymentStatusDeploying,
+	)
+	requeued, err := scanDeployment(row)
+	if !errors.Is(err, contracts.ErrNotFound) {
+		return requeued, err
+	}
+	current, err := s.GetDeploymentByID(ctx, dep.ID)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	if current.Version != dep.Version {
+		return domain.Deployment{}, contracts.ErrVersionMismatch
+	}
+	return domain.Deployment{}, contracts.ErrConflict
+}
+
+// UpdateDeployment overwrites the mutable fields of a deployment when dep.Version still matches the stored row.
+func (s *PostgresStore) UpdateDeployment(ctx context.Context, dep domain.Deployment) (domain.Deployment, error) {
+	row := s.pool.QueryRow(ctx, `
+		UPDATE deployments
+		SET status = $2, public_url = $3, error_message = $4, updated_at = $5,
+			started_at = $7, completed_at = $8, steps = $9, resolved_port = $10, version = version + 1
+		WHERE id = $1 AND version = $6
+		RETURNING `+deploymentColumns,
+		dep.ID, dep.Status, dep.URL, dep.Error, dep.UpdatedAt, dep.Version, dep.StartedAt, dep.CompletedAt,
+		toStepDocs(dep.Steps), dep.RuntimePort,
+	)
+	updated, err := scanDeployment(row)
+	if !errors.Is(err, contracts.ErrNotFound) {
+		return updated, err
+	}
+	if _, err := s.GetDeploymentByID(ctx, dep.ID); err != nil {
+		return domain.Deployment{}, err
+	}
+	return domain.Deployment{}, contracts.ErrVersionMismatch
+}
+
+// AppendDeploymentLogs adds lines to the end of a deployment's build log in one statement.
+func (s *PostgresStore) AppendDeploymentLogs(ctx context.Context, deploymentID string, lines []domain.LogEntry) error {
+	if len(lines) == 0 {
+		if _, err := s.GetDeploymentByID(ctx, deploymentID); err != nil {
+			return err
+		}
+		return nil
+	}
+	at := make([]time.Time, len(lines))
+	levels := make([]string, len(lines))
+	sources := make([]string, len(lines))
+	messages := make([]string, len(lines))
+	for i, l := range lines {
+		at[i], levels[i], sources[i], messages[i] = l.Timestamp, string(l.Level), l.Source, l.Message
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO deployment_logs (deployment_id, logged_at, level, source, message)
+		SELECT $1, l.logged_at, l.level, l.source, l.message
+		FROM unnest($2::timestamptz[], $3::text[], $4::text[], $5::text[]) WITH ORDINALITY
+			AS l (logged_at, level, source, message, n)
+		ORDER BY l.n`,
+		deploymentID, at, levels, sources, messages,
+	)
+	return mapPgErr(err)
+}
+
+// ListDeploymentLogs returns one page of a deployment's build log.
+func (s *PostgresStore) ListDeploymentLogs(ctx context.Context, deploymentID string, p contracts.Page) ([]domain.LogEntry, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT logged_at, level, source, message FROM deployment_logs
+		WHERE deployment_id = $1
+		ORDER BY seq
+		LIMIT NULLIF($2, 0) OFFSET $3`,
+		deploymentID, p.Limit, p.Offset,
+	)
+	if err != nil {
+		return nil, mapPgErr(err)
+	}
+	defer rows.Close()
+
+	out := make([]domain.LogEntry, 0)
+	for rows.Next() {
+		var l domain.LogEntry
+		if err := rows.Scan(&l.Timestamp, &l.Level, &l.Source, &l.Message); err != nil {
+			return nil, mapPgErr(err)
+		}
+		l.Timestamp = l.Timestamp.UTC()
+		out = append(out, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapPgErr(err)
+	}
+	return out, nil
+}
+
+// scanApp reads one app row in appColumns order.
+func scanApp(row pgx.Row) (domain.App, error) {
+	var a domain.App
+	var hc *healthCheckDoc
+	err := row.Scan(&a.ID, &a.Name, &a.Image, &a.Port, &a.Expose, &a.Env, &hc, &a.Status, &a.Version, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return domain.App{}, mapPgErr(err)
+	}
+	a.HealthCheck = hc.toDomain()
+	a.CreatedAt = a.CreatedAt.UTC()
+	a.UpdatedAt = a.UpdatedAt.UTC()
+	return a, nil
+}
+
+// scanDeployment reads one deployment row in deploymentColumns order.
+func scanDeployment(row pgx.Row) (domain.Deployment, error) {
+	var d domain.Deployment
+	var hc *healthCheckDoc
+	var steps []stepDoc
+	err := row.Scan(
+		&d.ID, &d.AppID, &d.Spec.Image, &d.Spec.Port, &d.Spec.Expose, &d.Spec.Env, &d.Spec.EnvHash, &hc, &d.RollbackOf, &d.RetryOf,
+		&steps, &d.RuntimePort,
+		&d.Status, &d.URL, &d.Error, &d.ClaimedBy, &d.LeaseExpiresAt, &d.Attempts, &d.Version,
+		&d.CreatedAt, &d.UpdatedAt, &d.StartedAt, &d.CompletedAt,
+	)
+	if err != nil {
+		return domain.Deployment{}, mapPgErr(err)
+	}
+	d.Spec.HealthCheck = hc.toDomain()
+	d.Steps = stepsToDomain(steps)
+	d.LeaseExpiresAt = utcPtr(d.LeaseExpiresAt)
+	d.StartedAt = utcPtr(d.StartedAt)
+	d.CompletedAt = utcPtr(d.CompletedAt)
+	d.CreatedAt = d.CreatedAt.UTC()
+	d.UpdatedAt = d.UpdatedAt.UTC()
+	return d, nil
+}
+
+// healthCheckDoc is the JSONB form of a health check; its tags keep the stored shape
+// independent of Go field names. Durations are stored in milliseconds.
+type healthCheckDoc struct {
+	Type       domain.HealthCheckType `json:"type"`
+	Path       string                 `json:"path,omitempty"`
+	Command    []string               `json:"command,omitempty"`
+	IntervalMS int64                  `json:"intervalMs"`
+	TimeoutMS  int64                  `json:"timeoutMs"`
+	Retries    int                    `json:"retries"`
+}
+
+// toHealthCheckDoc converts an optional health check for storage; nil stores NULL.
+func toHealthCheckDoc(hc *domain.HealthCheck) *healthCheckDoc {
+	if hc == nil {
+		return nil
+	}
+	return &healthCheckDoc{
+		Type:       hc.Type,
+		Path:       hc.Path,
+		Command:    hc.Command,
+		IntervalMS: hc.Interval.Milliseconds(),
+		TimeoutMS:  hc.Timeout.Milliseconds(),
+		Retries:    hc.Retries,
+	}
+}
+
+// toDomain converts a stored health check back; a NULL column gives nil.
+func (d *healthCheckDoc) toDomain() *domain.HealthCheck {
+	if d == nil {
+		return nil
+	}
+	return &domain.HealthCheck{
+		Type:     d.Type,
+		Path:     d.Path,
+		Command:  d.Command,
+		Interval: time.Duration(d.IntervalMS) * time.Millisecond,
+		Timeout:  time.Duration(d.TimeoutMS) * time.Millisecond,
+		Retries:  d.Retries,
+	}
+}
+
+// stepDoc is the JSONB form of one deploy step.
+type stepDoc struct {
+	ID          domain.StepID     `json:"id"`
+	Name        string            `json:"name"`
+	Status      domain.StepStatus `json:"status"`
+	StartedAt   *time.Time        `json:"startedAt,omitempty"`
+	CompletedAt *time.Time        `json:"completedAt,omitempty"`
+	Error       *string           `json:"error,omitempty"`
+}
+
+// toStepDocs converts deploy steps for storage; no steps store an empty list.
+func toStepDocs(steps []domain.DeploymentStep) []stepDoc {
+	out := make([]stepDoc, 0, len(steps))
+	for _, st := range steps {
+		out = append(out, stepDoc(st))
+	}
+	return out
+}
+
+// stepsToDomain converts stored steps back; an empty list gives nil.
+func stepsToDomain(docs []stepDoc) []domain.DeploymentStep {
+	if len(docs) == 0 {
+		return nil
+	}
+	out := make([]domain.DeploymentStep, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, domain.DeploymentStep{
+			ID:          d.ID,
+			Name:        d.Name,
+			Status:      d.Status,
+			StartedAt:   utcPtr(d.StartedAt),
+			CompletedAt: utcPtr(d.CompletedAt),
+			Error:       d.Error,
+		})
+	}
+	return out
+}
+
+// utcPtr returns a UTC copy of an optional timestamp.
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// mapPgErr translates driver errors into contract errors.
+// Unknown errors are returned unchanged so callers can still inspect them.
+func mapPgErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return contracts.ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return contracts.ErrConflict
+		case pgForeignKeyViolation:
+			return contracts.ErrNotFound
+		}
+	}
+	return err
+}
+
+// Compile-time check: ensure PostgresStore implements the Store contract.
+var _ contracts.Store = (*PostgresStore)(nil)
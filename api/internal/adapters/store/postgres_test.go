@@ -0,0 +1,93 @@
+// Tests for the postgres store adapter
+// Tests run against a real database when TEST_DATABASE_URL is set
+// The database is migrated once and emptied before every subtest
+// The shared conformance suite covers the store contract
+// These tests keep the postgres store in line with the memory store
+
+package store_test
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/adapters/store/migrations"
+	"github.com/t0gun/spacescale/internal/adapters/store/storetest"
+	"github.com/t0gun/spacescale/internal/contracts"
+)
+
+// TestPostgresStore_Conformance runs the shared store contract suite against postgres.
+func TestPostgresStore_Conformance(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("set TEST_DATABASE_URL to run postgres store tests")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, url)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	_, err = migrations.Up(ctx, pool)
+	require.NoError(t, err)
+
+	storetest.Run(t, func(t *testing.T) contracts.Store {
+		_, err := pool.Exec(context.Background(), `TRUNCATE deployments, apps CASCADE`)
+		require.NoError(t, err)
+		return store.NewPostgresStore(pool)
+	})
+}
+
+// TestPostgresStore_ColumnsInSchema checks every column the store queries is created by the embedded migrations.
+// It needs no database, so a query that outruns the schema fails even when the conformance suite is skipped.
+func TestPostgresStore_ColumnsInSchema(t *testing.T) {
+	all, err := migrations.All()
+	require.NoError(t, err)
+
+	createTable := regexp.MustCompile(`(?is)CREATE TABLE (\w+) \((.*)\)`)
+	alterTable := regexp.MustCompile(`(?i)ALTER TABLE (\w+)`)
+	addColumn := regexp.MustCompile(`(?i)ADD COLUMN (\w+)`)
+	comment := regexp.MustCompile(`--[^\n]*`)
+
+	schema := map[string]map[string]bool{}
+	add := func(table, column string) {
+		if schema[table] == nil {
+			schema[table] = map[string]bool{}
+		}
+		schema[table][strings.ToLower(column)] = true
+	}
+	for _, m := range all {
+		for _, stmt := range strings.Split(comment.ReplaceAllString(m.SQL, ""), ";") {
+			if c := createTable.FindStringSubmatch(stmt); c != nil {
+				for _, line := range strings.Split(c[2], "\n") {
+					fields := strings.Fields(line)
+					if len(fields) < 2 || strings.ToUpper(fields[0]) == "UNIQUE" || strings.ToUpper(fields[0]) == "PRIMARY" {
+						continue
+					}
+					add(c[1], fields[0])
+				}
+				continue
+			}
+			if a := alterTable.FindStringSubmatch(stmt); a != nil {
+				for _, c := range addColumn.FindAllStringSubmatch(stmt, -1) {
+					add(a[1], c[1])
+				}
+			}
+		}
+	}
+
+	for table, columns := range store.PostgresColumns {
+		for _, column := range strings.Split(columns, ",") {
+			column = strings.TrimSpace(column)
+			assert.True(t, schema[table][column], "%s.%s is not created by any migration", table, column)
+		}
+	}
+}
@@ -0,0 +1,956 @@
+// Package storetest is a conformance suite for contracts.Store implementations.
+// Every backend runs the same checks so they stay interchangeable.
+// Call Run from a _test.go file with a factory that returns an empty store.
+// The factory is called once per subtest; it may skip the test when its backend is unavailable.
+// Checks compare timestamps loosely because databases round them.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// Factory returns an empty store for one subtest.
+type Factory func(t *testing.T) contracts.Store
+
+// Run executes the whole suite against stores built by newStore.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, st contracts.Store)
+	}{
+		{"App/CreateAndGet", testAppCreateAndGet},
+		{"App/DuplicateNameConflict", testAppDuplicateName},
+		{"App/NotFound", testAppNotFound},
+		{"App/List", testAppList},
+		{"App/ListFilterAndPage", testAppListFilterAndPage},
+		{"App/UpdateVersioned", testAppUpdateVersioned},
+		{"App/UpdateRename", testAppUpdateRename},
+		{"App/UpdateStatus", testAppUpdateStatus},
+		{"App/DeleteCascades", testAppDeleteCascades},
+		{"Deployment/CreateAndGet", testDeploymentCreateAndGet},
+		{"Deployment/AppMissingNotFound", testDeploymentAppMissing},
+		{"Deployment/ListOrderAndUpdates", testDeploymentListOrder},
+		{"Deployment/ListEmpty", testDeploymentListEmpty},
+		{"Deployment/ListFilterAndPage", testDeploymentListFilterAndPage},
+		{"Deployment/UpdateNotFound", testDeploymentUpdateNotFound},
+		{"Deployment/UpdateVersioned", testDeploymentUpdateVersioned},
+		{"Deployment/Latest", testDeploymentLatest},
+		{"Deployment/Timestamps", testDeploymentTimestamps},
+		{"Deployment/Logs", testDeploymentLogs},
+		{"Deployment/LogsDeletedWithApp", testDeploymentLogsDeleted},
+		{"Queue/FIFO", testQueueFIFO},
+		{"Queue/SkipsNonQueued", testQueueSkipsNonQueued},
+		{"Queue/Position", testQueuePosition},
+		{"Queue/ClaimFieldsOwnedByStore", testQueueClaimFields},
+		{"Queue/ExpiredLeaseReclaimed", testQueueExpiredLease},
+		{"Queue/RenewLease", testQueueRenewLease},
+		{"Queue/StuckAndRequeue", testQueueStuckAndRequeue},
+		{"Queue/RequeueAfterReclaim", testQueueRequeueAfterReclaim},
+		{"Queue/RequeueWritesOnlyRequeueFields", testQueueRequeueFields},
+		{"Concurrency/ClaimsAreExclusive", testConcurrentClaims},
+		{"Concurrency/UniqueNames", testConcurrentCreateApp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+// newApp builds a valid app with the given name.
+func newApp(t *testing.T, name string) domain.App {
+	t.Helper()
+	port := 8080
+	app, err := domain.NewApp(domain.NewAppParams{Name: name, Image: "nginx:latest", Port: &port})
+	require.NoError(t, err)
+	return app
+}
+
+// seedApp stores a new app and returns it.
+func seedApp(t *testing.T, st contracts.Store, name string) domain.App {
+	t.Helper()
+	app := newApp(t, name)
+	require.NoError(t, st.CreateApp(context.Background(), app))
+	return app
+}
+
+// seedDeployments stores n queued deployments for appID in order.
+func seedDeployments(t *testing.T, st contracts.Store, appID string, n int) []domain.Deployment {
+	t.Helper()
+	out := make([]domain.Deployment, 0, n)
+	for i := 0; i < n; i++ {
+		dep := domain.NewDeployment(appID)
+		require.NoError(t, st.CreateDeployment(context.Background(), dep))
+		out = append(out, dep)
+	}
+	return out
+}
+
+// updateDeployment writes dep and returns the stored record with its new version.
+func updateDeployment(t *testing.T, st contracts.Store, dep domain.Deployment) domain.Deployment {
+	t.Helper()
+	updated, err := st.UpdateDeployment(context.Background(), dep)
+	require.NoError(t, err)
+	return updated
+}
+
+// testAppCreateAndGet verifies apps round trip by id and name.
+func testAppCreateAndGet(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	expose := false
+	app, err := domain.NewApp(domain.NewAppParams{
+		Name:   "hello",
+		Image:  "nginx:latest",
+		Expose: &expose,
+		Env:    map[string]string{"KEY": "VALUE"},
+		HealthCheck: &domain.HealthCheck{
+			Type:    domain.HealthCheckCommand,
+			Command: []string{"pg_isready", "-q"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, st.CreateApp(ctx, app))
+
+	got, err := st.GetAppByID(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, app.ID, got.ID)
+	assert.Equal(t, app.Name, got.Name)
+	assert.Equal(t, app.Image, got.Image)
+	assert.Nil(t, got.Port)
+	assert.False(t, got.Expose)
+	assert.Equal(t, app.Env, got.Env)
+	assert.Equal(t, app.HealthCheck, got.HealthCheck)
+	assert.Equal(t, domain.AppStatusCreated, got.Status)
+	assert.WithinDuration(t, app.CreatedAt, got.CreatedAt, time.Millisecond)
+	assert.WithinDuration(t, app.UpdatedAt, got.UpdatedAt, time.Millisecond)
+
+	byName, err := st.GetAppByName(ctx, app.Name)
+	require.NoError(t, err)
+	assert.Equal(t, app.ID, byName.ID)
+}
+
+// testAppDuplicateName verifies duplicate names return ErrConflict.
+func testAppDuplicateName(t *testing.T, st contracts.Store) {
+	seedApp(t, st, "hello")
+	err := st.CreateApp(context.Background(), newApp(t, "hello"))
+	assert.ErrorIs(t, err, contracts.ErrConflict)
+}
+
+// testAppNotFound verifies missing apps return ErrNotFound.
+func testAppNotFound(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	_, err := st.GetAppByID(ctx, "missing")
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+	_, err = st.GetAppByName(ctx, "missing")
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+}
+
+// testAppList verifies every app is listed.
+func testAppList(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	apps, total, err := st.ListApps(ctx, contracts.AppFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, apps)
+	assert.Equal(t, 0, total)
+
+	seedApp(t, st, "a")
+	seedApp(t, st, "b")
+	apps, total, err = st.ListApps(ctx, contracts.AppFilter{})
+	require.NoError(t, err)
+	assert.Len(t, apps, 2)
+	assert.Equal(t, 2, total)
+}
+
+// testAppListFilterAndPage verifies sort order, filters, and paging with totals.
+func testAppListFilterAndPage(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	base := time.Now().UTC().Truncate(time.Second)
+
+	// Distinct creation times pin the expected order; ties fall back to id.
+	names := []string{"web-b", "api", "web-a", "web-c"}
+	created := make([]domain.App, 0, len(names))
+	for i, name := range names {
+		app := newApp(t, name)
+		app.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		if name == "web-a" {
+			app.Status = domain.AppStatusRunning
+		}
+		require.NoError(t, st.CreateApp(ctx, app))
+		created = append(created, app)
+	}
+
+	all, total, err := st.ListApps(ctx, contracts.AppFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	require.Len(t, all, 4)
+	for i := range created {
+		assert.Equal(t, created[i].ID, all[i].ID)
+	}
+
+	web, total, err := st.ListApps(ctx, contracts.AppFilter{NamePrefix: "web-"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, web, 3)
+	assert.Equal(t, "web-b", web[0].Name)
+
+	running, total, err := st.ListApps(ctx, contracts.AppFilter{Status: domain.AppStatusRunning})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, running, 1)
+	assert.Equal(t, "web-a", running[0].Name)
+
+	page, total, err := st.ListApps(ctx, contracts.AppFilter{Page: contracts.Page{Limit: 2, Offset: 1}})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	require.Len(t, page, 2)
+	assert.Equal(t, created[1].ID, page[0].ID)
+	assert.Equal(t, created[2].ID, page[1].ID)
+
+	past, total, err := st.ListApps(ctx, contracts.AppFilter{Page: contracts.Page{Limit: 2, Offset: 10}})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Empty(t, past)
+}
+
+// testAppUpdateVersioned verifies app updates compare and swap on the version.
+func testAppUpdateVersioned(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	assert.Equal(t, int64(1), app.Version)
+
+	first := app
+	first.Image = "nginx:1.27"
+	first.Status = domain.AppStatusRunning
+	first.HealthCheck = &domain.HealthCheck{Type: domain.HealthCheckTCP, Interval: time.Second, Timeout: time.Second, Retries: 2}
+	first.UpdatedAt = time.Now().UTC()
+	updated, err := st.UpdateApp(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+	assert.Equal(t, "nginx:1.27", updated.Image)
+	assert.Equal(t, first.HealthCheck, updated.HealthCheck)
+
+	second := app
+	second.Image = "nginx:1.26"
+	_, err = st.UpdateApp(ctx, second)
+	assert.ErrorIs(t, err, contracts.ErrVersionMismatch)
+
+	got, err := st.GetAppByID(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "nginx:1.27", got.Image)
+	assert.Equal(t, domain.AppStatusRunning, got.Status)
+	assert.Equal(t, int64(2), got.Version)
+
+	missing := newApp(t, "missing")
+	_, err = st.UpdateApp(ctx, missing)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+}
+
+// testAppUpdateRename verifies renames move the name lookup and respect uniqueness.
+func testAppUpdateRename(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	seedApp(t, st, "taken")
+
+	clash := app
+	clash.Name = "taken"
+	_, err := st.UpdateApp(ctx, clash)
+	assert.ErrorIs(t, err, contracts.ErrConflict)
+
+	app.Name = "renamed"
+	app, err = st.UpdateApp(ctx, app)
+	require.NoError(t, err)
+
+	got, err := st.GetAppByName(ctx, "renamed")
+	require.NoError(t, err)
+	assert.Equal(t, app.ID, got.ID)
+	_, err = st.GetAppByName(ctx, "hello")
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+
+	// The old name is free again.
+	seedApp(t, st, "hello")
+}
+
+// testAppUpdateStatus verifies status updates bump the version and leave other fields alone.
+func testAppUpdateStatus(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+
+	updated, err := st.UpdateAppStatus(ctx, app.ID, domain.AppStatusRunning)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AppStatusRunning, updated.Status)
+	assert.Equal(t, app.Version+1, updated.Version)
+	assert.Equal(t, app.Image, updated.Image)
+
+	byName, err := st.GetAppByName(ctx, app.Name)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AppStatusRunning, byName.Status)
+
+	_, err = st.UpdateAppStatus(ctx, "missing", domain.AppStatusRunning)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+
+	// A deleting app keeps its status.
+	deleting, err := st.UpdateAppStatus(ctx, app.ID, domain.AppStatusDeleting)
+	require.NoError(t, err)
+	kept, err := st.UpdateAppStatus(ctx, app.ID, domain.AppStatusFailed)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AppStatusDeleting, kept.Status)
+	assert.Equal(t, deleting.Version, kept.Version)
+}
+
+// testAppDeleteCascades verifies deleting an app removes its deployments and queue entries only.
+func testAppDeleteCascades(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	other := seedApp(t, st, "other")
+	deps := seedDeployments(t, st, app.ID, 2)
+	kept := seedDeployments(t, st, other.ID, 1)
+
+	require.NoError(t, st.DeleteApp(ctx, app.ID))
+
+	_, err := st.GetAppByID(ctx, app.ID)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+	_, err = st.GetAppByName(ctx, app.Name)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+	_, err = st.GetDeploymentByID(ctx, deps[0].ID)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+
+	// Only the other app's deployment is left to claim.
+	claimed, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, kept[0].ID, claimed.ID)
+	_, err = st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+
+	// The name is free again and a second delete reports the app as missing.
+	seedApp(t, st, "hello")
+	assert.ErrorIs(t, st.DeleteApp(ctx, app.ID), contracts.ErrNotFound)
+}
+
+// testDeploymentCreateAndGet verifies deployments round trip by id.
+func testDeploymentCreateAndGet(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := newApp(t, "hello")
+	app.Env = map[string]string{"KEY": "VALUE"}
+	app.HealthCheck = &domain.HealthCheck{Type: domain.HealthCheckHTTP, Path: "/healthz", Interval: 5 * time.Second, Timeout: 2 * time.Second, Retries: 3}
+	require.NoError(t, st.CreateApp(ctx, app))
+	dep := domain.NewDeployment(app.ID)
+	dep.Spec = domain.SnapshotSpec(app)
+	require.NoError(t, st.CreateDeployment(ctx, dep))
+
+	got, err := st.GetDeploymentByID(ctx, dep.ID)
+	require.NoError(t, err)
+	assert.Equal(t, dep.ID, got.ID)
+	assert.Equal(t, app.ID, got.AppID)
+	assert.Equal(t, dep.Spec, got.Spec)
+	assert.Equal(t, domain.DeploymentStatusQueued, got.Status)
+	assert.Nil(t, got.URL)
+	assert.Nil(t, got.Error)
+	assert.Nil(t, got.ClaimedBy)
+	assert.WithinDuration(t, dep.CreatedAt, got.CreatedAt, time.Millisecond)
+	assert.Equal(t, dep.Steps, got.Steps)
+
+	assert.Nil(t, got.RollbackOf)
+
+	rollback := domain.NewDeployment(app.ID)
+	rollback.Spec = dep.Spec
+	rollback.RollbackOf = &dep.ID
+	require.NoError(t, st.CreateDeployment(ctx, rollback))
+	got, err = st.GetDeploymentByID(ctx, rollback.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &dep.ID, got.RollbackOf)
+
+	retry := domain.NewDeployment(app.ID)
+	retry.RetryOf = &rollback.ID
+	require.NoError(t, st.CreateDeployment(ctx, retry))
+	got, err = st.GetDeploymentByID(ctx, retry.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &rollback.ID, got.RetryOf)
+	assert.Nil(t, got.RollbackOf)
+
+	_, err = st.GetDeploymentByID(ctx, "missing")
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+}
+
+// testDeploymentAppMissing verifies deployments need an existing app.
+func testDeploymentAppMissing(t *testing.T, st contracts.Store) {
+	err := st.CreateDeployment(context.Background(), domain.NewDeployment("missing-app"))
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+}
+
+// testDeploymentListOrder verifies create order and that updates are visible in lists.
+func testDeploymentListOrder(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	deps := seedDeployments(t, st, app.ID, 3)
+
+	url := "https://hello.example.com"
+	msg := "boom"
+	deps[0].Status = domain.DeploymentStatusRunning
+	deps[0].URL = &url
+	deps[0].UpdatedAt = time.Now().UTC()
+	deps[0] = updateDeployment(t, st, deps[0])
+	deps[1].Status = domain.DeploymentStatusFailed
+	deps[1].Error = &msg
+	deps[1] = updateDeployment(t, st, deps[1])
+
+	got, total, err := st.ListDeploymentsByAppID(ctx, app.ID, contracts.DeploymentFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, got, 3)
+	for i := range deps {
+		assert.Equal(t, deps[i].ID, got[i].ID)
+	}
+	assert.Equal(t, domain.DeploymentStatusRunning, got[0].Status)
+	require.NotNil(t, got[0].URL)
+	assert.Equal(t, url, *got[0].URL)
+	assert.WithinDuration(t, deps[0].UpdatedAt, got[0].UpdatedAt, time.Millisecond)
+	assert.Equal(t, domain.DeploymentStatusFailed, got[1].Status)
+	require.NotNil(t, got[1].Error)
+	assert.Equal(t, msg, *got[1].Error)
+	assert.Equal(t, domain.DeploymentStatusQueued, got[2].Status)
+}
+
+// testDeploymentListEmpty verifies unknown apps list as empty, not an error.
+func testDeploymentListEmpty(t *testing.T, st contracts.Store) {
+	deps, total, err := st.ListDeploymentsByAppID(context.Background(), "missing-app", contracts.DeploymentFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, deps)
+	assert.Equal(t, 0, total)
+}
+
+// testDeploymentListFilterAndPage verifies status filters and paging with totals.
+func testDeploymentListFilterAndPage(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	other := seedApp(t, st, "other")
+	deps := seedDeployments(t, st, app.ID, 4)
+	seedDeployments(t, st, other.ID, 2)
+
+	for _, i := range []int{0, 2} {
+		deps[i].Status = domain.DeploymentStatusFailed
+		deps[i] = updateDeployment(t, st, deps[i])
+	}
+
+	failed, total, err := st.ListDeploymentsByAppID(ctx, app.ID, contracts.DeploymentFilter{Status: domain.DeploymentStatusFailed})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, failed, 2)
+	assert.Equal(t, deps[0].ID, failed[0].ID)
+	assert.Equal(t, deps[2].ID, failed[1].ID)
+
+	page, total, err := st.ListDeploymentsByAppID(ctx, app.ID, contracts.DeploymentFilter{Page: contracts.Page{Limit: 3, Offset: 2}})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	require.Len(t, page, 2)
+	assert.Equal(t, deps[2].ID, page[0].ID)
+	assert.Equal(t, deps[3].ID, page[1].ID)
+}
+
+// testDeploymentUpdateNotFound verifies updating a missing deployment fails.
+func testDeploymentUpdateNotFound(t *testing.T, st contracts.Store) {
+	_, err := st.UpdateDeployment(context.Background(), domain.NewDeployment("missing-app"))
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+}
+
+// testDeploymentUpdateVersioned verifies stale writers are refused and every status change bumps the version.
+func testDeploymentUpdateVersioned(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	dep := seedDeployments(t, st, app.ID, 1)[0]
+	assert.Equal(t, int64(1), dep.Version)
+
+	claimed, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), claimed.Version)
+
+	// Heartbeats leave the version alone so the holder's next write still applies.
+	require.NoError(t, st.RenewDeploymentLease(ctx, claimed.ID, "worker-1", time.Minute))
+
+	port := 8080
+	claimed.Status = domain.DeploymentStatusBuilding
+	claimed.StartStep(domain.StepPullImage)
+	claimed.FinishStep(domain.StepPullImage, errors.New("pull failed"))
+	claimed.RuntimePort = &port
+	building := updateDeployment(t, st, claimed)
+	assert.Equal(t, int64(3), building.Version)
+	assert.Equal(t, claimed.Steps, building.Steps)
+	assert.Equal(t, &port, building.RuntimePort)
+
+	// A second writer still holding version 2 loses.
+	stale := claimed
+	stale.Status = domain.DeploymentStatusFailed
+	_, err = st.UpdateDeployment(ctx, stale)
+	assert.ErrorIs(t, err, contracts.ErrVersionMismatch)
+
+	got, err := st.GetDeploymentByID(ctx, dep.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusBuilding, got.Status)
+	assert.Equal(t, int64(3), got.Version)
+	assert.Equal(t, claimed.Steps, got.Steps)
+
+	require.NoError(t, got.Requeue())
+	requeued, err := st.RequeueDeployment(ctx, got)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), requeued.Version)
+	assert.Nil(t, requeued.RuntimePort)
+	_, err = st.RequeueDeployment(ctx, got)
+	assert.ErrorIs(t, err, contracts.ErrVersionMismatch)
+}
+
+// testDeploymentLatest verifies the newest deployment is reported per app.
+func testDeploymentLatest(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	a := seedApp(t, st, "a")
+	b := seedApp(t, st, "b")
+	empty := seedApp(t, st, "empty")
+	aDeps := seedDeployments(t, st, a.ID, 3)
+	bDeps := seedDeployments(t, st, b.ID, 1)
+
+	latest, err := st.LatestDeployments(ctx, []string{a.ID, b.ID, empty.ID, "missing"})
+	require.NoError(t, err)
+	assert.Len(t, latest, 2)
+	assert.Equal(t, aDeps[2].ID, latest[a.ID].ID)
+	assert.Equal(t, bDeps[0].ID, latest[b.ID].ID)
+
+	latest, err = st.LatestDeployments(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, latest)
+}
+
+// testDeploymentTimestamps verifies start and completion times round trip.
+func testDeploymentTimestamps(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	seedDeployments(t, st, app.ID, 1)
+
+	dep, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, dep.Start())
+	dep = updateDeployment(t, st, dep)
+	require.NoError(t, dep.Fail("boom"))
+	dep = updateDeployment(t, st, dep)
+
+	got, err := st.GetDeploymentByID(ctx, dep.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.StartedAt)
+	require.NotNil(t, got.CompletedAt)
+	assert.WithinDuration(t, *dep.StartedAt, *got.StartedAt, time.Millisecond)
+	assert.WithinDuration(t, *dep.CompletedAt, *got.CompletedAt, time.Millisecond)
+	assert.Equal(t, time.UTC, got.StartedAt.Location())
+}
+
+// testDeploymentLogs verifies build log lines append in order and page by offset.
+func testDeploymentLogs(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	deps := seedDeployments(t, st, app.ID, 2)
+
+	base := time.Now().UTC()
+	var lines []domain.LogEntry
+	for i := range 5 {
+		lines = append(lines, domain.LogEntry{
+			Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Level:     domain.LogLevelInfo,
+			Message:   fmt.Sprintf("line %d", i),
+			Source:    string(domain.StepPullImage),
+		})
+	}
+	lines[4].Level = domain.LogLevelError
+	require.NoError(t, st.AppendDeploymentLogs(ctx, deps[0].ID, lines[:3]))
+	require.NoError(t, st.AppendDeploymentLogs(ctx, deps[0].ID, nil))
+	require.NoError(t, st.AppendDeploymentLogs(ctx, deps[0].ID, lines[3:]))
+
+	got, err := st.ListDeploymentLogs(ctx, deps[0].ID, contracts.Page{})
+	require.NoError(t, err)
+	require.Len(t, got, 5)
+	for i, line := range got {
+		assert.Equal(t, lines[i].Message, line.Message)
+		assert.Equal(t, lines[i].Level, line.Level)
+		assert.Equal(t, lines[i].Source, line.Source)
+		assert.WithinDuration(t, lines[i].Timestamp, line.Timestamp, time.Millisecond)
+		assert.Equal(t, time.UTC, line.Timestamp.Location())
+	}
+
+	page, err := st.ListDeploymentLogs(ctx, deps[0].ID, contracts.Page{Limit: 2, Offset: 3})
+	require.NoError(t, err)
+	if assert.Len(t, page, 2) {
+		assert.Equal(t, "line 3", page[0].Message)
+	}
+	page, err = st.ListDeploymentLogs(ctx, deps[0].ID, contracts.Page{Offset: 5})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+
+	// Each deployment has its own log, and a missing one cannot take lines
+	page, err = st.ListDeploymentLogs(ctx, deps[1].ID, contracts.Page{})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+	page, err = st.ListDeploymentLogs(ctx, "missing", contracts.Page{})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+	assert.ErrorIs(t, st.AppendDeploymentLogs(ctx, "missing", lines), contracts.ErrNotFound)
+	assert.ErrorIs(t, st.AppendDeploymentLogs(ctx, "missing", nil), contracts.ErrNotFound)
+}
+
+// testDeploymentLogsDeleted verifies an app's deployment logs go with it.
+func testDeploymentLogsDeleted(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	deps := seedDeployments(t, st, app.ID, 1)
+	line := domain.LogEntry{Timestamp: time.Now().UTC(), Level: domain.LogLevelInfo, Message: "pulling", Source: string(domain.StepPullImage)}
+	require.NoError(t, st.AppendDeploymentLogs(ctx, deps[0].ID, []domain.LogEntry{line}))
+
+	require.NoError(t, st.DeleteApp(ctx, app.ID))
+	got, err := st.ListDeploymentLogs(ctx, deps[0].ID, contracts.Page{})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+// testQueueFIFO verifies queued deployments are claimed oldest first.
+func testQueueFIFO(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	a := seedApp(t, st, "a")
+	b := seedApp(t, st, "b")
+	first := seedDeployments(t, st, a.ID, 1)[0]
+	second := seedDeployments(t, st, b.ID, 1)[0]
+	third := seedDeployments(t, st, a.ID, 1)[0]
+
+	for _, want := range []domain.Deployment{first, second, third} {
+		dep, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, want.ID, dep.ID)
+	}
+	_, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+}
+
+// testQueuePosition verifies positions follow claim order and drop to zero once a deployment leaves the queue.
+func testQueuePosition(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	a := seedApp(t, st, "a")
+	b := seedApp(t, st, "b")
+	first := seedDeployments(t, st, a.ID, 1)[0]
+	second := seedDeployments(t, st, b.ID, 1)[0]
+	third := seedDeployments(t, st, a.ID, 1)[0]
+
+	position := func(id string) int {
+		t.Helper()
+		pos, err := st.QueuePosition(ctx, id)
+		require.NoError(t, err)
+		return pos
+	}
+	assert.Equal(t, 1, position(first.ID))
+	assert.Equal(t, 2, position(second.ID))
+	assert.Equal(t, 3, position(third.ID))
+
+	_, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, second.Cancel())
+	updateDeployment(t, st, second)
+
+	assert.Equal(t, 0, position(first.ID))
+	assert.Equal(t, 0, position(second.ID))
+	assert.Equal(t, 1, position(third.ID))
+	assert.Equal(t, 0, position("missing"))
+}
+
+// testQueueSkipsNonQueued verifies deployments moved out of QUEUED are not claimed.
+func testQueueSkipsNonQueued(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	deps := seedDeployments(t, st, app.ID, 2)
+
+	deps[0].Status = domain.DeploymentStatusRunning
+	deps[0] = updateDeployment(t, st, deps[0])
+
+	dep, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, deps[1].ID, dep.ID)
+	_, err = st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+}
+
+// testQueueClaimFields verifies claims are recorded and survive updates.
+func testQueueClaimFields(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	seedDeployments(t, st, app.ID, 1)
+
+	dep, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, dep.ClaimedBy)
+	assert.Equal(t, "worker-1", *dep.ClaimedBy)
+	require.NotNil(t, dep.LeaseExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), *dep.LeaseExpiresAt, 5*time.Second)
+	assert.Equal(t, 1, dep.Attempts)
+
+	dep.Status = domain.DeploymentStatusBuilding
+	dep.ClaimedBy = nil
+	dep.LeaseExpiresAt = nil
+	dep.Attempts = 0
+	dep = updateDeployment(t, st, dep)
+
+	got, err := st.GetDeploymentByID(ctx, dep.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusBuilding, got.Status)
+	require.NotNil(t, got.ClaimedBy)
+	assert.Equal(t, "worker-1", *got.ClaimedBy)
+	assert.NotNil(t, got.LeaseExpiresAt)
+	assert.Equal(t, 1, got.Attempts)
+}
+
+// testQueueExpiredLease verifies expired claims go back to the queue ahead of newer work.
+func testQueueExpiredLease(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	deps := seedDeployments(t, st, app.ID, 2)
+
+	dep, err := st.TakeNextQueuedDeployment(ctx, "worker-1", 20*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, deps[0].ID, dep.ID)
+	require.NoError(t, dep.Start())
+	dep.StartStep(domain.StepPullImage)
+	dep = updateDeployment(t, st, dep)
+
+	time.Sleep(60 * time.Millisecond)
+
+	dep, err = st.TakeNextQueuedDeployment(ctx, "worker-2", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, deps[0].ID, dep.ID)
+	assert.Equal(t, domain.DeploymentStatusQueued, dep.Status)
+	require.NotNil(t, dep.ClaimedBy)
+	assert.Equal(t, "worker-2", *dep.ClaimedBy)
+	assert.Equal(t, 2, dep.Attempts)
+	// The interrupted run is dropped the way Deployment.Requeue drops it.
+	assert.Nil(t, dep.StartedAt)
+	assert.Equal(t, domain.NewDeploySteps(), dep.Steps)
+
+	dep, err = st.TakeNextQueuedDeployment(ctx, "worker-2", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, deps[1].ID, dep.ID)
+}
+
+// testQueueRenewLease verifies only the live holder can renew.
+func testQueueRenewLease(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	seedDeployments(t, st, app.ID, 1)
+
+	dep, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Second)
+	require.NoError(t, err)
+
+	require.NoError(t, st.RenewDeploymentLease(ctx, dep.ID, "worker-1", time.Hour))
+	got, err := st.GetDeploymentByID(ctx, dep.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.LeaseExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *got.LeaseExpiresAt, 5*time.Second)
+
+	assert.ErrorIs(t, st.RenewDeploymentLease(ctx, dep.ID, "worker-2", time.Hour), contracts.ErrLeaseLost)
+	assert.ErrorIs(t, st.RenewDeploymentLease(ctx, "missing", "worker-1", time.Hour), contracts.ErrNotFound)
+
+	dep.Status = domain.DeploymentStatusRunning
+	dep = updateDeployment(t, st, dep)
+	assert.ErrorIs(t, st.RenewDeploymentLease(ctx, dep.ID, "worker-1", time.Hour), contracts.ErrLeaseLost)
+}
+
+// testQueueStuckAndRequeue verifies stuck detection honours leases and requeue resets the claim.
+func testQueueStuckAndRequeue(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	seedDeployments(t, st, app.ID, 2)
+	cutoff := func() time.Time { return time.Now().UTC().Add(-time.Minute) }
+
+	// One claim with a live lease and one that has expired, both last touched an hour ago.
+	live, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Hour)
+	require.NoError(t, err)
+	dead, err := st.TakeNextQueuedDeployment(ctx, "worker-1", 20*time.Millisecond)
+	require.NoError(t, err)
+	for _, dep := range []domain.Deployment{live, dead} {
+		dep.Status = domain.DeploymentStatusBuilding
+		dep.UpdatedAt = time.Now().UTC().Add(-time.Hour)
+		updateDeployment(t, st, dep)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	stuck, err := st.ListStuckDeployments(ctx, cutoff())
+	require.NoError(t, err)
+	require.Len(t, stuck, 1)
+	assert.Equal(t, dead.ID, stuck[0].ID)
+
+	// Only a deployment moved with Requeue is stored.
+	_, err = st.RequeueDeployment(ctx, stuck[0])
+	assert.ErrorIs(t, err, contracts.ErrConflict)
+	requeue := stuck[0]
+	require.NoError(t, requeue.Requeue())
+	got, err := st.RequeueDeployment(ctx, requeue)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusQueued, got.Status)
+	assert.Nil(t, got.ClaimedBy)
+	assert.Nil(t, got.StartedAt)
+	assert.Equal(t, stuck[0].Version+1, got.Version)
+	_, err = st.RequeueDeployment(ctx, got)
+	assert.ErrorIs(t, err, contracts.ErrConflict)
+	_, err = st.RequeueDeployment(ctx, domain.Deployment{ID: "missing", Status: domain.DeploymentStatusQueued})
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+
+	stuck, err = st.ListStuckDeployments(ctx, cutoff())
+	require.NoError(t, err)
+	assert.Empty(t, stuck)
+
+	next, err := st.TakeNextQueuedDeployment(ctx, "worker-2", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, dead.ID, next.ID)
+}
+
+// testQueueRequeueFields verifies a requeue stores only what Deployment.Requeue changes.
+func testQueueRequeueFields(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	seedDeployments(t, st, app.ID, 1)
+
+	dep, err := st.TakeNextQueuedDeployment(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, dep.Start())
+	dep = updateDeployment(t, st, dep)
+
+	// Fields a requeue does not own are changed on the caller's copy and must not be written.
+	requeue := dep
+	require.NoError(t, requeue.Requeue())
+	other := "someone-else"
+	requeue.Spec.Image = "nginx:changed"
+	requeue.RetryOf = &other
+	requeue.Attempts = 99
+	requeue.CreatedAt = dep.CreatedAt.Add(time.Hour)
+	got, err := st.RequeueDeployment(ctx, requeue)
+	require.NoError(t, err)
+
+	stored, err := st.GetDeploymentByID(ctx, dep.ID)
+	require.NoError(t, err)
+	for _, d := range []domain.Deployment{got, stored} {
+		assert.Equal(t, domain.DeploymentStatusQueued, d.Status)
+		assert.Nil(t, d.StartedAt)
+		assert.Nil(t, d.ClaimedBy)
+		assert.Equal(t, domain.NewDeploySteps(), d.Steps)
+		assert.Equal(t, dep.Spec, d.Spec)
+		assert.Nil(t, d.RetryOf)
+		assert.Equal(t, dep.Attempts, d.Attempts)
+		assert.True(t, dep.CreatedAt.Equal(d.CreatedAt))
+	}
+}
+
+// testQueueRequeueAfterReclaim verifies a stuck deployment reclaimed between listing and requeueing stays with its new holder.
+func testQueueRequeueAfterReclaim(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	app := seedApp(t, st, "hello")
+	seedDeployments(t, st, app.ID, 1)
+
+	dep, err := st.TakeNextQueuedDeployment(ctx, "worker-1", 20*time.Millisecond)
+	require.NoError(t, err)
+	dep.Status = domain.DeploymentStatusBuilding
+	dep.UpdatedAt = time.Now().UTC().Add(-time.Hour)
+	updateDeployment(t, st, dep)
+	time.Sleep(60 * time.Millisecond)
+
+	stuck, err := st.ListStuckDeployments(ctx, time.Now().UTC().Add(-time.Minute))
+	require.NoError(t, err)
+	require.Len(t, stuck, 1)
+
+	// A live worker reclaims the expired row before the requeue lands.
+	reclaimed, err := st.TakeNextQueuedDeployment(ctx, "worker-2", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, dep.ID, reclaimed.ID)
+
+	late := stuck[0]
+	require.NoError(t, late.Requeue())
+	_, err = st.RequeueDeployment(ctx, late)
+	assert.ErrorIs(t, err, contracts.ErrVersionMismatch)
+
+	got, err := st.GetDeploymentByID(ctx, dep.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.ClaimedBy)
+	assert.Equal(t, "worker-2", *got.ClaimedBy)
+	assert.Equal(t, reclaimed.Version, got.Version)
+	require.NoError(t, st.RenewDeploymentLease(ctx, dep.ID, "worker-2", time.Hour))
+}
+
+// testConcurrentClaims verifies concurrent workers never claim the same deployment twice.
+func testConcurrentClaims(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	const deployments, workers = 40, 8
+
+	app := seedApp(t, st, "hello")
+	seeded := seedDeployments(t, st, app.ID, deployments)
+
+	var (
+		mu      sync.Mutex
+		claimed = make(map[string]string, deployments)
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		workerID := fmt.Sprintf("worker-%d", w)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				dep, err := st.TakeNextQueuedDeployment(ctx, workerID, time.Minute)
+				if err != nil {
+					assert.ErrorIs(t, err, contracts.ErrNotFound)
+					return
+				}
+				mu.Lock()
+				prev, dup := claimed[dep.ID]
+				claimed[dep.ID] = workerID
+				mu.Unlock()
+				assert.False(t, dup, "deployment %s claimed by %s and %s", dep.ID, prev, workerID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, claimed, deployments)
+	for _, dep := range seeded {
+		assert.Contains(t, claimed, dep.ID)
+	}
+}
+
+// testConcurrentCreateApp verifies exactly one of many racing creates wins a name.
+func testConcurrentCreateApp(t *testing.T, st contracts.Store) {
+	ctx := context.Background()
+	const racers = 8
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		ok        int
+		conflicts int
+	)
+	for i := 0; i < racers; i++ {
+		app := newApp(t, "hello")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := st.CreateApp(ctx, app)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				ok++
+			case assert.ErrorIs(t, err, contracts.ErrConflict):
+				conflicts++
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, ok)
+	assert.Equal(t, racers-1, conflicts)
+}
@@ -0,0 +1,55 @@
+// Package config reads process settings from the environment for the api and worker binaries.
+// Unset variables fall back to a default; malformed ones stop the process at startup.
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Env returns an environment variable or a default value.
+func Env(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// EnvDuration returns a duration env var such as "90s" or a default value.
+func EnvDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("%s: %v", key, err)
+	}
+	return d
+}
+
+// EnvInt returns an integer env var or a default value.
+func EnvInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("%s: %v", key, err)
+	}
+	return n
+}
+
+// DefaultWorkerID returns "<hostname>-<random>" so workers on one host, and restarts of one worker, never share claims.
+func DefaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "worker"
+	}
+	return host + "-" + uuid.NewString()[:8]
+}
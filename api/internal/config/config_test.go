@@ -0,0 +1,38 @@
+// Tests for reading settings from the environment
+// Tests verify set variables are parsed and unset ones take the default
+// Tests verify default worker ids are distinct
+// Malformed values stop the process, so they are not covered here
+// These tests keep the binaries' configuration predictable
+
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/t0gun/spacescale/internal/config"
+)
+
+// TestEnv verifies set variables are read and unset ones fall back to the default.
+func TestEnv(t *testing.T) {
+	t.Setenv("SPACESCALE_TEST_STRING", "value")
+	t.Setenv("SPACESCALE_TEST_INT", "4")
+	t.Setenv("SPACESCALE_TEST_DURATION", "90s")
+
+	assert.Equal(t, "value", config.Env("SPACESCALE_TEST_STRING", "def"))
+	assert.Equal(t, 4, config.EnvInt("SPACESCALE_TEST_INT", 1))
+	assert.Equal(t, 90*time.Second, config.EnvDuration("SPACESCALE_TEST_DURATION", time.Second))
+
+	assert.Equal(t, "def", config.Env("SPACESCALE_TEST_UNSET", "def"))
+	assert.Equal(t, 1, config.EnvInt("SPACESCALE_TEST_UNSET", 1))
+	assert.Equal(t, time.Second, config.EnvDuration("SPACESCALE_TEST_UNSET", time.Second))
+}
+
+// TestDefaultWorkerID verifies ids carry a random suffix so two workers never share one.
+func TestDefaultWorkerID(t *testing.T) {
+	a, b := config.DefaultWorkerID(), config.DefaultWorkerID()
+	assert.NotEqual(t, a, b)
+	assert.True(t, strings.Contains(a, "-"))
+}
@@ -7,4 +7,8 @@ import "errors"
 var (
 	ErrNotFound = errors.New("not found")
 	ErrConflict = errors.New("conflict")
+	// ErrLeaseLost means the caller no longer holds the deployment claim.
+	ErrLeaseLost = errors.New("lease lost")
+	// ErrVersionMismatch means the record changed since the caller read it.
+	ErrVersionMismatch = errors.New("version mismatch")
 )
@@ -1,19 +1,81 @@
 // Runtime interface for deployment implementations
-// It defines how apps are deployed
+// It defines how apps are deployed and how their workload is managed afterwards
 // A nil url means the app runs without exposure
 // Service code depends on this contract
 // Runtime adapters implement this interface
+// Deploy reports its progress through the standard deploy steps
+// Logs reads the workload's output, optionally following new lines
 
 package contracts
 
 import (
 	"context"
+	"time"
 
 	"github.com/t0gun/spacescale/internal/domain"
 )
 
+// DeployProgress receives a deploy's progress through its steps and the build log lines it writes
+// Calls come from the goroutine running Deploy, one step at a time
+type DeployProgress interface {
+	// StepStarted reports that a step began.
+	StepStarted(step domain.StepID)
+	// StepFinished reports that a step ended, failed when err is not nil.
+	StepFinished(step domain.StepID, err error)
+	// Log reports one build log line, such as image pull progress.
+	Log(line domain.LogEntry)
+	// PortResolved reports the port the app's container listens on once it is known.
+	PortResolved(port int)
+}
+
+// NoProgress is a DeployProgress that ignores every report
+type NoProgress struct{}
+
+// StepStarted does nothing.
+func (NoProgress) StepStarted(domain.StepID) {}
+
+// StepFinished does nothing.
+func (NoProgress) StepFinished(domain.StepID, error) {}
+
+// Log does nothing.
+func (NoProgress) Log(domain.LogEntry) {}
+
+// PortResolved does nothing.
+func (NoProgress) PortResolved(int) {}
+
+// LogQuery selects which lines of an app's output Logs reads
+// Since and Tail narrow the lines already written; Follow keeps reading new ones
+type LogQuery struct {
+	Since  *time.Time // lines written at or after this time; nil reads from the start
+	Tail   int        // only the last Tail lines; zero reads them all
+	Follow bool       // keep reading lines as they are written until ctx ends or the workload goes away
+}
+
 // Runtime defines how an app is deployed and how its URL is returned
 type Runtime interface {
 	// Deploy runs an app deployment and returns its URL when exposed.
-	Deploy(ctx context.Context, app domain.App) (url *string, err error)
+	// It reports each step it runs to progress as it starts and finishes, along with its build log.
+	// Canceling ctx before the switch step stops it and removes whatever it started; the switch itself runs to the end.
+	Deploy(ctx context.Context, app domain.App, progress DeployProgress) (url *string, err error)
+	// Stop stops an app's workload and keeps it around so Start can bring it back.
+	Stop(ctx context.Context, app domain.App) error
+	// Start starts a stopped workload again without redeploying it.
+	Start(ctx context.Context, app domain.App) error
+	// Restart stops and starts an app's workload.
+	Restart(ctx context.Context, app domain.App) error
+	// Pause stops an app's workload and serves a maintenance response where it was routed.
+	Pause(ctx context.Context, app domain.App) error
+	// Resume restarts a paused workload as it was, without pulling or recreating it,
+	// and takes the maintenance response down.
+	Resume(ctx context.Context, app domain.App) error
+	// Remove tears down everything the runtime runs for an app, including its routing.
+	// Removing an app that has nothing running is not an error.
+	Remove(ctx context.Context, app domain.App) error
+	// Status reports what an app's workload is doing right now.
+	// Stop, Start, Restart, Pause, Resume and Status return ErrNotFound when the app has never been deployed.
+	Status(ctx context.Context, app domain.App) (domain.RuntimeStatus, error)
+	// Logs reads an app's output oldest first and passes each line to emit.
+	// Reading stops when emit returns an error, which Logs returns unchanged.
+	// It returns ErrNotFound when the app has never been deployed.
+	Logs(ctx context.Context, app domain.App, q LogQuery, emit func(domain.LogEntry) error) error
 }
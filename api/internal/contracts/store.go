@@ -3,10 +3,30 @@ package contracts
 
 import (
 	"context"
+	"time"
 
 	"github.com/t0gun/spacescale/internal/domain"
 )
 
+// Page selects a window of a sorted list. A zero Limit means no limit.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// AppFilter narrows ListApps. Zero values match everything.
+type AppFilter struct {
+	Status     domain.AppStatus
+	NamePrefix string
+	Page
+}
+
+// DeploymentFilter narrows ListDeploymentsByAppID. Zero values match everything.
+type DeploymentFilter struct {
+	Status domain.DeploymentStatus
+	Page
+}
+
 // Store defines persistence operations for apps and deployments.
 type Store interface {
 	// CreateApp persists a new app.
@@ -15,18 +35,59 @@ type Store interface {
 	GetAppByID(ctx context.Context, id string) (domain.App, error)
 	// GetAppByName fetches an app by its name.
 	GetAppByName(ctx context.Context, name string) (domain.App, error)
-	// ListApps returns all apps.
-	ListApps(ctx context.Context) ([]domain.App, error)
+	// ListApps returns one page of matching apps sorted by creation time then id,
+	// along with the total number of matches.
+	ListApps(ctx context.Context, f AppFilter) ([]domain.App, int, error)
+	// UpdateApp replaces an app when app.Version matches the stored version and returns the
+	// stored record with its version bumped. It returns ErrVersionMismatch when the app changed
+	// since it was read and ErrConflict when a rename collides with another app.
+	UpdateApp(ctx context.Context, app domain.App) (domain.App, error)
+	// UpdateAppStatus sets the status of an app and bumps its version without comparing it.
+	// Status is derived from deployments, so it never races a user's edit of other fields.
+	// An app marked DELETING keeps that status and is returned unchanged.
+	UpdateAppStatus(ctx context.Context, id string, status domain.AppStatus) (domain.App, error)
+	// DeleteApp removes an app together with all of its deployments, queued ones included.
+	// It returns ErrNotFound when the app does not exist.
+	DeleteApp(ctx context.Context, id string) error
 
 	// CreateDeployment persists a new deployment.
 	CreateDeployment(ctx context.Context, dep domain.Deployment) error
 	// GetDeploymentByID fetches a deployment by its id.
 	GetDeploymentByID(ctx context.Context, id string) (domain.Deployment, error)
-	// ListDeploymentsByAppID returns deployments for one app.
-	ListDeploymentsByAppID(ctx context.Context, appID string) ([]domain.Deployment, error)
+	// ListDeploymentsByAppID returns one page of an app's matching deployments in create order,
+	// along with the total number of matches.
+	ListDeploymentsByAppID(ctx context.Context, appID string, f DeploymentFilter) ([]domain.Deployment, int, error)
+	// QueuePosition returns the 1-based position of a QUEUED deployment in claim order,
+	// or 0 when the deployment is not waiting in the queue.
+	QueuePosition(ctx context.Context, id string) (int, error)
+	// LatestDeployments returns the newest deployment of each app, keyed by app id.
+	// Apps without deployments are left out of the map.
+	LatestDeployments(ctx context.Context, appIDs []string) (map[string]domain.Deployment, error)
+
+	// TakeNextQueuedDeployment claims the next queued deployment for workerID until the lease runs out.
+	// Deployments whose lease expired before they finished are returned to the queue and can be claimed again.
+	TakeNextQueuedDeployment(ctx context.Context, workerID string, lease time.Duration) (domain.Deployment, error)
+	// RenewDeploymentLease extends the claim held by workerID or returns ErrLeaseLost.
+	// Renewing does not bump the deployment version, so heartbeats never race the holder's writes.
+	RenewDeploymentLease(ctx context.Context, id, workerID string, lease time.Duration) error
+	// ListStuckDeployments returns BUILDING or DEPLOYING deployments last updated before the cutoff
+	// whose lease is missing or expired, oldest first.
+	ListStuckDeployments(ctx context.Context, updatedBefore time.Time) ([]domain.Deployment, error)
+	// RequeueDeployment stores a deployment moved back to QUEUED with Deployment.Requeue when deployment.Version
+	// still matches the stored version, drops its claim and returns the stored record with its version bumped.
+	// Only the fields Requeue changes are written; the rest of deployment is ignored.
+	// It returns ErrVersionMismatch when the deployment changed since it was read, such as when a worker
+	// reclaimed it, and ErrConflict when it is no longer in progress or deployment was not requeued.
+	RequeueDeployment(ctx context.Context, deployment domain.Deployment) (domain.Deployment, error)
+	// UpdateDeployment updates a deployment when deployment.Version matches the stored version
+	// and returns the stored record with its version bumped, or ErrVersionMismatch.
+	// Claim fields are owned by the store and are not changed by updates.
+	UpdateDeployment(ctx context.Context, deployment domain.Deployment) (domain.Deployment, error)
 
-	// TakeNextQueuedDeployment returns and removes the next queued deployment.
-	TakeNextQueuedDeployment(ctx context.Context) (domain.Deployment, error)
-	// UpdateDeployment updates an existing deployment record.
-	UpdateDeployment(ctx context.Context, deployment domain.Deployment) error
+	// AppendDeploymentLogs adds lines to the end of a deployment's build log.
+	// It returns ErrNotFound when the deployment does not exist.
+	AppendDeploymentLogs(ctx context.Context, deploymentID string, lines []domain.LogEntry) error
+	// ListDeploymentLogs returns one page of a deployment's build log in the order the lines were appended.
+	// A deployment without lines, or one that does not exist, has an empty log.
+	ListDeploymentLogs(ctx context.Context, deploymentID string, p Page) ([]domain.LogEntry, error)
 }
@@ -0,0 +1,104 @@
+// Health checks that gate a deployment going RUNNING
+// An app may probe an HTTP path, a TCP port, or run a command in its container
+// Interval, timeout and retries fall back to defaults when left at zero
+// A nil check means the image's own HEALTHCHECK, if any, decides
+// Checks are frozen into the deployment spec like the rest of the app's config
+
+package domain
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// HealthCheckType selects how a health check probes the app
+// http and tcp probes run inside the container and need /bin/sh with wget or curl, or nc;
+// images without them, such as distroless or scratch ones, should use a command check
+type HealthCheckType string
+
+const (
+	HealthCheckHTTP    HealthCheckType = "http"
+	HealthCheckTCP     HealthCheckType = "tcp"
+	HealthCheckCommand HealthCheckType = "command"
+)
+
+// Health check defaults used for zero fields.
+const (
+	DefaultHealthInterval = 10 * time.Second
+	DefaultHealthTimeout  = 5 * time.Second
+	DefaultHealthRetries  = 3
+)
+
+// HealthCheck configures how the runtime decides a new container is healthy
+// Path is used by http checks and Command by command checks
+// Retries is how many failures in a row mark the container unhealthy
+type HealthCheck struct {
+	Type     HealthCheckType
+	Path     string
+	Command  []string
+	Interval time.Duration
+	Timeout  time.Duration
+	Retries  int
+}
+
+// Valid reports whether t is a known check type.
+func (t HealthCheckType) Valid() bool {
+	switch t {
+	case HealthCheckHTTP, HealthCheckTCP, HealthCheckCommand:
+		return true
+	default:
+		return false
+	}
+}
+
+// normalized returns a copy with defaults filled in and fields the type does not use cleared.
+func (h HealthCheck) normalized() HealthCheck {
+	if h.Interval == 0 {
+		h.Interval = DefaultHealthInterval
+	}
+	if h.Timeout == 0 {
+		h.Timeout = DefaultHealthTimeout
+	}
+	if h.Retries == 0 {
+		h.Retries = DefaultHealthRetries
+	}
+	switch h.Type {
+	case HealthCheckHTTP:
+		h.Path = strings.TrimSpace(h.Path)
+		if h.Path == "" {
+			h.Path = "/"
+		}
+		h.Command = nil
+	case HealthCheckTCP:
+		h.Path, h.Command = "", nil
+	case HealthCheckCommand:
+		h.Path = ""
+		h.Command = slices.Clone(h.Command)
+	}
+	return h
+}
+
+// Equal reports whether two checks probe the same way.
+func (h HealthCheck) Equal(o HealthCheck) bool {
+	return h.Type == o.Type && h.Path == o.Path && slices.Equal(h.Command, o.Command) &&
+		h.Interval == o.Interval && h.Timeout == o.Timeout && h.Retries == o.Retries
+}
+
+// cloneHealthCheck returns a deep copy of hc, or nil.
+func cloneHealthCheck(hc *HealthCheck) *HealthCheck {
+	if hc == nil {
+		return nil
+	}
+	c := *hc
+	c.Command = slices.Clone(hc.Command)
+	return &c
+}
+
+// sameHealthCheck reports whether two optional checks are equal.
+func sameHealthCheck(a, b *HealthCheck) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,68 @@
+// Log lines from an app's workload and from its deploys
+// Workload output is read live from the runtime; each deployment's build log is stored with it
+// Every line records its source: stdout, stderr or the deploy step that wrote it
+// Levels are guessed from the line, falling back on the stream it came from
+// Timestamps come from the runtime and are in utc
+
+package domain
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// LogLevel is how severe a log line is
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "debug"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelWarn  LogLevel = "warn"
+	LogLevelError LogLevel = "error"
+)
+
+// Log sources of a workload's output.
+const (
+	LogSourceStdout = "stdout"
+	LogSourceStderr = "stderr"
+)
+
+// LogEntry is one line of a workload's output or of a deployment's build log
+type LogEntry struct {
+	Timestamp time.Time
+	Level     LogLevel
+	Message   string
+	Source    string
+}
+
+// logLevelWords maps the level names apps commonly print to a level.
+var logLevelWords = map[string]LogLevel{
+	"trace": LogLevelDebug, "debug": LogLevelDebug, "dbg": LogLevelDebug,
+	"info": LogLevelInfo, "notice": LogLevelInfo, "inf": LogLevelInfo,
+	"warn": LogLevelWarn, "warning": LogLevelWarn, "wrn": LogLevelWarn,
+	"error": LogLevelError, "err": LogLevelError, "fatal": LogLevelError, "panic": LogLevelError,
+	"crit": LogLevelError, "critical": LogLevelError, "alert": LogLevelError, "emerg": LogLevelError,
+}
+
+// logLevelScan bounds how far into a line a level name is looked for.
+const logLevelScan = 80
+
+// DetectLogLevel guesses the level of a line from the first level name near its start.
+// A line without one is info on stdout and error on stderr.
+func DetectLogLevel(source, message string) LogLevel {
+	head := message
+	if len(head) > logLevelScan {
+		head = head[:logLevelScan]
+	}
+	words := strings.FieldsFunc(strings.ToLower(head), func(r rune) bool { return !unicode.IsLetter(r) })
+	for _, w := range words {
+		if lvl, ok := logLevelWords[w]; ok {
+			return lvl
+		}
+	}
+	if source == LogSourceStderr {
+		return LogLevelError
+	}
+	return LogLevelInfo
+}
@@ -0,0 +1,41 @@
+// Tests for log lines read from a workload
+// Tests verify levels are picked up from common log formats
+// Tests verify lines without a level fall back on their stream
+// Tests verify a level name deep into a line is ignored
+// These tests guard how log levels are guessed
+
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// TestDetectLogLevel verifies level detection for common line shapes.
+func TestDetectLogLevel(t *testing.T) {
+	tests := []struct {
+		label   string
+		source  string
+		message string
+		want    domain.LogLevel
+	}{
+		{label: "logfmt", source: domain.LogSourceStdout, message: `time=2024-01-01 level=warn msg="slow"`, want: domain.LogLevelWarn},
+		{label: "json", source: domain.LogSourceStdout, message: `{"level":"error","msg":"boom"}`, want: domain.LogLevelError},
+		{label: "bracketed", source: domain.LogSourceStderr, message: "2024/01/01 12:00:00 [notice] 1#1: start worker", want: domain.LogLevelInfo},
+		{label: "prefix", source: domain.LogSourceStderr, message: "DEBUG: cache warmed", want: domain.LogLevelDebug},
+		{label: "python", source: domain.LogSourceStderr, message: "WARNING:root:disk almost full", want: domain.LogLevelWarn},
+		{label: "fatal", source: domain.LogSourceStdout, message: "FATAL could not bind", want: domain.LogLevelError},
+		{label: "plain stdout", source: domain.LogSourceStdout, message: "listening on :8080", want: domain.LogLevelInfo},
+		{label: "plain stderr", source: domain.LogSourceStderr, message: "listening on :8080", want: domain.LogLevelError},
+		{label: "word inside another", source: domain.LogSourceStdout, message: "information about errors", want: domain.LogLevelInfo},
+		{label: "too far in", source: domain.LogSourceStdout, message: strings.Repeat("x ", 50) + "error", want: domain.LogLevelInfo},
+	}
+	for _, tc := range tests {
+		t.Run(tc.label, func(t *testing.T) {
+			assert.Equal(t, tc.want, domain.DetectLogLevel(tc.source, tc.message))
+		})
+	}
+}
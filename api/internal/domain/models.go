@@ -21,8 +21,19 @@ const (
 	AppStatusRunning  AppStatus = "RUNNING"
 	AppStatusFailed   AppStatus = "FAILED"
 	AppStatusPaused   AppStatus = "PAUSED"
+	AppStatusDeleting AppStatus = "DELETING"
 )
 
+// Valid reports whether s is a known app status.
+func (s AppStatus) Valid() bool {
+	switch s {
+	case AppStatusCreated, AppStatusBuilding, AppStatusRunning, AppStatusFailed, AppStatusPaused, AppStatusDeleting:
+		return true
+	default:
+		return false
+	}
+}
+
 // DeploymentStatus represents the lifecycle state of a deployment
 type DeploymentStatus string
 
@@ -32,28 +43,67 @@ const (
 	DeploymentStatusDeploying DeploymentStatus = "DEPLOYING"
 	DeploymentStatusRunning   DeploymentStatus = "RUNNING"
 	DeploymentStatusFailed    DeploymentStatus = "FAILED"
+	DeploymentStatusCanceled  DeploymentStatus = "CANCELED"
 )
 
+// Valid reports whether s is a known deployment status.
+func (s DeploymentStatus) Valid() bool {
+	switch s {
+	case DeploymentStatusQueued, DeploymentStatusBuilding, DeploymentStatusDeploying,
+		DeploymentStatusRunning, DeploymentStatusFailed, DeploymentStatusCanceled:
+		return true
+	default:
+		return false
+	}
+}
+
+// InProgress reports whether a deployment is still waiting for or being worked on by a worker.
+func (s DeploymentStatus) InProgress() bool {
+	switch s {
+	case DeploymentStatusQueued, DeploymentStatusBuilding, DeploymentStatusDeploying:
+		return true
+	default:
+		return false
+	}
+}
+
+// AppStatus returns the app status that mirrors an app's latest deployment in this state.
+func (s DeploymentStatus) AppStatus() AppStatus {
+	switch s {
+	case DeploymentStatusRunning:
+		return AppStatusRunning
+	case DeploymentStatusFailed, DeploymentStatusCanceled:
+		return AppStatusFailed
+	default:
+		return AppStatusBuilding
+	}
+}
+
 // App is the core application model stored by the platform
+// Version starts at 1 and is bumped by the store on every update
+// HealthCheck is nil when the image's own HEALTHCHECK, if any, decides
 type App struct {
-	ID        string
-	Name      string
-	Image     string
-	Port      *int
-	Expose    bool
-	Env       map[string]string
-	Status    AppStatus
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          string
+	Name        string
+	Image       string
+	Port        *int
+	Expose      bool
+	Env         map[string]string
+	HealthCheck *HealthCheck
+	Status      AppStatus
+	Version     int64
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
 // NewAppParams holds the input used to construct an App
 type NewAppParams struct {
-	Name   string
-	Image  string
-	Port   *int
-	Expose *bool // nil defaults to true
-	Env    map[string]string
+	Name        string
+	Image       string
+	Port        *int
+	Expose      *bool // nil defaults to true
+	Env         map[string]string
+	HealthCheck *HealthCheck // validated and given defaults; nil leaves health to the image
 }
 
 // NewApp builds a validated App from input parameters.
@@ -73,6 +123,10 @@ func NewApp(p NewAppParams) (App, error) {
 	if err := ValidatePort(p.Port); err != nil {
 		return App{}, err
 	}
+	hc, err := NormalizeHealthCheck(p.HealthCheck)
+	if err != nil {
+		return App{}, err
+	}
 
 	var envCopy map[string]string
 	if p.Env != nil {
@@ -84,27 +138,113 @@ func NewApp(p NewAppParams) (App, error) {
 
 	now := time.Now().UTC()
 	return App{
-		ID:        uuid.NewString(),
-		Name:      p.Name,
-		Image:     p.Image,
-		Port:      p.Port,
-		Expose:    exposeVal,
-		Env:       envCopy,
-		Status:    AppStatusCreated,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:          uuid.NewString(),
+		Name:        p.Name,
+		Image:       p.Image,
+		Port:        p.Port,
+		Expose:      exposeVal,
+		Env:         envCopy,
+		HealthCheck: hc,
+		Status:      AppStatusCreated,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}, nil
 }
 
+// AppPatch holds the fields to change on an App
+// Nil fields are left alone; a non-nil empty Env clears the env
+// and a non-nil HealthCheck without a Type removes the health check
+type AppPatch struct {
+	Name        *string
+	Image       *string
+	Port        *int
+	Expose      *bool
+	Env         map[string]string
+	HealthCheck *HealthCheck
+}
+
+// Empty reports whether the patch changes nothing.
+func (p AppPatch) Empty() bool {
+	return p.Name == nil && p.Image == nil && p.Port == nil && p.Expose == nil && p.Env == nil &&
+		p.HealthCheck == nil
+}
+
+// Patch returns a validated copy of the app with the patch applied.
+// The same rules as NewApp apply to every field that is set.
+func (a App) Patch(p AppPatch) (App, error) {
+	if p.Name != nil {
+		if err := ValidateAppName(*p.Name); err != nil {
+			return App{}, err
+		}
+		a.Name = *p.Name
+	}
+	if p.Image != nil {
+		if err := ValidateImageRef(*p.Image); err != nil {
+			return App{}, err
+		}
+		a.Image = *p.Image
+	}
+	if p.Port != nil {
+		if err := ValidatePort(p.Port); err != nil {
+			return App{}, err
+		}
+		port := *p.Port
+		a.Port = &port
+	}
+	if p.Expose != nil {
+		a.Expose = *p.Expose
+	}
+	if p.Env != nil {
+		env := make(map[string]string, len(p.Env))
+		for k, v := range p.Env {
+			env[k] = v
+		}
+		a.Env = env
+	}
+	if p.HealthCheck != nil {
+		if p.HealthCheck.Type == "" {
+			a.HealthCheck = nil
+		} else {
+			hc, err := NormalizeHealthCheck(p.HealthCheck)
+			if err != nil {
+				return App{}, err
+			}
+			a.HealthCheck = hc
+		}
+	}
+	a.UpdatedAt = time.Now().UTC()
+	return a, nil
+}
+
 // Deployment tracks a single deployment attempt for an app
+// ClaimedBy, LeaseExpiresAt and Attempts are managed by the store when a worker claims it
+// Version starts at 1 and is bumped by the store on every status change
+// Status changes go through the transition methods, which also set StartedAt and CompletedAt
+// Spec is frozen when the deployment is requested and never changes afterwards
+// RollbackOf is the id of the earlier deployment whose spec a rollback redeploys
+// RetryOf is the id of the FAILED or CANCELED deployment a retry was cloned from
+// Steps track the runtime's progress and start over each time the deployment is started
+// RuntimePort is the port the runtime resolved from the spec or the image; nil until it does or when not exposed
 type Deployment struct {
-	ID        string
-	AppID     string
-	Status    DeploymentStatus
-	URL       *string
-	Error     *string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID             string
+	AppID          string
+	Spec           DeploymentSpec
+	RollbackOf     *string
+	RetryOf        *string
+	Steps          []DeploymentStep
+	RuntimePort    *int
+	Status         DeploymentStatus
+	URL            *string
+	Error          *string
+	ClaimedBy      *string
+	LeaseExpiresAt *time.Time
+	Attempts       int
+	Version        int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	StartedAt      *time.Time
+	CompletedAt    *time.Time
 }
 
 // NewDeployment builds a queued Deployment for an app.
@@ -114,6 +254,8 @@ func NewDeployment(appID string) Deployment {
 		ID:        uuid.NewString(),
 		AppID:     appID,
 		Status:    DeploymentStatusQueued,
+		Steps:     NewDeploySteps(),
+		Version:   1,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
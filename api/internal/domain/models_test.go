@@ -92,3 +92,126 @@ func TestNewDeployment(t *testing.T) {
 		})
 	}
 }
+
+// TestDeploymentStatusAppStatus verifies which app status mirrors each deployment status.
+func TestDeploymentStatusAppStatus(t *testing.T) {
+	tests := []struct {
+		dep  domain.DeploymentStatus
+		want domain.AppStatus
+	}{
+		{domain.DeploymentStatusQueued, domain.AppStatusBuilding},
+		{domain.DeploymentStatusBuilding, domain.AppStatusBuilding},
+		{domain.DeploymentStatusDeploying, domain.AppStatusBuilding},
+		{domain.DeploymentStatusRunning, domain.AppStatusRunning},
+		{domain.DeploymentStatusFailed, domain.AppStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.dep), func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.dep.AppStatus())
+		})
+	}
+}
+
+// TestSnapshotSpec verifies specs copy the app and hash its env stably.
+func TestSnapshotSpec(t *testing.T) {
+	app, err := domain.NewApp(domain.NewAppParams{
+		Name:  "hello",
+		Image: "nginx:latest",
+		Port:  ptrInt(8080),
+		Env:   map[string]string{"A": "1", "B": "2"},
+	})
+	assert.NoError(t, err)
+
+	spec := domain.SnapshotSpec(app)
+	assert.Equal(t, "nginx:latest", spec.Image)
+	assert.Equal(t, 8080, *spec.Port)
+	assert.True(t, spec.Expose)
+	assert.Equal(t, app.Env, spec.Env)
+	assert.Len(t, spec.EnvHash, 64)
+
+	// The spec owns its copies.
+	app.Env["A"] = "changed"
+	*app.Port = 9090
+	assert.Equal(t, "1", spec.Env["A"])
+	assert.Equal(t, 8080, *spec.Port)
+
+	assert.Equal(t, domain.HashEnv(map[string]string{"B": "2", "A": "1"}), spec.EnvHash)
+	assert.NotEqual(t, domain.HashEnv(map[string]string{"A": "12"}), domain.HashEnv(map[string]string{"A": "1", "2": ""}))
+	assert.Equal(t, domain.HashEnv(nil), domain.HashEnv(map[string]string{}))
+
+	deployed := spec.Apply(domain.App{ID: "app-1", Name: "hello", Image: "other"})
+	assert.Equal(t, "app-1", deployed.ID)
+	assert.Equal(t, "nginx:latest", deployed.Image)
+	assert.Nil(t, deployed.HealthCheck)
+
+	// A health check change makes the app differ from what was deployed.
+	app.Env["A"] = "1"
+	*app.Port = 8080
+	assert.True(t, spec.Same(domain.SnapshotSpec(app)))
+	app.HealthCheck = &domain.HealthCheck{Type: domain.HealthCheckTCP}
+	checked := domain.SnapshotSpec(app)
+	assert.False(t, spec.Same(checked))
+	assert.Equal(t, app.HealthCheck, checked.Apply(app).HealthCheck)
+}
+
+// TestAppPatch verifies partial updates validate and copy their input.
+func TestAppPatch(t *testing.T) {
+	app, err := domain.NewApp(domain.NewAppParams{Name: "hello", Image: "nginx:latest", Env: map[string]string{"A": "1"}})
+	assert.NoError(t, err)
+
+	t.Run("applies set fields only", func(t *testing.T) {
+		name := "renamed"
+		port := 9090
+		got, err := app.Patch(domain.AppPatch{Name: &name, Port: &port})
+		assert.NoError(t, err)
+		assert.Equal(t, "renamed", got.Name)
+		assert.Equal(t, 9090, *got.Port)
+		assert.Equal(t, app.Image, got.Image)
+		assert.Equal(t, app.Env, got.Env)
+		assert.Equal(t, app.ID, got.ID)
+		assert.False(t, got.UpdatedAt.Before(app.UpdatedAt))
+
+		port = 1
+		assert.Equal(t, 9090, *got.Port)
+	})
+
+	t.Run("empty env clears", func(t *testing.T) {
+		got, err := app.Patch(domain.AppPatch{Env: map[string]string{}})
+		assert.NoError(t, err)
+		assert.Empty(t, got.Env)
+		assert.Equal(t, "1", app.Env["A"])
+	})
+
+	t.Run("invalid fields rejected", func(t *testing.T) {
+		bad := "Bad_Name"
+		_, err := app.Patch(domain.AppPatch{Name: &bad})
+		assert.ErrorIs(t, err, domain.ErrInvalidAppName)
+
+		blank := " "
+		_, err = app.Patch(domain.AppPatch{Image: &blank})
+		assert.ErrorIs(t, err, domain.ErrInvalidImage)
+
+		port := 70000
+		_, err = app.Patch(domain.AppPatch{Port: &port})
+		assert.ErrorIs(t, err, domain.ErrInvalidPort)
+	})
+
+	t.Run("health check set and cleared", func(t *testing.T) {
+		got, err := app.Patch(domain.AppPatch{HealthCheck: &domain.HealthCheck{Type: domain.HealthCheckHTTP, Path: "/healthz"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "/healthz", got.HealthCheck.Path)
+		assert.Equal(t, domain.DefaultHealthRetries, got.HealthCheck.Retries)
+		assert.Nil(t, app.HealthCheck)
+
+		_, err = got.Patch(domain.AppPatch{HealthCheck: &domain.HealthCheck{Type: domain.HealthCheckCommand}})
+		assert.ErrorIs(t, err, domain.ErrInvalidHealthCheck)
+
+		cleared, err := got.Patch(domain.AppPatch{HealthCheck: &domain.HealthCheck{}})
+		assert.NoError(t, err)
+		assert.Nil(t, cleared.HealthCheck)
+	})
+
+	assert.True(t, domain.AppPatch{}.Empty())
+	assert.False(t, domain.AppPatch{Env: map[string]string{}}.Empty())
+}
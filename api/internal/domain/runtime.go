@@ -0,0 +1,27 @@
+// Runtime status reported for an app's running workload
+// The runtime is asked directly, so this is live state and is never stored
+// State is reduced to running, restarting or exited
+// Anything that is neither running nor restarting counts as exited
+// StartedAt is nil when the workload never started
+
+package domain
+
+import "time"
+
+// RuntimeState is what an app's workload is doing right now
+type RuntimeState string
+
+const (
+	RuntimeStateRunning    RuntimeState = "running"
+	RuntimeStateRestarting RuntimeState = "restarting"
+	RuntimeStateExited     RuntimeState = "exited"
+)
+
+// RuntimeStatus is a snapshot of an app's workload as the runtime sees it
+// ExitCode is only meaningful once the workload has exited
+type RuntimeStatus struct {
+	State        RuntimeState
+	ExitCode     int
+	StartedAt    *time.Time
+	RestartCount int
+}
@@ -0,0 +1,84 @@
+// Deployment specs frozen when a deployment is requested
+// A spec records the image, port, exposure, env and health check the deployment runs
+// Edits to the app after that do not change a queued deployment
+// Env values stay internal; the hash tells deployments apart without showing secrets
+// Hashes are stable because keys are sorted before hashing
+
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// DeploymentSpec is what a deployment runs
+// Port is nil when the runtime should take the port from the image
+// HealthCheck is nil when the image's own HEALTHCHECK, if any, decides
+type DeploymentSpec struct {
+	Image       string
+	Port        *int
+	Expose      bool
+	Env         map[string]string
+	EnvHash     string
+	HealthCheck *HealthCheck
+}
+
+// SnapshotSpec copies the deployable fields of an app into a spec.
+func SnapshotSpec(app App) DeploymentSpec {
+	spec := DeploymentSpec{
+		Image:       app.Image,
+		Expose:      app.Expose,
+		EnvHash:     HashEnv(app.Env),
+		HealthCheck: cloneHealthCheck(app.HealthCheck),
+	}
+	if app.Port != nil {
+		port := *app.Port
+		spec.Port = &port
+	}
+	if app.Env != nil {
+		spec.Env = make(map[string]string, len(app.Env))
+		for k, v := range app.Env {
+			spec.Env[k] = v
+		}
+	}
+	return spec
+}
+
+// Apply returns app with its deployable fields replaced by the spec.
+// The app keeps its id and name so the runtime still knows whose container it runs.
+func (s DeploymentSpec) Apply(app App) App {
+	app.Image = s.Image
+	app.Port = s.Port
+	app.Expose = s.Expose
+	app.Env = s.Env
+	app.HealthCheck = s.HealthCheck
+	return app
+}
+
+// Same reports whether two specs would deploy the same container.
+func (s DeploymentSpec) Same(o DeploymentSpec) bool {
+	samePort := (s.Port == nil) == (o.Port == nil) && (s.Port == nil || *s.Port == *o.Port)
+	return s.Image == o.Image && samePort && s.Expose == o.Expose && s.EnvHash == o.EnvHash &&
+		sameHealthCheck(s.HealthCheck, o.HealthCheck)
+}
+
+// HashEnv returns a hex sha256 over the sorted env entries.
+// An empty or nil env hashes to the same value.
+func HashEnv(env map[string]string) string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		// NUL cannot appear in env keys or values, so entries never run together.
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(env[k]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,142 @@
+// Deployment steps and their timings
+// Every deployment starts with the standard plan of steps, all pending
+// The runtime reports each step as it starts and finishes
+// Steps left pending when a deployment ends are marked skipped
+// Step changes copy the slice so stored records never share it
+
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// StepID names one step of a deployment
+type StepID string
+
+// The standard deploy steps, in the order a runtime runs them.
+const (
+	StepPullImage        StepID = "pull_image"
+	StepResolvePort      StepID = "resolve_port"
+	StepStartContainer   StepID = "start_container"
+	StepHealthCheck      StepID = "health_check"
+	StepReplaceContainer StepID = "replace_container"
+)
+
+// stepNames are the display names of the standard steps.
+var stepNames = map[StepID]string{
+	StepPullImage:        "Pull image",
+	StepResolvePort:      "Resolve port",
+	StepStartContainer:   "Start container",
+	StepHealthCheck:      "Health check",
+	StepReplaceContainer: "Replace container",
+}
+
+// deployPlan is the order of the standard steps.
+var deployPlan = []StepID{StepPullImage, StepResolvePort, StepStartContainer, StepHealthCheck, StepReplaceContainer}
+
+// StepStatus is where a deployment step stands
+type StepStatus string
+
+const (
+	StepStatusPending   StepStatus = "pending"
+	StepStatusRunning   StepStatus = "running"
+	StepStatusCompleted StepStatus = "completed"
+	StepStatusFailed    StepStatus = "failed"
+	StepStatusSkipped   StepStatus = "skipped"
+)
+
+// DeploymentStep is one step of a deployment
+// StartedAt and CompletedAt are nil until the step starts or ends; Error is set when it failed
+type DeploymentStep struct {
+	ID          StepID
+	Name        string
+	Status      StepStatus
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+	Error       *string
+}
+
+// NewDeploySteps returns the standard plan with every step pending.
+func NewDeploySteps() []DeploymentStep {
+	steps := make([]DeploymentStep, 0, len(deployPlan))
+	for _, id := range deployPlan {
+		steps = append(steps, DeploymentStep{ID: id, Name: stepNames[id], Status: StepStatusPending})
+	}
+	return steps
+}
+
+// StartStep marks a step running. A step outside the plan is appended.
+func (d *Deployment) StartStep(id StepID) {
+	now := time.Now().UTC()
+	d.editStep(id, func(s *DeploymentStep) {
+		s.Status = StepStatusRunning
+		s.StartedAt = &now
+		s.CompletedAt = nil
+		s.Error = nil
+	})
+}
+
+// FinishStep marks a step completed, or failed with err's message when err is not nil.
+func (d *Deployment) FinishStep(id StepID, err error) {
+	now := time.Now().UTC()
+	d.editStep(id, func(s *DeploymentStep) {
+		if s.StartedAt == nil {
+			s.StartedAt = &now
+		}
+		s.CompletedAt = &now
+		s.Status = StepStatusCompleted
+		if err != nil {
+			msg := err.Error()
+			s.Status = StepStatusFailed
+			s.Error = &msg
+		}
+	})
+}
+
+// endSteps settles steps when the deployment finishes.
+// A step still running completes when the deployment succeeded and fails with reason otherwise;
+// steps that never started are skipped.
+func (d *Deployment) endSteps(succeeded bool, reason string) {
+	if len(d.Steps) == 0 {
+		return
+	}
+	d.Steps = slices.Clone(d.Steps)
+	now := time.Now().UTC()
+	for i := range d.Steps {
+		s := &d.Steps[i]
+		switch s.Status {
+		case StepStatusPending:
+			s.Status = StepStatusSkipped
+		case StepStatusRunning:
+			s.CompletedAt = &now
+			s.Status = StepStatusCompleted
+			if !succeeded {
+				msg := reason
+				s.Status = StepStatusFailed
+				s.Error = &msg
+			}
+		}
+	}
+}
+
+// Switching reports whether the runtime began moving traffic to the new container, which cannot be undone.
+func (d *Deployment) Switching() bool {
+	i := slices.IndexFunc(d.Steps, func(s DeploymentStep) bool { return s.ID == StepReplaceContainer })
+	return i >= 0 && d.Steps[i].Status != StepStatusPending
+}
+
+// editStep applies fn to a copy of the step list, appending the step when it is not in the list.
+func (d *Deployment) editStep(id StepID, fn func(*DeploymentStep)) {
+	d.Steps = slices.Clone(d.Steps)
+	i := slices.IndexFunc(d.Steps, func(s DeploymentStep) bool { return s.ID == id })
+	if i < 0 {
+		name := stepNames[id]
+		if name == "" {
+			name = string(id)
+		}
+		d.Steps = append(d.Steps, DeploymentStep{ID: id, Name: name, Status: StepStatusPending})
+		i = len(d.Steps) - 1
+	}
+	fn(&d.Steps[i])
+}
@@ -0,0 +1,152 @@
+// Tests for deployment steps
+// Tests verify new deployments start with the standard plan
+// Tests verify step reports stamp status, timings and errors
+// Tests verify finishing a deployment settles the steps left open
+// Tests verify step changes never touch an earlier copy
+
+package domain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// stepStatuses returns the status of every step in order.
+func stepStatuses(steps []domain.DeploymentStep) []domain.StepStatus {
+	out := make([]domain.StepStatus, 0, len(steps))
+	for _, s := range steps {
+		out = append(out, s.Status)
+	}
+	return out
+}
+
+// TestNewDeploymentSteps verifies the standard plan is pending.
+func TestNewDeploymentSteps(t *testing.T) {
+	dep := domain.NewDeployment("app-1")
+	ids := make([]domain.StepID, 0, len(dep.Steps))
+	for _, s := range dep.Steps {
+		ids = append(ids, s.ID)
+		assert.Equal(t, domain.StepStatusPending, s.Status)
+		assert.NotEmpty(t, s.Name)
+		assert.Nil(t, s.StartedAt)
+		assert.Nil(t, s.CompletedAt)
+		assert.Nil(t, s.Error)
+	}
+	assert.Equal(t, []domain.StepID{
+		domain.StepPullImage, domain.StepResolvePort, domain.StepStartContainer,
+		domain.StepHealthCheck, domain.StepReplaceContainer,
+	}, ids)
+}
+
+// TestDeploymentStepReports verifies start and finish reports and how each outcome settles the rest.
+func TestDeploymentStepReports(t *testing.T) {
+	tests := []struct {
+		name   string
+		finish func(d *domain.Deployment) error
+		want   []domain.StepStatus
+		reason string
+	}{
+		{
+			name:   "succeed",
+			finish: func(d *domain.Deployment) error { return d.Succeed(nil) },
+			want: []domain.StepStatus{
+				domain.StepStatusCompleted, domain.StepStatusCompleted, domain.StepStatusCompleted,
+				domain.StepStatusSkipped, domain.StepStatusSkipped,
+			},
+		},
+		{
+			name:   "fail",
+			finish: func(d *domain.Deployment) error { return d.Fail("boom") },
+			want: []domain.StepStatus{
+				domain.StepStatusCompleted, domain.StepStatusCompleted, domain.StepStatusFailed,
+				domain.StepStatusSkipped, domain.StepStatusSkipped,
+			},
+			reason: "boom",
+		},
+		{
+			name:   "cancel",
+			finish: func(d *domain.Deployment) error { return d.Cancel() },
+			want: []domain.StepStatus{
+				domain.StepStatusCompleted, domain.StepStatusCompleted, domain.StepStatusFailed,
+				domain.StepStatusSkipped, domain.StepStatusSkipped,
+			},
+			reason: "canceled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dep := domain.NewDeployment("app-1")
+			require.NoError(t, dep.Start())
+			require.NoError(t, dep.MarkDeploying())
+			dep.StartStep(domain.StepPullImage)
+			assert.Equal(t, domain.StepStatusRunning, dep.Steps[0].Status)
+			require.NotNil(t, dep.Steps[0].StartedAt)
+			dep.FinishStep(domain.StepPullImage, nil)
+			dep.StartStep(domain.StepResolvePort)
+			dep.FinishStep(domain.StepResolvePort, nil)
+			// The third step is still running when the deployment ends
+			dep.StartStep(domain.StepStartContainer)
+
+			require.NoError(t, tt.finish(&dep))
+			assert.Equal(t, tt.want, stepStatuses(dep.Steps))
+			running := dep.Steps[2]
+			require.NotNil(t, running.CompletedAt)
+			if tt.reason == "" {
+				assert.Nil(t, running.Error)
+			} else if assert.NotNil(t, running.Error) {
+				assert.Equal(t, tt.reason, *running.Error)
+			}
+			assert.Nil(t, dep.Steps[3].StartedAt)
+		})
+	}
+}
+
+// TestDeploymentStepFailure verifies a failed step keeps the error and its timings.
+func TestDeploymentStepFailure(t *testing.T) {
+	dep := domain.NewDeployment("app-1")
+	dep.StartStep(domain.StepHealthCheck)
+	dep.FinishStep(domain.StepHealthCheck, errors.New("unhealthy"))
+
+	s := dep.Steps[3]
+	assert.Equal(t, domain.StepStatusFailed, s.Status)
+	require.NotNil(t, s.Error)
+	assert.Equal(t, "unhealthy", *s.Error)
+	require.NotNil(t, s.StartedAt)
+	require.NotNil(t, s.CompletedAt)
+	assert.False(t, s.CompletedAt.Before(*s.StartedAt))
+
+	// A finish without a start still gets a start time
+	dep.FinishStep(domain.StepReplaceContainer, nil)
+	assert.NotNil(t, dep.Steps[4].StartedAt)
+}
+
+// TestDeploymentStepExtra verifies a step outside the plan is appended.
+func TestDeploymentStepExtra(t *testing.T) {
+	dep := domain.NewDeployment("app-1")
+	dep.StartStep("migrate")
+	require.Len(t, dep.Steps, 6)
+	assert.Equal(t, domain.StepID("migrate"), dep.Steps[5].ID)
+	assert.Equal(t, "migrate", dep.Steps[5].Name)
+	assert.Equal(t, domain.StepStatusRunning, dep.Steps[5].Status)
+}
+
+// TestDeploymentStepsCopyOnWrite verifies step changes leave earlier copies alone.
+func TestDeploymentStepsCopyOnWrite(t *testing.T) {
+	dep := domain.NewDeployment("app-1")
+	require.NoError(t, dep.Start())
+	before := dep
+
+	dep.StartStep(domain.StepPullImage)
+	require.NoError(t, dep.Fail("boom"))
+	assert.Equal(t, domain.StepStatusPending, before.Steps[0].Status)
+	assert.Equal(t, domain.StepStatusPending, before.Steps[1].Status)
+
+	// Starting again begins a fresh plan
+	dep.Status = domain.DeploymentStatusQueued
+	require.NoError(t, dep.Start())
+	assert.Equal(t, domain.NewDeploySteps(), dep.Steps)
+}
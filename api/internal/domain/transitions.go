@@ -0,0 +1,139 @@
+// Deployment state machine and its legal transitions
+// Status only moves along the edges listed in the transition table
+// Each move goes through a method that also stamps the timestamps
+// Illegal moves return a TransitionError and leave the deployment unchanged
+// RUNNING, FAILED and CANCELED are final, and a deploy switching traffic can no longer be canceled
+
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidTransition is matched by every TransitionError.
+var ErrInvalidTransition = errors.New("invalid deployment transition")
+
+// ErrSwitching is returned when canceling a deployment whose runtime already began switching traffic.
+var ErrSwitching = errors.New("deployment is already switching traffic to its new container")
+
+// TransitionError reports an illegal deployment status change.
+type TransitionError struct {
+	From DeploymentStatus
+	To   DeploymentStatus
+}
+
+// Error describes the rejected move.
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("invalid deployment transition from %s to %s", e.From, e.To)
+}
+
+// Unwrap lets errors.Is match ErrInvalidTransition.
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// transitions lists the statuses each status may move to.
+// In-progress deployments also go back to QUEUED through Requeue when their worker stops or is lost.
+var transitions = map[DeploymentStatus][]DeploymentStatus{
+	DeploymentStatusQueued:    {DeploymentStatusBuilding, DeploymentStatusFailed, DeploymentStatusCanceled},
+	DeploymentStatusBuilding:  {DeploymentStatusDeploying, DeploymentStatusFailed, DeploymentStatusCanceled, DeploymentStatusQueued},
+	DeploymentStatusDeploying: {DeploymentStatusRunning, DeploymentStatusFailed, DeploymentStatusCanceled, DeploymentStatusQueued},
+}
+
+// CanTransition reports whether a deployment may move from one status to another.
+func CanTransition(from, to DeploymentStatus) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Start moves a queued deployment to BUILDING and records when work began.
+func (d *Deployment) Start() error {
+	if err := d.moveTo(DeploymentStatusBuilding); err != nil {
+		return err
+	}
+	d.StartedAt = d.stamp()
+	d.CompletedAt = nil
+	d.Error = nil
+	d.Steps = NewDeploySteps()
+	return nil
+}
+
+// MarkDeploying moves a building deployment to DEPLOYING once the runtime takes over.
+func (d *Deployment) MarkDeploying() error {
+	return d.moveTo(DeploymentStatusDeploying)
+}
+
+// Succeed marks a deploying deployment RUNNING at url, which is nil when the app is not exposed.
+func (d *Deployment) Succeed(url *string) error {
+	if err := d.moveTo(DeploymentStatusRunning); err != nil {
+		return err
+	}
+	d.URL = url
+	d.Error = nil
+	d.CompletedAt = d.stamp()
+	d.endSteps(true, "")
+	return nil
+}
+
+// Fail marks an unfinished deployment FAILED with a reason.
+func (d *Deployment) Fail(reason string) error {
+	if err := d.moveTo(DeploymentStatusFailed); err != nil {
+		return err
+	}
+	d.Error = &reason
+	d.CompletedAt = d.stamp()
+	d.endSteps(false, reason)
+	return nil
+}
+
+// Cancel stops an unfinished deployment that has not begun switching traffic.
+func (d *Deployment) Cancel() error {
+	if d.Switching() {
+		return ErrSwitching
+	}
+	if err := d.moveTo(DeploymentStatusCanceled); err != nil {
+		return err
+	}
+	d.CompletedAt = d.stamp()
+	d.endSteps(false, "canceled")
+	return nil
+}
+
+// Requeue puts a building or deploying deployment back in the queue after its worker stopped or was lost.
+// The claim and what the interrupted run recorded are dropped so the next run starts clean.
+func (d *Deployment) Requeue() error {
+	if err := d.moveTo(DeploymentStatusQueued); err != nil {
+		return err
+	}
+	d.StartedAt = nil
+	d.CompletedAt = nil
+	d.URL = nil
+	d.Error = nil
+	d.Steps = NewDeploySteps()
+	d.RuntimePort = nil
+	d.ClaimedBy = nil
+	d.LeaseExpiresAt = nil
+	return nil
+}
+
+// stamp returns a copy of the last transition time for StartedAt or CompletedAt.
+func (d *Deployment) stamp() *time.Time {
+	t := d.UpdatedAt
+	return &t
+}
+
+// moveTo validates and applies a status change.
+func (d *Deployment) moveTo(to DeploymentStatus) error {
+	if !CanTransition(d.Status, to) {
+		return &TransitionError{From: d.Status, To: to}
+	}
+	d.Status = to
+	d.UpdatedAt = time.Now().UTC()
+	return nil
+}
@@ -0,0 +1,158 @@
+// Tests for the deployment state machine
+// Tests walk every method through legal and illegal moves
+// Tests verify timestamps are stamped on each move
+// Tests verify rejected moves, including a cancel while switching traffic, leave the deployment unchanged
+// These tests guard the transition table
+
+package domain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// TestDeploymentTransitions verifies each method against every starting status.
+func TestDeploymentTransitions(t *testing.T) {
+	url := "https://hello.example.com"
+	moves := map[string]struct {
+		apply func(d *domain.Deployment) error
+		to    domain.DeploymentStatus
+		from  []domain.DeploymentStatus
+	}{
+		"Start": {
+			apply: func(d *domain.Deployment) error { return d.Start() },
+			to:    domain.DeploymentStatusBuilding,
+			from:  []domain.DeploymentStatus{domain.DeploymentStatusQueued},
+		},
+		"MarkDeploying": {
+			apply: func(d *domain.Deployment) error { return d.MarkDeploying() },
+			to:    domain.DeploymentStatusDeploying,
+			from:  []domain.DeploymentStatus{domain.DeploymentStatusBuilding},
+		},
+		"Succeed": {
+			apply: func(d *domain.Deployment) error { return d.Succeed(&url) },
+			to:    domain.DeploymentStatusRunning,
+			from:  []domain.DeploymentStatus{domain.DeploymentStatusDeploying},
+		},
+		"Fail": {
+			apply: func(d *domain.Deployment) error { return d.Fail("boom") },
+			to:    domain.DeploymentStatusFailed,
+			from: []domain.DeploymentStatus{
+				domain.DeploymentStatusQueued, domain.DeploymentStatusBuilding, domain.DeploymentStatusDeploying,
+			},
+		},
+		"Requeue": {
+			apply: func(d *domain.Deployment) error { return d.Requeue() },
+			to:    domain.DeploymentStatusQueued,
+			from:  []domain.DeploymentStatus{domain.DeploymentStatusBuilding, domain.DeploymentStatusDeploying},
+		},
+		"Cancel": {
+			apply: func(d *domain.Deployment) error { return d.Cancel() },
+			to:    domain.DeploymentStatusCanceled,
+			from: []domain.DeploymentStatus{
+				domain.DeploymentStatusQueued, domain.DeploymentStatusBuilding, domain.DeploymentStatusDeploying,
+			},
+		},
+	}
+	all := []domain.DeploymentStatus{
+		domain.DeploymentStatusQueued, domain.DeploymentStatusBuilding, domain.DeploymentStatusDeploying,
+		domain.DeploymentStatusRunning, domain.DeploymentStatusFailed, domain.DeploymentStatusCanceled,
+	}
+
+	for name, mv := range moves {
+		for _, from := range all {
+			allowed := false
+			for _, f := range mv.from {
+				allowed = allowed || f == from
+			}
+			t.Run(name+"/"+string(from), func(t *testing.T) {
+				dep := domain.NewDeployment("app-1")
+				dep.Status = from
+				before := dep
+
+				err := mv.apply(&dep)
+				if !allowed {
+					var te *domain.TransitionError
+					require.True(t, errors.As(err, &te))
+					assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+					assert.Equal(t, from, te.From)
+					assert.Equal(t, mv.to, te.To)
+					assert.Equal(t, before, dep)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, mv.to, dep.Status)
+				assert.WithinDuration(t, time.Now().UTC(), dep.UpdatedAt, 2*time.Second)
+			})
+		}
+	}
+}
+
+// TestDeploymentLifecycleTimestamps verifies a full run stamps start and completion.
+func TestDeploymentLifecycleTimestamps(t *testing.T) {
+	dep := domain.NewDeployment("app-1")
+	assert.Nil(t, dep.StartedAt)
+	assert.Nil(t, dep.CompletedAt)
+
+	require.NoError(t, dep.Start())
+	require.NotNil(t, dep.StartedAt)
+	assert.Nil(t, dep.CompletedAt)
+	started := *dep.StartedAt
+
+	time.Sleep(time.Millisecond)
+	require.NoError(t, dep.MarkDeploying())
+	assert.Equal(t, started, *dep.StartedAt)
+	require.NoError(t, dep.Fail("boom"))
+	require.NotNil(t, dep.CompletedAt)
+	require.NotNil(t, dep.Error)
+	assert.Equal(t, "boom", *dep.Error)
+	assert.False(t, dep.CompletedAt.Before(*dep.StartedAt))
+
+	err := dep.Succeed(nil)
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+	assert.Equal(t, domain.DeploymentStatusFailed, dep.Status)
+}
+
+// TestDeploymentRequeue verifies a requeued deployment drops its claim and the interrupted run.
+func TestDeploymentRequeue(t *testing.T) {
+	dep := domain.NewDeployment("app-1")
+	worker := "worker-1"
+	expires := time.Now().UTC().Add(time.Minute)
+	dep.ClaimedBy, dep.LeaseExpiresAt = &worker, &expires
+	require.NoError(t, dep.Start())
+	dep.StartStep(domain.StepPullImage)
+	require.NoError(t, dep.MarkDeploying())
+
+	require.NoError(t, dep.Requeue())
+	assert.Equal(t, domain.DeploymentStatusQueued, dep.Status)
+	assert.Nil(t, dep.StartedAt)
+	assert.Nil(t, dep.CompletedAt)
+	assert.Nil(t, dep.ClaimedBy)
+	assert.Nil(t, dep.LeaseExpiresAt)
+	assert.Equal(t, domain.NewDeploySteps(), dep.Steps)
+
+	err := dep.Requeue()
+	assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+}
+
+// TestDeploymentCancelWhileSwitching verifies a deploy that began switching traffic can no longer be canceled.
+func TestDeploymentCancelWhileSwitching(t *testing.T) {
+	dep := domain.NewDeployment("app-1")
+	require.NoError(t, dep.Start())
+	require.NoError(t, dep.MarkDeploying())
+	dep.FinishStep(domain.StepHealthCheck, nil)
+	assert.False(t, dep.Switching())
+
+	dep.StartStep(domain.StepReplaceContainer)
+	assert.True(t, dep.Switching())
+	err := dep.Cancel()
+	assert.ErrorIs(t, err, domain.ErrSwitching)
+	assert.Equal(t, domain.DeploymentStatusDeploying, dep.Status)
+
+	require.NoError(t, dep.Succeed(nil))
+}
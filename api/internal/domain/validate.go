@@ -2,12 +2,14 @@
 // App names follow allowed patterns for safety
 // Image refs must be present to deploy
 // Port values are validated when provided
+// Health checks are validated and given defaults when provided
 // Errors are returned for invalid inputs
 
 package domain
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 )
@@ -17,6 +19,8 @@ var (
 	ErrInvalidAppName = errors.New("invalid app name")
 	ErrInvalidImage   = errors.New("invalid image ref")
 	ErrInvalidPort    = errors.New("invalid port")
+	// ErrInvalidHealthCheck is wrapped with the reason a health check was rejected.
+	ErrInvalidHealthCheck = errors.New("invalid health check")
 
 	// lowercase letters digits seperated by single hyphens
 	appNameRe = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
@@ -50,3 +54,24 @@ func ValidatePort(port *int) error {
 	}
 	return nil
 }
+
+// NormalizeHealthCheck validates an optional health check and returns a copy with defaults filled in.
+func NormalizeHealthCheck(hc *HealthCheck) (*HealthCheck, error) {
+	if hc == nil {
+		return nil, nil
+	}
+	if !hc.Type.Valid() {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidHealthCheck, hc.Type)
+	}
+	if hc.Interval < 0 || hc.Timeout < 0 || hc.Retries < 0 {
+		return nil, fmt.Errorf("%w: interval, timeout and retries must not be negative", ErrInvalidHealthCheck)
+	}
+	out := hc.normalized()
+	if out.Type == HealthCheckHTTP && !strings.HasPrefix(out.Path, "/") {
+		return nil, fmt.Errorf("%w: path must start with /", ErrInvalidHealthCheck)
+	}
+	if out.Type == HealthCheckCommand && len(out.Command) == 0 {
+		return nil, fmt.Errorf("%w: command is required", ErrInvalidHealthCheck)
+	}
+	return &out, nil
+}
@@ -1,4 +1,4 @@
-// Tests for app name image and port validation
+// Tests for app name image port and health check validation
 // Tests include valid and invalid examples
 // Port tests include nil and range checks
 // Validation errors are expected for bad inputs
@@ -9,6 +9,7 @@ package domain_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/t0gun/spacescale/internal/domain"
@@ -112,3 +113,52 @@ func TestValidatePort(t *testing.T) {
 		})
 	}
 }
+
+// TestNormalizeHealthCheck verifies health check validation and defaults.
+func TestNormalizeHealthCheck(t *testing.T) {
+	tests := []struct {
+		label string
+		in    *domain.HealthCheck
+		want  *domain.HealthCheck
+		ok    bool
+	}{
+		{label: "nil", ok: true},
+		{
+			label: "http defaults",
+			in:    &domain.HealthCheck{Type: domain.HealthCheckHTTP},
+			want: &domain.HealthCheck{Type: domain.HealthCheckHTTP, Path: "/", Interval: domain.DefaultHealthInterval,
+				Timeout: domain.DefaultHealthTimeout, Retries: domain.DefaultHealthRetries},
+			ok: true,
+		},
+		{
+			label: "tcp drops unused fields",
+			in:    &domain.HealthCheck{Type: domain.HealthCheckTCP, Path: "/x", Command: []string{"true"}, Interval: time.Second, Timeout: time.Second, Retries: 1},
+			want:  &domain.HealthCheck{Type: domain.HealthCheckTCP, Interval: time.Second, Timeout: time.Second, Retries: 1},
+			ok:    true,
+		},
+		{
+			label: "command",
+			in:    &domain.HealthCheck{Type: domain.HealthCheckCommand, Command: []string{"pg_isready"}},
+			want: &domain.HealthCheck{Type: domain.HealthCheckCommand, Command: []string{"pg_isready"}, Interval: domain.DefaultHealthInterval,
+				Timeout: domain.DefaultHealthTimeout, Retries: domain.DefaultHealthRetries},
+			ok: true,
+		},
+
+		{label: "unknown type", in: &domain.HealthCheck{Type: "grpc"}},
+		{label: "relative path", in: &domain.HealthCheck{Type: domain.HealthCheckHTTP, Path: "healthz"}},
+		{label: "missing command", in: &domain.HealthCheck{Type: domain.HealthCheckCommand}},
+		{label: "negative retries", in: &domain.HealthCheck{Type: domain.HealthCheckTCP, Retries: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			got, err := domain.NormalizeHealthCheck(tt.in)
+			if !tt.ok {
+				assert.ErrorIs(t, err, domain.ErrInvalidHealthCheck)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -10,65 +10,451 @@ import (
 	"time"
 
 	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
 )
 
 // createAppReq is the request body for creating an app
 type createAppReq struct {
+	Name        string            `json:"name"`
+	Image       string            `json:"image"`
+	Port        *int              `json:"port,omitempty"`
+	Expose      *bool             `json:"expose,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	HealthCheck *healthCheckDTO   `json:"healthCheck,omitempty"`
+}
+
+// updateAppReq is the request body for a partial app update
+// Omitted fields are left alone; an empty env object clears the env
+// and a health check object without a type removes the health check
+type updateAppReq struct {
+	Name        *string           `json:"name,omitempty"`
+	Image       *string           `json:"image,omitempty"`
+	Port        *int              `json:"port,omitempty"`
+	Expose      *bool             `json:"expose,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	HealthCheck *healthCheckDTO   `json:"healthCheck,omitempty"`
+}
+
+// healthCheckDTO is the API shape for an app health check in requests and responses
+// Durations are whole seconds; zero takes the default
+type healthCheckDTO struct {
+	Type            domain.HealthCheckType `json:"type"`
+	Path            string                 `json:"path,omitempty"`
+	Command         []string               `json:"command,omitempty"`
+	IntervalSeconds int                    `json:"intervalSeconds,omitempty"`
+	TimeoutSeconds  int                    `json:"timeoutSeconds,omitempty"`
+	Retries         int                    `json:"retries,omitempty"`
+}
+
+// toDomain maps a request health check to the domain type; nil stays nil.
+func (h *healthCheckDTO) toDomain() *domain.HealthCheck {
+	if h == nil {
+		return nil
+	}
+	return &domain.HealthCheck{
+		Type:     h.Type,
+		Path:     h.Path,
+		Command:  h.Command,
+		Interval: time.Duration(h.IntervalSeconds) * time.Second,
+		Timeout:  time.Duration(h.TimeoutSeconds) * time.Second,
+		Retries:  h.Retries,
+	}
+}
+
+// toHealthCheckDTO maps a domain health check to the API shape; nil stays nil.
+func toHealthCheckDTO(hc *domain.HealthCheck) *healthCheckDTO {
+	if hc == nil {
+		return nil
+	}
+	return &healthCheckDTO{
+		Type:            hc.Type,
+		Path:            hc.Path,
+		Command:         hc.Command,
+		IntervalSeconds: int(hc.Interval / time.Second),
+		TimeoutSeconds:  int(hc.Timeout / time.Second),
+		Retries:         hc.Retries,
+	}
+}
+
+// rollbackReq is the optional request body for a rollback
+// An empty deploymentId rolls back to the last successful deployment
+type rollbackReq struct {
+	DeploymentID string `json:"deploymentId,omitempty"`
+}
+
+// appResp is the API response shape for an app
+type appResp struct {
+	ID     string            `json:"id"`
 	Name   string            `json:"name"`
 	Image  string            `json:"image"`
 	Port   *int              `json:"port,omitempty"`
-	Expose *bool             `json:"expose,omitempty"`
+	Expose bool              `json:"expose"`
 	Env    map[string]string `json:"env,omitempty"`
+	// HealthCheck is null when the image's own HEALTHCHECK, if any, decides.
+	HealthCheck *healthCheckDTO  `json:"healthCheck"`
+	Status      domain.AppStatus `json:"status"`
+	Version     int64            `json:"version"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	UpdatedAt   time.Time        `json:"updatedAt"`
+	// URL and LatestDeployment come from the app's newest deployment and are null without one.
+	URL              *string         `json:"url"`
+	LatestDeployment *deploymentResp `json:"latestDeployment"`
 }
 
-// appResp is the API response shape for an app
-type appResp struct {
-	ID        string            `json:"id"`
-	Name      string            `json:"name"`
-	Image     string            `json:"image"`
-	Port      *int              `json:"port,omitempty"`
-	Expose    bool              `json:"expose"`
-	Env       map[string]string `json:"env,omitempty"`
-	Status    domain.AppStatus  `json:"status"`
-	CreatedAt time.Time         `json:"createdAt"`
-	UpdatedAt time.Time         `json:"updatedAt"`
-}
-
-// toAppResp maps a domain app to the API response shape.
-func toAppResp(a domain.App) appResp {
-	return appResp{
-		ID:        a.ID,
-		Name:      a.Name,
-		Image:     a.Image,
-		Port:      a.Port,
-		Expose:    a.Expose,
-		Env:       a.Env,
-		Status:    a.Status,
-		CreatedAt: a.CreatedAt,
-		UpdatedAt: a.UpdatedAt,
+// toAppResp maps a domain app and its latest deployment, if any, to the API response shape.
+func toAppResp(a domain.App, latest *domain.Deployment) appResp {
+	resp := appResp{
+		ID:          a.ID,
+		Name:        a.Name,
+		Image:       a.Image,
+		Port:        a.Port,
+		Expose:      a.Expose,
+		Env:         a.Env,
+		HealthCheck: toHealthCheckDTO(a.HealthCheck),
+		Status:      a.Status,
+		Version:     a.Version,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+	if latest != nil {
+		dep := toDeploymentResp(*latest)
+		resp.URL = latest.URL
+		resp.LatestDeployment = &dep
+	}
+	return resp
+}
+
+// updateAppResp is the API response shape for an app update
+// RedeployRequired is true when the running deployment no longer matches the app
+type updateAppResp struct {
+	appResp
+	RedeployRequired bool `json:"redeployRequired"`
+}
+
+// deploymentSpecResp is the API response shape for what a deployment runs
+// Env values are left out; the hash shows whether they changed
+type deploymentSpecResp struct {
+	Image       string          `json:"image"`
+	Port        *int            `json:"port,omitempty"`
+	Expose      bool            `json:"expose"`
+	EnvHash     string          `json:"envHash"`
+	HealthCheck *healthCheckDTO `json:"healthCheck,omitempty"`
+}
+
+// toDeploymentSpecResp maps a deployment spec to the API response shape.
+func toDeploymentSpecResp(s domain.DeploymentSpec) deploymentSpecResp {
+	return deploymentSpecResp{
+		Image:       s.Image,
+		Port:        s.Port,
+		Expose:      s.Expose,
+		EnvHash:     s.EnvHash,
+		HealthCheck: toHealthCheckDTO(s.HealthCheck),
 	}
 }
 
 // deploymentResp is the API response shape for a deployment
 type deploymentResp struct {
-	ID        string                  `json:"id"`
-	AppID     string                  `json:"appId"`
-	Status    domain.DeploymentStatus `json:"status"`
-	URL       *string                 `json:"url,omitempty"`
-	Error     *string                 `json:"error,omitempty"`
-	CreatedAt time.Time               `json:"createdAt"`
-	UpdatedAt time.Time               `json:"updatedAt"`
+	ID    string             `json:"id"`
+	AppID string             `json:"appId"`
+	Spec  deploymentSpecResp `json:"spec"`
+	// RollbackOf is the deployment whose spec this one redeploys, for rollbacks only.
+	RollbackOf *string `json:"rollbackOf,omitempty"`
+	// RetryOf is the deployment this one retries, for retries only.
+	RetryOf *string `json:"retryOf,omitempty"`
+	// RuntimePort is the port the runtime routed to, resolved from the spec or the image.
+	RuntimePort *int                    `json:"runtimePort,omitempty"`
+	Status      domain.DeploymentStatus `json:"status"`
+	URL         *string                 `json:"url,omitempty"`
+	Error       *string                 `json:"error,omitempty"`
+	Version     int64                   `json:"version"`
+	CreatedAt   time.Time               `json:"createdAt"`
+	UpdatedAt   time.Time               `json:"updatedAt"`
+	// StartedAt and CompletedAt are null until the deployment starts or finishes.
+	StartedAt   *time.Time `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+	// Steps are the runtime's deploy steps in run order; always an array.
+	Steps []deploymentStepResp `json:"steps"`
+}
+
+// deploymentStepResp is the API response shape for one deploy step
+// StartedAt and CompletedAt are null until the step starts or ends; Error is set only when it failed
+type deploymentStepResp struct {
+	ID          domain.StepID     `json:"id"`
+	Name        string            `json:"name"`
+	Status      domain.StepStatus `json:"status"`
+	StartedAt   *time.Time        `json:"startedAt"`
+	CompletedAt *time.Time        `json:"completedAt"`
+	Error       *string           `json:"error,omitempty"`
+}
+
+// toDeploymentStepsResp maps deploy steps to the API response shape.
+func toDeploymentStepsResp(steps []domain.DeploymentStep) []deploymentStepResp {
+	out := make([]deploymentStepResp, 0, len(steps))
+	for _, st := range steps {
+		out = append(out, deploymentStepResp(st))
+	}
+	return out
 }
 
 // toDeploymentResp maps a domain deployment to the API response shape.
 func toDeploymentResp(d domain.Deployment) deploymentResp {
 	return deploymentResp{
-		ID:        d.ID,
-		AppID:     d.AppID,
-		Status:    d.Status,
-		URL:       d.URL,
-		Error:     d.Error,
-		CreatedAt: d.CreatedAt,
-		UpdatedAt: d.UpdatedAt,
+		ID:          d.ID,
+		AppID:       d.AppID,
+		Spec:        toDeploymentSpecResp(d.Spec),
+		RollbackOf:  d.RollbackOf,
+		RetryOf:     d.RetryOf,
+		RuntimePort: d.RuntimePort,
+		Status:      d.Status,
+		URL:         d.URL,
+		Error:       d.Error,
+		Version:     d.Version,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+
+		StartedAt:   d.StartedAt,
+		CompletedAt: d.CompletedAt,
+		Steps:       toDeploymentStepsResp(d.Steps),
+	}
+}
+
+// deploymentDetailResp is the API response shape for one deployment with its app and timings
+// QueuePosition is set only while the deployment is QUEUED; 1 means it is claimed next
+type deploymentDetailResp struct {
+	deploymentResp
+	App           appSummaryResp    `json:"app"`
+	Timings       deploymentTimings `json:"timings"`
+	QueuePosition *int              `json:"queuePosition,omitempty"`
+}
+
+// appSummaryResp is the short form of an app embedded in other responses
+type appSummaryResp struct {
+	ID     string           `json:"id"`
+	Name   string           `json:"name"`
+	Status domain.AppStatus `json:"status"`
+}
+
+// deploymentTimings says when a deployment was queued, started and completed
+// DurationMs is the time from start to completion and is null until the deployment finishes
+type deploymentTimings struct {
+	QueuedAt    time.Time  `json:"queuedAt"`
+	StartedAt   *time.Time `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+	DurationMs  *int64     `json:"durationMs"`
+}
+
+// toDeploymentDetailResp maps a service deployment detail to the API response shape.
+func toDeploymentDetailResp(d service.DeploymentDetail) deploymentDetailResp {
+	dep := d.Deployment
+	resp := deploymentDetailResp{
+		deploymentResp: toDeploymentResp(dep),
+		App:            appSummaryResp{ID: d.App.ID, Name: d.App.Name, Status: d.App.Status},
+		Timings: deploymentTimings{
+			QueuedAt:    dep.CreatedAt,
+			StartedAt:   dep.StartedAt,
+			CompletedAt: dep.CompletedAt,
+		},
 	}
+	if dep.StartedAt != nil && dep.CompletedAt != nil {
+		ms := dep.CompletedAt.Sub(*dep.StartedAt).Milliseconds()
+		resp.Timings.DurationMs = &ms
+	}
+	if dep.Status == domain.DeploymentStatusQueued && d.QueuePosition > 0 {
+		pos := d.QueuePosition
+		resp.QueuePosition = &pos
+	}
+	return resp
+}
+
+// appListResp is the API response shape for a page of apps
+type appListResp struct {
+	Apps     []appResp `json:"apps"`
+	Total    int       `json:"total"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"pageSize"`
+}
+
+// toAppListResp maps a service app page to the API response shape.
+func toAppListResp(l service.AppList) appListResp {
+	out := make([]appResp, 0, len(l.Apps))
+	for _, a := range l.Apps {
+		var latest *domain.Deployment
+		if dep, ok := l.Latest[a.ID]; ok {
+			latest = &dep
+		}
+		out = append(out, toAppResp(a, latest))
+	}
+	return appListResp{Apps: out, Total: l.Total, Page: l.Page, PageSize: l.PageSize}
+}
+
+// deploymentListResp is the API response shape for a page of deployments
+type deploymentListResp struct {
+	Deployments []deploymentResp `json:"deployments"`
+	Total       int              `json:"total"`
+	Page        int              `json:"page"`
+	PageSize    int              `json:"pageSize"`
+}
+
+// toDeploymentListResp maps a service deployment page to the API response shape.
+func toDeploymentListResp(l service.DeploymentList) deploymentListResp {
+	out := make([]deploymentResp, 0, len(l.Deployments))
+	for _, d := range l.Deployments {
+		out = append(out, toDeploymentResp(d))
+	}
+	return deploymentListResp{Deployments: out, Total: l.Total, Page: l.Page, PageSize: l.PageSize}
+}
+
+// runtimeStatusResp is the API response shape for an app's live workload status
+type runtimeStatusResp struct {
+	State        domain.RuntimeState `json:"state"`
+	ExitCode     int                 `json:"exitCode"`
+	StartedAt    *time.Time          `json:"startedAt"`
+	RestartCount int                 `json:"restartCount"`
+}
+
+// toRuntimeStatusResp maps a runtime status to the API response shape.
+func toRuntimeStatusResp(s domain.RuntimeStatus) runtimeStatusResp {
+	return runtimeStatusResp{
+		State:        s.State,
+		ExitCode:     s.ExitCode,
+		StartedAt:    s.StartedAt,
+		RestartCount: s.RestartCount,
+	}
+}
+
+// logEntryResp is the API response shape for one log line
+type logEntryResp struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Level     domain.LogLevel `json:"level"`
+	Message   string          `json:"message"`
+	Source    string          `json:"source,omitempty"`
+}
+
+// toLogEntryResp maps a log line to the API response shape.
+func toLogEntryResp(e domain.LogEntry) logEntryResp {
+	return logEntryResp{Timestamp: e.Timestamp, Level: e.Level, Message: e.Message, Source: e.Source}
+}
+
+// toDomain maps a log line a worker reported to the domain type.
+func (e logEntryResp) toDomain() domain.LogEntry {
+	return domain.LogEntry{Timestamp: e.Timestamp.UTC(), Level: e.Level, Message: e.Message, Source: e.Source}
+}
+
+// logsResp is the API response shape for a page of log lines
+// Cursor is passed back to read the lines after this page and is null until a line has been read
+type logsResp struct {
+	Logs    []logEntryResp `json:"logs"`
+	Cursor  *string        `json:"cursor"`
+	HasMore bool           `json:"hasMore"`
+}
+
+// toLogsResp maps a log page to the API response shape.
+func toLogsResp(p service.LogPage) logsResp {
+	out := logsResp{Logs: make([]logEntryResp, 0, len(p.Entries)), HasMore: p.HasMore}
+	for _, e := range p.Entries {
+		out.Logs = append(out.Logs, toLogEntryResp(e))
+	}
+	if p.Cursor != "" {
+		cursor := p.Cursor
+		out.Cursor = &cursor
+	}
+	return out
+}
+
+// appStatusResp is the API response shape for an app in an event
+type appStatusResp struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	Status    domain.AppStatus `json:"status"`
+	Version   int64            `json:"version"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+}
+
+// eventResp is the API response shape for a change event
+// Deployment is set for deployment events and App for app events
+type eventResp struct {
+	Type       service.EventType `json:"type"`
+	AppID      string            `json:"appId"`
+	At         time.Time         `json:"at"`
+	Deployment *deploymentResp   `json:"deployment,omitempty"`
+	App        *appStatusResp    `json:"app,omitempty"`
+}
+
+// toEventResp maps a service event to the API response shape.
+func toEventResp(e service.Event) eventResp {
+	out := eventResp{Type: e.Type, AppID: e.AppID, At: e.At}
+	if e.Deployment != nil {
+		dep := toDeploymentResp(*e.Deployment)
+		out.Deployment = &dep
+	}
+	if e.App != nil {
+		out.App = &appStatusResp{
+			ID:        e.App.ID,
+			Name:      e.App.Name,
+			Status:    e.App.Status,
+			Version:   e.App.Version,
+			UpdatedAt: e.App.UpdatedAt,
+		}
+	}
+	return out
+}
+
+// workerClaimReq is the request body for claiming the next deployment
+type workerClaimReq struct {
+	WorkerID string `json:"workerId"`
+}
+
+// workerClaimResp is the API response shape for a claimed deployment
+// App has the deployment's spec applied and carries env values, since the worker has to run it
+// LeaseSeconds is how long the claim lasts without a heartbeat
+type workerClaimResp struct {
+	Deployment   deploymentResp `json:"deployment"`
+	App          appResp        `json:"app"`
+	LeaseSeconds int            `json:"leaseSeconds"`
+}
+
+// toWorkerClaimResp maps a service claim to the API response shape.
+func toWorkerClaimResp(c service.DeploymentClaim) workerClaimResp {
+	return workerClaimResp{
+		Deployment:   toDeploymentResp(c.Deployment),
+		App:          toAppResp(c.App, nil),
+		LeaseSeconds: max(int(c.Lease/time.Second), 1),
+	}
+}
+
+// stepEventDTO is the API shape for one step report from a worker
+type stepEventDTO struct {
+	Step     domain.StepID `json:"step"`
+	Finished bool          `json:"finished"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// workerHeartbeatReq is the request body for a worker heartbeat
+// Steps, logs and port are what the worker's runtime reported since its last call
+type workerHeartbeatReq struct {
+	WorkerID string         `json:"workerId"`
+	Steps    []stepEventDTO `json:"steps,omitempty"`
+	Logs     []logEntryResp `json:"logs,omitempty"`
+	Port     *int           `json:"port,omitempty"`
+}
+
+// progress maps the reported steps, logs and port to the service type.
+func (r workerHeartbeatReq) progress() service.WorkerProgress {
+	p := service.WorkerProgress{Port: r.Port}
+	for _, e := range r.Steps {
+		p.Steps = append(p.Steps, service.StepEvent(e))
+	}
+	for _, l := range r.Logs {
+		p.Logs = append(p.Logs, l.toDomain())
+	}
+	return p
+}
+
+// workerReportReq is the request body for reporting how a worker's deploy ended
+// An empty error means it succeeded; interrupted puts the deployment back in the queue
+type workerReportReq struct {
+	workerHeartbeatReq
+	URL         *string `json:"url,omitempty"`
+	Error       string  `json:"error,omitempty"`
+	Interrupted bool    `json:"interrupted,omitempty"`
 }
@@ -17,8 +17,14 @@ func mapServiceErr(err error) (status int, msg string) {
 		return http.StatusConflict, "conflict"
 	case errors.Is(err, service.ErrNotFound):
 		return http.StatusNotFound, "not found"
+	case errors.Is(err, service.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, "version mismatch"
 	case errors.Is(err, service.ErrNoRuntime):
 		return http.StatusServiceUnavailable, "runtime not configured"
+	case errors.Is(err, service.ErrLeaseLost):
+		return http.StatusConflict, "deployment lease lost"
+	case errors.Is(err, service.ErrTeardown):
+		return http.StatusBadGateway, "runtime teardown failed"
 	case errors.Is(err, service.ErrNoWork):
 		return http.StatusNoContent, ""
 	default:
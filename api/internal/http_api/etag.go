@@ -0,0 +1,33 @@
+// ETag helpers for optimistic concurrency on versioned records.
+package http_api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// setETag advertises a record version as a strong entity tag.
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// ifMatchVersion reads the If-Match header as a record version.
+// A missing header or * returns 0, meaning any version. ok is false when the
+// header holds something we never issued, which can never match.
+func ifMatchVersion(r *http.Request) (version int64, ok bool) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" || raw == "*" {
+		return 0, true
+	}
+	raw = strings.TrimPrefix(raw, "W/")
+	unquoted, err := strconv.Unquote(raw)
+	if err != nil {
+		return 0, false
+	}
+	version, err = strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
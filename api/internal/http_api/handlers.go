@@ -7,9 +7,15 @@
 package http_api
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/t0gun/spacescale/internal/domain"
 	"github.com/t0gun/spacescale/internal/service"
 )
 
@@ -22,11 +28,12 @@ func (s *Server) handleCreateApp(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app, err := s.svc.CreateApp(r.Context(), service.CreateAppParams{
-		Name:   req.Name,
-		Image:  req.Image,
-		Port:   req.Port,
-		Expose: req.Expose,
-		Env:    req.Env,
+		Name:        req.Name,
+		Image:       req.Image,
+		Port:        req.Port,
+		Expose:      req.Expose,
+		Env:         req.Env,
+		HealthCheck: req.HealthCheck.toDomain(),
 	})
 	if err != nil {
 		status, msg := mapServiceErr(err)
@@ -37,13 +44,166 @@ func (s *Server) handleCreateApp(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, status, msg)
 		return
 	}
-	writeJSON(w, http.StatusCreated, toAppResp(app))
+	setETag(w, app.Version)
+	writeJSON(w, http.StatusCreated, toAppResp(app, nil))
+}
+
+// handleUpdateApp applies a partial update to an app.
+// If-Match pins the update to the app version the client last saw.
+func (s *Server) handleUpdateApp(w http.ResponseWriter, r *http.Request) {
+	appID := chi.URLParam(r, "appID")
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		writeErr(w, http.StatusPreconditionFailed, "version mismatch")
+		return
+	}
+	var req updateAppReq
+	if err := readJSON(r, &req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	upd, err := s.svc.UpdateApp(r.Context(), service.UpdateAppParams{
+		AppID:       appID,
+		IfVersion:   version,
+		Name:        req.Name,
+		Image:       req.Image,
+		Port:        req.Port,
+		Expose:      req.Expose,
+		Env:         req.Env,
+		HealthCheck: req.HealthCheck.toDomain(),
+	})
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	latest, err := s.svc.LatestDeployment(r.Context(), appID)
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	setETag(w, upd.App.Version)
+	writeJSON(w, http.StatusOK, updateAppResp{
+		appResp:          toAppResp(upd.App, latest),
+		RedeployRequired: upd.RedeployRequired,
+	})
+}
+
+// handleDeleteApp deletes an app and tears it down; deleting a missing app also returns 204.
+// If-Match pins the delete to the app version the client last saw.
+// A worker in another process that may still be deploying the app makes it 409 until that worker stops.
+func (s *Server) handleDeleteApp(w http.ResponseWriter, r *http.Request) {
+	appID := chi.URLParam(r, "appID")
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		writeErr(w, http.StatusPreconditionFailed, "version mismatch")
+		return
+	}
+	if err := s.svc.DeleteApp(r.Context(), service.DeleteAppParams{AppID: appID, IfVersion: version}); err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStopApp stops an app's workload.
+func (s *Server) handleStopApp(w http.ResponseWriter, r *http.Request) {
+	s.writeRuntimeStatus(w, r, s.svc.StopApp)
+}
+
+// handleStartApp starts a stopped app's workload.
+func (s *Server) handleStartApp(w http.ResponseWriter, r *http.Request) {
+	s.writeRuntimeStatus(w, r, s.svc.StartApp)
+}
+
+// handleRestartApp restarts an app's workload.
+func (s *Server) handleRestartApp(w http.ResponseWriter, r *http.Request) {
+	s.writeRuntimeStatus(w, r, s.svc.RestartApp)
+}
+
+// handleAppRuntimeStatus reports what an app's workload is doing right now.
+func (s *Server) handleAppRuntimeStatus(w http.ResponseWriter, r *http.Request) {
+	s.writeRuntimeStatus(w, r, s.svc.AppRuntimeStatus)
+}
+
+// writeRuntimeStatus runs a runtime operation on the app in the path and writes the status it returns.
+func (s *Server) writeRuntimeStatus(w http.ResponseWriter, r *http.Request, fn func(context.Context, string) (domain.RuntimeStatus, error)) {
+	st, err := fn(r.Context(), chi.URLParam(r, "appID"))
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	writeJSON(w, http.StatusOK, toRuntimeStatusResp(st))
+}
+
+// handlePauseApp stops a running app and serves a maintenance response in its place.
+// If-Match pins the pause to the app version the client last saw.
+func (s *Server) handlePauseApp(w http.ResponseWriter, r *http.Request) {
+	appID := chi.URLParam(r, "appID")
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		writeErr(w, http.StatusPreconditionFailed, "version mismatch")
+		return
+	}
+	app, err := s.svc.PauseApp(r.Context(), service.PauseAppParams{AppID: appID, IfVersion: version})
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	s.writeApp(w, r, app)
+}
+
+// handleResumeApp starts a paused app again.
+// If-Match pins the resume to the app version the client last saw.
+func (s *Server) handleResumeApp(w http.ResponseWriter, r *http.Request) {
+	appID := chi.URLParam(r, "appID")
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		writeErr(w, http.StatusPreconditionFailed, "version mismatch")
+		return
+	}
+	app, err := s.svc.ResumeApp(r.Context(), service.ResumeAppParams{AppID: appID, IfVersion: version})
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	s.writeApp(w, r, app)
+}
+
+// writeApp writes an app with its latest deployment and ETag.
+func (s *Server) writeApp(w http.ResponseWriter, r *http.Request, app domain.App) {
+	latest, err := s.svc.LatestDeployment(r.Context(), app.ID)
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	setETag(w, app.Version)
+	writeJSON(w, http.StatusOK, toAppResp(app, latest))
 }
 
 // handleDeployApp handles app deployment requests.
+// If-Match pins the deploy to the app version the client last saw.
+// force=true deploys a paused app, which ends the pause.
 func (s *Server) handleDeployApp(w http.ResponseWriter, r *http.Request) {
 	appID := chi.URLParam(r, "appID")
-	dep, err := s.svc.DeployApp(r.Context(), service.DeployAppParams{AppID: appID})
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		writeErr(w, http.StatusPreconditionFailed, "version mismatch")
+		return
+	}
+	force, err := queryBool(r, "force")
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid force")
+		return
+	}
+	dep, err := s.svc.DeployApp(r.Context(), service.DeployAppParams{AppID: appID, IfVersion: version, Force: force})
 	if err != nil {
 		status, msg := mapServiceErr(err)
 		if status == http.StatusNoContent {
@@ -53,13 +213,113 @@ func (s *Server) handleDeployApp(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, status, msg)
 		return
 	}
+	setETag(w, dep.Version)
+	writeJSON(w, http.StatusAccepted, toDeploymentResp(dep))
+}
+
+// handleRollbackApp queues a deployment of an earlier deployment's spec.
+// The body is optional; without a deploymentId the last successful deployment is used.
+// If-Match and force work as they do for deploy.
+func (s *Server) handleRollbackApp(w http.ResponseWriter, r *http.Request) {
+	appID := chi.URLParam(r, "appID")
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		writeErr(w, http.StatusPreconditionFailed, "version mismatch")
+		return
+	}
+	force, err := queryBool(r, "force")
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid force")
+		return
+	}
+	var req rollbackReq
+	if err := readJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeErr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	dep, err := s.svc.RollbackApp(r.Context(), service.RollbackAppParams{
+		AppID:        appID,
+		DeploymentID: req.DeploymentID,
+		IfVersion:    version,
+		Force:        force,
+	})
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	setETag(w, dep.Version)
+	writeJSON(w, http.StatusAccepted, toDeploymentResp(dep))
+}
+
+// handleGetDeployment returns one deployment with its app, timings and queue position.
+func (s *Server) handleGetDeployment(w http.ResponseWriter, r *http.Request) {
+	detail, err := s.svc.GetDeploymentByID(r.Context(), chi.URLParam(r, "deploymentID"))
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	setETag(w, detail.Deployment.Version)
+	writeJSON(w, http.StatusOK, toDeploymentDetailResp(detail))
+}
+
+// handleCancelDeployment cancels a queued or in-flight deployment.
+// If-Match pins the cancel to the deployment version the client last saw.
+func (s *Server) handleCancelDeployment(w http.ResponseWriter, r *http.Request) {
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		writeErr(w, http.StatusPreconditionFailed, "version mismatch")
+		return
+	}
+	dep, err := s.svc.CancelDeployment(r.Context(), service.CancelDeploymentParams{
+		DeploymentID: chi.URLParam(r, "deploymentID"),
+		IfVersion:    version,
+	})
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	setETag(w, dep.Version)
+	writeJSON(w, http.StatusOK, toDeploymentResp(dep))
+}
+
+// handleRetryDeployment queues a new deployment with the spec of a failed or canceled one.
+// force=true retries on a paused app, which ends the pause.
+func (s *Server) handleRetryDeployment(w http.ResponseWriter, r *http.Request) {
+	force, err := queryBool(r, "force")
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid force")
+		return
+	}
+	dep, err := s.svc.RetryDeployment(r.Context(), service.RetryDeploymentParams{
+		DeploymentID: chi.URLParam(r, "deploymentID"),
+		Force:        force,
+	})
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	setETag(w, dep.Version)
 	writeJSON(w, http.StatusAccepted, toDeploymentResp(dep))
 }
 
 // handleListDeployments lists deployments for an app.
 func (s *Server) handleListDeployments(w http.ResponseWriter, r *http.Request) {
 	appID := chi.URLParam(r, "appID")
-	deps, err := s.svc.ListDeployments(r.Context(), service.ListDeploymentsParams{AppID: appID})
+	page, pageSize, ok := queryPage(r)
+	if !ok {
+		writeErr(w, http.StatusBadRequest, "invalid page or pageSize")
+		return
+	}
+	list, err := s.svc.ListDeployments(r.Context(), service.ListDeploymentsParams{
+		AppID:    appID,
+		Status:   domain.DeploymentStatus(r.URL.Query().Get("status")),
+		Page:     page,
+		PageSize: pageSize,
+	})
 	if err != nil {
 		status, msg := mapServiceErr(err)
 		if status == http.StatusNoContent {
@@ -69,11 +329,7 @@ func (s *Server) handleListDeployments(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, status, msg)
 		return
 	}
-	out := make([]deploymentResp, 0, len(deps))
-	for _, d := range deps {
-		out = append(out, toDeploymentResp(d))
-	}
-	writeJSON(w, http.StatusOK, out)
+	writeJSON(w, http.StatusOK, toDeploymentListResp(list))
 }
 
 // handleProcessNextDeployment processes the next queued deployment.
@@ -91,9 +347,14 @@ func (s *Server) handleProcessNextDeployment(w http.ResponseWriter, r *http.Requ
 	writeJSON(w, http.StatusOK, toDeploymentResp(dep))
 }
 
-// handleListApps lists all apps.
-func (s *Server) handleListApps(w http.ResponseWriter, r *http.Request) {
-	apps, err := s.svc.ListApps(r.Context())
+// handleClaimDeployment claims the next queued deployment for a worker, or returns 204 when there is none.
+func (s *Server) handleClaimDeployment(w http.ResponseWriter, r *http.Request) {
+	var req workerClaimReq
+	if err := readJSON(r, &req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	claim, err := s.svc.ClaimDeployment(r.Context(), req.WorkerID)
 	if err != nil {
 		status, msg := mapServiceErr(err)
 		if status == http.StatusNoContent {
@@ -103,11 +364,77 @@ func (s *Server) handleListApps(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, status, msg)
 		return
 	}
-	out := make([]appResp, 0, len(apps))
-	for _, a := range apps {
-		out = append(out, toAppResp(a))
+	writeJSON(w, http.StatusOK, toWorkerClaimResp(claim))
+}
+
+// handleHeartbeatDeployment renews a worker's claim and stores its progress.
+// A 409 tells the worker its claim is gone, so it should stop the deploy.
+func (s *Server) handleHeartbeatDeployment(w http.ResponseWriter, r *http.Request) {
+	var req workerHeartbeatReq
+	if err := readJSON(r, &req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	err := s.svc.HeartbeatDeployment(r.Context(), service.HeartbeatParams{
+		DeploymentID: chi.URLParam(r, "deploymentID"),
+		WorkerID:     req.WorkerID,
+		Progress:     req.progress(),
+	})
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReportDeployment stores how a worker's deploy ended and returns the deployment.
+func (s *Server) handleReportDeployment(w http.ResponseWriter, r *http.Request) {
+	var req workerReportReq
+	if err := readJSON(r, &req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	dep, err := s.svc.ReportDeployment(r.Context(), service.ReportParams{
+		DeploymentID: chi.URLParam(r, "deploymentID"),
+		WorkerID:     req.WorkerID,
+		Progress:     req.progress(),
+		URL:          req.URL,
+		Error:        req.Error,
+		Interrupted:  req.Interrupted,
+	})
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	writeJSON(w, http.StatusOK, toDeploymentResp(dep))
+}
+
+// handleListApps lists apps with optional status and name prefix filters.
+func (s *Server) handleListApps(w http.ResponseWriter, r *http.Request) {
+	page, pageSize, ok := queryPage(r)
+	if !ok {
+		writeErr(w, http.StatusBadRequest, "invalid page or pageSize")
+		return
+	}
+	q := r.URL.Query()
+	list, err := s.svc.ListApps(r.Context(), service.ListAppsParams{
+		Status:     domain.AppStatus(q.Get("status")),
+		NamePrefix: q.Get("namePrefix"),
+		Page:       page,
+		PageSize:   pageSize,
+	})
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		if status == http.StatusNoContent {
+			w.WriteHeader(status)
+			return
+		}
+		writeErr(w, status, msg)
+		return
 	}
-	writeJSON(w, http.StatusOK, out)
+	writeJSON(w, http.StatusOK, toAppListResp(list))
 }
 
 // handleGetAppByID returns one app by id.
@@ -123,5 +450,120 @@ func (s *Server) handleGetAppByID(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, status, msg)
 		return
 	}
-	writeJSON(w, http.StatusOK, toAppResp(app))
+	latest, err := s.svc.LatestDeployment(r.Context(), appID)
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	setETag(w, app.Version)
+	writeJSON(w, http.StatusOK, toAppResp(app, latest))
+}
+
+// handleAppLogs returns a page of an app's log lines, or streams them as server-sent events with follow=true.
+func (s *Server) handleAppLogs(w http.ResponseWriter, r *http.Request) {
+	s.serveLogs(w, r, chi.URLParam(r, "appID"), s.svc.AppLogs, s.svc.FollowAppLogs)
+}
+
+// handleDeploymentLogs returns the log lines an app wrote since a deployment started, or streams them with follow=true.
+func (s *Server) handleDeploymentLogs(w http.ResponseWriter, r *http.Request) {
+	s.serveLogs(w, r, chi.URLParam(r, "deploymentID"), s.svc.DeploymentLogs, s.svc.FollowDeploymentLogs)
+}
+
+// serveLogs reads the log query and writes a page, or streams lines when following.
+// A followed stream sends each line as a "log" event whose id is the cursor after it,
+// so a reconnecting client resumes from Last-Event-ID. The stream also ends when the server shuts down.
+func (s *Server) serveLogs(
+	w http.ResponseWriter,
+	r *http.Request,
+	id string,
+	page func(context.Context, string, service.LogsParams) (service.LogPage, error),
+	follow func(context.Context, string, service.LogsParams, func(domain.LogEntry, string) error) error,
+) {
+	params, following, ok := queryLogs(r)
+	if !ok {
+		writeErr(w, http.StatusBadRequest, "invalid since, tail, limit or follow")
+		return
+	}
+	if !following {
+		logs, err := page(r.Context(), id, params)
+		if err != nil {
+			status, msg := mapServiceErr(err)
+			writeErr(w, status, msg)
+			return
+		}
+		writeJSON(w, http.StatusOK, toLogsResp(logs))
+		return
+	}
+
+	if params.Cursor == "" {
+		params.Cursor = r.Header.Get("Last-Event-ID")
+	}
+	ctx, cancel := s.streamContext(r)
+	defer cancel()
+	stream := newSSEWriter(w)
+	err := follow(ctx, id, params, func(e domain.LogEntry, cursor string) error {
+		return stream.Send(cursor, "log", toLogEntryResp(e))
+	})
+	if err != nil && ctx.Err() != nil {
+		// The client left or the server is shutting down; a reconnect resumes from the last id
+		_ = stream.Start()
+		return
+	}
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		if !stream.Started() {
+			writeErr(w, status, msg)
+			return
+		}
+		_ = stream.Send("", "error", errResp{Error: msg})
+		return
+	}
+	// The workload went away; an empty stream still tells the client there was nothing to follow
+	_ = stream.Start()
+}
+
+// eventKeepAlive is how often an idle event stream gets a comment so proxies keep it open.
+const eventKeepAlive = 15 * time.Second
+
+// handleEvents streams deployment and app changes as server-sent events, optionally for one app.
+// Each event's id resumes the stream after it through Last-Event-ID. The stream ends when the
+// client falls too far behind or the server shuts down, and the client reconnects from the last event it handled.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := s.streamContext(r)
+	defer cancel()
+	sub, err := s.svc.SubscribeEvents(ctx, service.EventsParams{
+		AppID:       r.URL.Query().Get("appId"),
+		LastEventID: r.Header.Get("Last-Event-ID"),
+	})
+	if err != nil {
+		status, msg := mapServiceErr(err)
+		writeErr(w, status, msg)
+		return
+	}
+	defer sub.Close()
+
+	stream := newSSEWriter(w)
+	if err := stream.Start(); err != nil {
+		return
+	}
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if err := stream.Send(strconv.FormatUint(e.ID, 10), string(e.Type), toEventResp(e)); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := stream.Comment("keep-alive"); err != nil {
+				return
+			}
+		}
+	}
 }
@@ -0,0 +1,66 @@
+// Query string helpers for list and action endpoints.
+package http_api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// queryInt reads an optional integer query parameter; missing means zero.
+func queryInt(r *http.Request, name string) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(raw)
+}
+
+// queryBool reads an optional boolean query parameter; missing means false.
+func queryBool(r *http.Request, name string) (bool, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(raw)
+}
+
+// queryPage reads the page and pageSize query parameters.
+func queryPage(r *http.Request) (page, pageSize int, ok bool) {
+	page, err := queryInt(r, "page")
+	if err != nil {
+		return 0, 0, false
+	}
+	pageSize, err = queryInt(r, "pageSize")
+	if err != nil {
+		return 0, 0, false
+	}
+	return page, pageSize, true
+}
+
+// queryLogs reads the cursor, since, tail, limit and follow query parameters of a log request.
+// since is an RFC 3339 time.
+func queryLogs(r *http.Request) (p service.LogsParams, follow bool, ok bool) {
+	q := r.URL.Query()
+	p.Cursor = q.Get("cursor")
+	if raw := q.Get("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return service.LogsParams{}, false, false
+		}
+		p.Since = &since
+	}
+	var err error
+	if p.Tail, err = queryInt(r, "tail"); err != nil {
+		return service.LogsParams{}, false, false
+	}
+	if p.Limit, err = queryInt(r, "limit"); err != nil {
+		return service.LogsParams{}, false, false
+	}
+	if follow, err = queryBool(r, "follow"); err != nil {
+		return service.LogsParams{}, false, false
+	}
+	return p, follow, true
+}
@@ -2,6 +2,7 @@
 package http_api
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -13,11 +14,32 @@ import (
 type Server struct {
 	svc         *service.AppService
 	workerToken string
+
+	// streams ends with CloseStreams and takes every event and log stream with it
+	streams     context.Context
+	stopStreams context.CancelFunc
 }
 
 // NewServer builds an API server with the service and worker auth token.
 func NewServer(svc *service.AppService, workerToken string) *Server {
-	return &Server{svc: svc, workerToken: workerToken}
+	streams, stopStreams := context.WithCancel(context.Background())
+	return &Server{svc: svc, workerToken: workerToken, streams: streams, stopStreams: stopStreams}
+}
+
+// CloseStreams ends every open event and log stream, and any opened after it, so clients reconnect elsewhere.
+// http.Server.Shutdown waits for streams without ending them, so register it with RegisterOnShutdown.
+func (s *Server) CloseStreams() {
+	s.stopStreams()
+}
+
+// streamContext returns the context a stream runs under; it ends with the request or with CloseStreams.
+func (s *Server) streamContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(r.Context())
+	stop := context.AfterFunc(s.streams, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
 }
 
 // Router builds the HTTP routes and middleware stack.
@@ -41,8 +63,30 @@ func (s *Server) Router() http.Handler {
 		r.Get("/apps/{appID}/deployments", s.handleListDeployments)
 		r.Get("/apps", s.handleListApps)
 		r.Get("/apps/{appID}", s.handleGetAppByID)
+		r.Patch("/apps/{appID}", s.handleUpdateApp)
+		r.Delete("/apps/{appID}", s.handleDeleteApp)
+		r.Post("/apps/{appID}/stop", s.handleStopApp)
+		r.Post("/apps/{appID}/start", s.handleStartApp)
+		r.Post("/apps/{appID}/restart", s.handleRestartApp)
+		r.Get("/apps/{appID}/runtime", s.handleAppRuntimeStatus)
+		r.Get("/apps/{appID}/logs", s.handleAppLogs)
+		r.Post("/apps/{appID}/pause", s.handlePauseApp)
+		r.Post("/apps/{appID}/resume", s.handleResumeApp)
+		r.Post("/apps/{appID}/rollback", s.handleRollbackApp)
+		r.Get("/deployments/{deploymentID}", s.handleGetDeployment)
+		r.Get("/deployments/{deploymentID}/logs", s.handleDeploymentLogs)
+		r.Post("/deployments/{deploymentID}/cancel", s.handleCancelDeployment)
+		r.Post("/deployments/{deploymentID}/retry", s.handleRetryDeployment)
+		r.Get("/events", s.handleEvents)
 
-		r.With(WorkerAuth{Token: s.workerToken}.Middleware).Post("/deployments/next:process", s.handleProcessNextDeployment)
+		// Worker endpoints: next:process runs a deploy here; claim, heartbeat and report let a worker elsewhere run it
+		r.Group(func(r chi.Router) {
+			r.Use(WorkerAuth{Token: s.workerToken}.Middleware)
+			r.Post("/deployments/next:process", s.handleProcessNextDeployment)
+			r.Post("/deployments/next:claim", s.handleClaimDeployment)
+			r.Post("/deployments/{deploymentID}/heartbeat", s.handleHeartbeatDeployment)
+			r.Post("/deployments/{deploymentID}/report", s.handleReportDeployment)
+		})
 	})
 
 	return r
@@ -1,22 +1,29 @@
 // Tests for http api routes and responses
 // Tests exercise app creation and deployment flows
 // Tests verify status codes and response fields
-// Tests cover exposure disabled behavior
+// Tests cover exposure disabled behavior and streams ending on shutdown
 // These tests guard handler regressions
 
 package http_api_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/t0gun/spacescale/internal/adapters/runtime/docker"
 	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
 	"github.com/t0gun/spacescale/internal/http_api"
 	"github.com/t0gun/spacescale/internal/service"
 )
@@ -114,6 +121,7 @@ func TestCreateApp(t *testing.T) {
 		assert.NotEmpty(t, got["id"])
 		assert.Equal(t, "hello", got["name"])
 		assert.Equal(t, "nginx:latest", got["image"])
+		assert.EqualValues(t, 1, got["version"])
 	})
 
 	t.Run("invalid - 400", func(t *testing.T) {
@@ -139,6 +147,32 @@ func TestCreateApp(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
 	})
 
+	t.Run("health check - 201", func(t *testing.T) {
+		ts, _ := newTestServer(t, "")
+		defer ts.Close()
+
+		body := `{"name":"hello","image":"nginx:latest","port":8080,"healthCheck":{"type":"http","path":"/healthz","intervalSeconds":2}}`
+		res := doRequest(t, newJSONRequest(t, http.MethodPost, ts.URL+"/v0/apps", []byte(body)))
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+		var got map[string]any
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+		hc, _ := got["healthCheck"].(map[string]any)
+		assert.Equal(t, "http", hc["type"])
+		assert.Equal(t, "/healthz", hc["path"])
+		assert.EqualValues(t, 2, hc["intervalSeconds"])
+		assert.EqualValues(t, 5, hc["timeoutSeconds"])
+		assert.EqualValues(t, 3, hc["retries"])
+	})
+
+	t.Run("invalid health check - 400", func(t *testing.T) {
+		ts, _ := newTestServer(t, "")
+		defer ts.Close()
+
+		body := `{"name":"hello","image":"nginx:latest","healthCheck":{"type":"command"}}`
+		res := doRequest(t, newJSONRequest(t, http.MethodPost, ts.URL+"/v0/apps", []byte(body)))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
 }
 
 // TestCreateAppConflict verifies conflict on duplicate names.
@@ -189,10 +223,14 @@ func TestDeployAndProcessAndListDeployments(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, listRes.StatusCode)
 
-	var deps []map[string]any
-	assert.NoError(t, json.NewDecoder(listRes.Body).Decode(&deps))
-	assert.Len(t, deps, 1)
-	assert.Equal(t, "RUNNING", deps[0]["status"])
+	var list struct {
+		Deployments []map[string]any `json:"deployments"`
+		Total       int              `json:"total"`
+	}
+	assert.NoError(t, json.NewDecoder(listRes.Body).Decode(&list))
+	assert.Len(t, list.Deployments, 1)
+	assert.Equal(t, 1, list.Total)
+	assert.Equal(t, "RUNNING", list.Deployments[0]["status"])
 }
 
 // TestDeployNoExpose verifies deployments without exposure omit URLs.
@@ -253,6 +291,31 @@ func TestProcessNoWork(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, res.StatusCode)
 }
 
+// TestProcessAttemptsExhausted verifies a deployment failed for too many attempts is returned with 200.
+func TestProcessAttemptsExhausted(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	app, err := domain.NewApp(domain.NewAppParams{Name: "hello", Image: "nginx:latest"})
+	assert.NoError(t, err)
+	assert.NoError(t, st.CreateApp(ctx, app))
+	assert.NoError(t, st.CreateDeployment(ctx, domain.NewDeployment(app.ID)))
+	// A worker claims it and dies, so its lease runs out
+	_, err = st.TakeNextQueuedDeployment(ctx, "worker-1", time.Millisecond)
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	svc := service.NewAppServiceWithRuntime(st, stubRuntime{}, service.WithMaxAttempts(1))
+	ts := httptest.NewServer(http_api.NewServer(svc, "").Router())
+	defer ts.Close()
+
+	res := doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0/deployments/next:process", nil))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var got map[string]any
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+	assert.Equal(t, "FAILED", got["status"])
+	assert.Equal(t, "deployment gave up after 1 attempts", got["error"])
+}
+
 // TestProcessNoRuntime verifies missing runtime returns 503.
 func TestProcessNoRuntime(t *testing.T) {
 	st := store.NewMemoryStore()
@@ -282,9 +345,12 @@ func TestListApps(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, res.StatusCode)
 
-		var got []map[string]any
+		var got appList
 		assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
-		assert.Len(t, got, 0)
+		assert.Len(t, got.Apps, 0)
+		assert.Equal(t, 0, got.Total)
+		assert.Equal(t, 1, got.Page)
+		assert.Equal(t, service.DefaultPageSize, got.PageSize)
 	})
 
 	t.Run("list includes created app", func(t *testing.T) {
@@ -298,11 +364,52 @@ func TestListApps(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, res.StatusCode)
 
-		var got []map[string]any
+		var got appList
 		assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
-		assert.GreaterOrEqual(t, len(got), 1)
-		assert.Equal(t, created["id"], got[0]["id"])
+		assert.GreaterOrEqual(t, len(got.Apps), 1)
+		assert.Equal(t, created["id"], got.Apps[0]["id"])
 	})
+
+	t.Run("filters and pages", func(t *testing.T) {
+		ts, _ := newTestServer(t, "")
+		defer ts.Close()
+
+		createApp(t, ts, "web-a", "nginx:latest", nil, nil, nil)
+		createApp(t, ts, "web-b", "nginx:latest", nil, nil, nil)
+		createApp(t, ts, "api", "nginx:latest", nil, nil, nil)
+
+		req := newRequest(t, http.MethodGet, ts.URL+"/v0/apps?namePrefix=web-&status=CREATED&page=2&pageSize=1", nil)
+		res := doRequest(t, req)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var got appList
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+		assert.Equal(t, 2, got.Total)
+		assert.Equal(t, 2, got.Page)
+		assert.Equal(t, 1, got.PageSize)
+		assert.Len(t, got.Apps, 1)
+		assert.Equal(t, "web-b", got.Apps[0]["name"])
+	})
+
+	t.Run("bad query - 400", func(t *testing.T) {
+		ts, _ := newTestServer(t, "")
+		defer ts.Close()
+
+		for _, q := range []string{"?page=abc", "?pageSize=1000", "?page=-1", "?status=NOPE"} {
+			req := newRequest(t, http.MethodGet, ts.URL+"/v0/apps"+q, nil)
+			res := doRequest(t, req)
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, q)
+		}
+	})
+}
+
+// appList is the decoded app list payload.
+type appList struct {
+	Apps     []map[string]any `json:"apps"`
+	Total    int              `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"pageSize"`
 }
 
 // TestGetAppByID verifies app lookup behavior.
@@ -324,6 +431,34 @@ func TestGetAppByID(t *testing.T) {
 		assert.Equal(t, appID, got["id"])
 		assert.Equal(t, "hello", got["name"])
 		assert.Equal(t, "nginx:latest", got["image"])
+		assert.Equal(t, `"1"`, getRes.Header.Get("ETag"))
+		assert.EqualValues(t, 1, got["version"])
+		assert.Contains(t, got, "url")
+		assert.Nil(t, got["url"])
+		assert.Nil(t, got["latestDeployment"])
+	})
+
+	t.Run("includes latest deployment", func(t *testing.T) {
+		ts, _ := newTestServer(t, "")
+		defer ts.Close()
+
+		created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+		appID, _ := created["id"].(string)
+
+		deployRes := doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0/apps/"+appID+"/deploy", nil))
+		assert.Equal(t, http.StatusAccepted, deployRes.StatusCode)
+		var dep map[string]any
+		assert.NoError(t, json.NewDecoder(deployRes.Body).Decode(&dep))
+
+		getRes := doRequest(t, newRequest(t, http.MethodGet, ts.URL+"/v0/apps/"+appID, nil))
+		assert.Equal(t, http.StatusOK, getRes.StatusCode)
+
+		var got map[string]any
+		assert.NoError(t, json.NewDecoder(getRes.Body).Decode(&got))
+		assert.Equal(t, "BUILDING", got["status"])
+		latest, ok := got["latestDeployment"].(map[string]any)
+		assert.True(t, ok)
+		assert.Equal(t, dep["id"], latest["id"])
 	})
 
 	t.Run("not found - 404", func(t *testing.T) {
@@ -340,6 +475,107 @@ func TestGetAppByID(t *testing.T) {
 	})
 }
 
+// TestDeployIfMatch verifies deploys honour the app version in If-Match.
+func TestDeployIfMatch(t *testing.T) {
+	tests := []struct {
+		label    string
+		ifMatch  string
+		wantCode int
+	}{
+		{label: "no header - 202", ifMatch: "", wantCode: http.StatusAccepted},
+		{label: "wildcard - 202", ifMatch: "*", wantCode: http.StatusAccepted},
+		{label: "current version - 202", ifMatch: `"1"`, wantCode: http.StatusAccepted},
+		{label: "weak current version - 202", ifMatch: `W/"1"`, wantCode: http.StatusAccepted},
+		{label: "stale version - 412", ifMatch: `"2"`, wantCode: http.StatusPreconditionFailed},
+		{label: "garbage - 412", ifMatch: "nope", wantCode: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			ts, _ := newTestServer(t, "")
+			defer ts.Close()
+
+			created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+			appID, _ := created["id"].(string)
+
+			req := newRequest(t, http.MethodPost, ts.URL+"/v0/apps/"+appID+"/deploy", nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			res := doRequest(t, req)
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+			if tt.wantCode == http.StatusAccepted {
+				assert.Equal(t, `"1"`, res.Header.Get("ETag"))
+			}
+		})
+	}
+}
+
+// TestUpdateApp verifies partial app updates over HTTP.
+func TestUpdateApp(t *testing.T) {
+	patch := func(t *testing.T, ts *httptest.Server, appID, body, ifMatch string) *http.Response {
+		t.Helper()
+		req := newJSONRequest(t, http.MethodPatch, ts.URL+"/v0/apps/"+appID, []byte(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		return doRequest(t, req)
+	}
+
+	t.Run("ok - 200", func(t *testing.T) {
+		ts, _ := newTestServer(t, "")
+		defer ts.Close()
+
+		created := createApp(t, ts, "hello", "nginx:latest", ptrInt(8080), nil, nil)
+		appID, _ := created["id"].(string)
+
+		res := patch(t, ts, appID, `{"name":"renamed","env":{"A":"1"}}`, `"1"`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+
+		var got map[string]any
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+		assert.Equal(t, "renamed", got["name"])
+		assert.Equal(t, "nginx:latest", got["image"])
+		assert.Equal(t, map[string]any{"A": "1"}, got["env"])
+		assert.Equal(t, false, got["redeployRequired"])
+	})
+
+	tests := []struct {
+		label    string
+		body     string
+		ifMatch  string
+		wantCode int
+	}{
+		{label: "stale if-match - 412", body: `{"name":"renamed"}`, ifMatch: `"9"`, wantCode: http.StatusPreconditionFailed},
+		{label: "invalid name - 400", body: `{"name":"Bad_Name"}`, wantCode: http.StatusBadRequest},
+		{label: "unknown field - 400", body: `{"startCommand":"run"}`, wantCode: http.StatusBadRequest},
+		{label: "empty patch - 400", body: `{}`, wantCode: http.StatusBadRequest},
+		{label: "name taken - 409", body: `{"name":"taken"}`, wantCode: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			ts, _ := newTestServer(t, "")
+			defer ts.Close()
+
+			created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+			createApp(t, ts, "taken", "nginx:latest", nil, nil, nil)
+			appID, _ := created["id"].(string)
+
+			res := patch(t, ts, appID, tt.body, tt.ifMatch)
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+		})
+	}
+
+	t.Run("missing app - 404", func(t *testing.T) {
+		ts, _ := newTestServer(t, "")
+		defer ts.Close()
+
+		res := patch(t, ts, "missing", `{"name":"renamed"}`, "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
+
 // TestWorkerAuth_ProcessNextDeployment verifies worker token enforcement.
 func TestWorkerAuth_ProcessNextDeployment(t *testing.T) {
 	tests := []struct {
@@ -374,3 +610,671 @@ func TestWorkerAuth_ProcessNextDeployment(t *testing.T) {
 func ptrInt(v int) *int {
 	return &v
 }
+
+// stubRuntime stands in for Docker where a test does not deploy anything.
+type stubRuntime struct {
+	removeErr error
+	// status is what the workload reports; nil means nothing is deployed
+	status *domain.RuntimeStatus
+	// logs is the workload's output
+	logs []domain.LogEntry
+	// build is logged to the deployment's build log
+	build []domain.LogEntry
+}
+
+// Deploy logs the configured build lines, resolves port 80 and succeeds without a URL.
+func (r stubRuntime) Deploy(ctx context.Context, app domain.App, progress contracts.DeployProgress) (*string, error) {
+	for _, line := range r.build {
+		progress.Log(line)
+	}
+	progress.PortResolved(80)
+	return nil, nil
+}
+
+// Stop succeeds when something is deployed.
+func (r stubRuntime) Stop(ctx context.Context, app domain.App) error { return r.deployed() }
+
+// Start succeeds when something is deployed.
+func (r stubRuntime) Start(ctx context.Context, app domain.App) error { return r.deployed() }
+
+// Restart succeeds when something is deployed.
+func (r stubRuntime) Restart(ctx context.Context, app domain.App) error { return r.deployed() }
+
+// Pause succeeds when something is deployed.
+func (r stubRuntime) Pause(ctx context.Context, app domain.App) error { return r.deployed() }
+
+// Resume succeeds when something is deployed.
+func (r stubRuntime) Resume(ctx context.Context, app domain.App) error { return r.deployed() }
+
+// Remove returns the configured error.
+func (r stubRuntime) Remove(ctx context.Context, app domain.App) error { return r.removeErr }
+
+// Status returns the configured status.
+func (r stubRuntime) Status(ctx context.Context, app domain.App) (domain.RuntimeStatus, error) {
+	if err := r.deployed(); err != nil {
+		return domain.RuntimeStatus{}, err
+	}
+	return *r.status, nil
+}
+
+// Logs emits the configured lines written at or after q.Since; following ends once they run out.
+func (r stubRuntime) Logs(ctx context.Context, app domain.App, q contracts.LogQuery, emit func(domain.LogEntry) error) error {
+	if err := r.deployed(); err != nil {
+		return err
+	}
+	for _, e := range r.logs {
+		if q.Since != nil && e.Timestamp.Before(*q.Since) {
+			continue
+		}
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deployed returns contracts.ErrNotFound when no status is configured.
+func (r stubRuntime) deployed() error {
+	if r.status == nil {
+		return contracts.ErrNotFound
+	}
+	return nil
+}
+
+// TestDeleteApp verifies app deletes, repeat deletes and teardown failures over HTTP.
+func TestDeleteApp(t *testing.T) {
+	tests := []struct {
+		label     string
+		ifMatch   string
+		removeErr error
+		wantCode  int
+		wantGone  bool
+	}{
+		{label: "deletes - 204", wantCode: http.StatusNoContent, wantGone: true},
+		{label: "current version - 204", ifMatch: `"1"`, wantCode: http.StatusNoContent, wantGone: true},
+		{label: "stale version - 412", ifMatch: `"2"`, wantCode: http.StatusPreconditionFailed},
+		{label: "teardown fails - 502", removeErr: errors.New("daemon unreachable"), wantCode: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			st := store.NewMemoryStore()
+			svc := service.NewAppServiceWithRuntime(st, stubRuntime{removeErr: tt.removeErr})
+			ts := httptest.NewServer(http_api.NewServer(svc, "").Router())
+			defer ts.Close()
+
+			created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+			appID, _ := created["id"].(string)
+
+			req := newRequest(t, http.MethodDelete, ts.URL+"/v0/apps/"+appID, nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			res := doRequest(t, req)
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+
+			getRes := doRequest(t, newRequest(t, http.MethodGet, ts.URL+"/v0/apps/"+appID, nil))
+			if !tt.wantGone {
+				assert.Equal(t, http.StatusOK, getRes.StatusCode)
+				return
+			}
+			assert.Equal(t, http.StatusNotFound, getRes.StatusCode)
+
+			// Deleting again is not an error.
+			again := doRequest(t, newRequest(t, http.MethodDelete, ts.URL+"/v0/apps/"+appID, nil))
+			assert.Equal(t, http.StatusNoContent, again.StatusCode)
+		})
+	}
+}
+
+// TestAppLifecycle verifies the stop, start, restart and runtime status routes.
+func TestAppLifecycle(t *testing.T) {
+	running := &domain.RuntimeStatus{State: domain.RuntimeStateRunning, RestartCount: 2}
+	tests := []struct {
+		label     string
+		method    string
+		action    string
+		status    *domain.RuntimeStatus
+		missing   bool
+		noRuntime bool
+		wantCode  int
+	}{
+		{label: "stop - 200", method: http.MethodPost, action: "stop", status: running, wantCode: http.StatusOK},
+		{label: "start - 200", method: http.MethodPost, action: "start", status: running, wantCode: http.StatusOK},
+		{label: "restart - 200", method: http.MethodPost, action: "restart", status: running, wantCode: http.StatusOK},
+		{label: "runtime status - 200", method: http.MethodGet, action: "runtime", status: running, wantCode: http.StatusOK},
+		{label: "never deployed - 404", method: http.MethodPost, action: "stop", wantCode: http.StatusNotFound},
+		{label: "missing app - 404", method: http.MethodGet, action: "runtime", missing: true, wantCode: http.StatusNotFound},
+		{label: "no runtime - 503", method: http.MethodPost, action: "restart", noRuntime: true, wantCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			st := store.NewMemoryStore()
+			svc := service.NewAppServiceWithRuntime(st, stubRuntime{status: tt.status})
+			if tt.noRuntime {
+				svc = service.NewAppService(st)
+			}
+			ts := httptest.NewServer(http_api.NewServer(svc, "").Router())
+			defer ts.Close()
+
+			appID := "missing"
+			if !tt.missing {
+				created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+				appID, _ = created["id"].(string)
+			}
+
+			res := doRequest(t, newRequest(t, tt.method, ts.URL+"/v0/apps/"+appID+"/"+tt.action, nil))
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got map[string]any
+			assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+			assert.Equal(t, "running", got["state"])
+			assert.Equal(t, float64(2), got["restartCount"])
+			assert.Equal(t, float64(0), got["exitCode"])
+			assert.Nil(t, got["startedAt"])
+		})
+	}
+}
+
+// TestPauseAndResume verifies pausing blocks deploys until resumed or forced.
+func TestPauseAndResume(t *testing.T) {
+	st := store.NewMemoryStore()
+	svc := service.NewAppServiceWithRuntime(st, stubRuntime{status: &domain.RuntimeStatus{State: domain.RuntimeStateRunning}})
+	ts := httptest.NewServer(http_api.NewServer(svc, "").Router())
+	defer ts.Close()
+
+	created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+	appID, _ := created["id"].(string)
+	post := func(path string) *http.Response {
+		return doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0"+path, nil))
+	}
+	appStatus := func(res *http.Response) string {
+		var got map[string]any
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+		status, _ := got["status"].(string)
+		return status
+	}
+
+	// Nothing is running yet.
+	assert.Equal(t, http.StatusConflict, post("/apps/"+appID+"/pause").StatusCode)
+
+	assert.Equal(t, http.StatusAccepted, post("/apps/"+appID+"/deploy").StatusCode)
+	assert.Equal(t, http.StatusOK, post("/deployments/next:process").StatusCode)
+
+	res := post("/apps/" + appID + "/pause")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "PAUSED", appStatus(res))
+	assert.NotEmpty(t, res.Header.Get("ETag"))
+
+	assert.Equal(t, http.StatusConflict, post("/apps/"+appID+"/deploy").StatusCode)
+	assert.Equal(t, http.StatusBadRequest, post("/apps/"+appID+"/deploy?force=maybe").StatusCode)
+
+	res = post("/apps/" + appID + "/resume")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "RUNNING", appStatus(res))
+
+	assert.Equal(t, http.StatusOK, post("/apps/"+appID+"/pause").StatusCode)
+	assert.Equal(t, http.StatusAccepted, post("/apps/"+appID+"/deploy?force=true").StatusCode)
+}
+
+// TestRollbackApp verifies rollbacks queue a deployment of an earlier spec and link back to it.
+func TestRollbackApp(t *testing.T) {
+	st := store.NewMemoryStore()
+	svc := service.NewAppServiceWithRuntime(st, stubRuntime{status: &domain.RuntimeStatus{State: domain.RuntimeStateRunning}})
+	ts := httptest.NewServer(http_api.NewServer(svc, "").Router())
+	defer ts.Close()
+
+	created := createApp(t, ts, "hello", "nginx:1.26", nil, nil, nil)
+	appID, _ := created["id"].(string)
+	post := func(path, body string) *http.Response {
+		var b []byte
+		if body != "" {
+			b = []byte(body)
+		}
+		return doRequest(t, newJSONRequest(t, http.MethodPost, ts.URL+"/v0"+path, b))
+	}
+	deployment := func(res *http.Response) map[string]any {
+		var got map[string]any
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+		return got
+	}
+
+	// One deployment is not enough to roll back from.
+	assert.Equal(t, http.StatusAccepted, post("/apps/"+appID+"/deploy", "").StatusCode)
+	first := deployment(post("/deployments/next:process", ""))
+	assert.Equal(t, http.StatusConflict, post("/apps/"+appID+"/rollback", "").StatusCode)
+
+	res := doRequest(t, newJSONRequest(t, http.MethodPatch, ts.URL+"/v0/apps/"+appID, []byte(`{"image":"nginx:1.27"}`)))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, http.StatusAccepted, post("/apps/"+appID+"/deploy", "").StatusCode)
+	assert.Equal(t, http.StatusOK, post("/deployments/next:process", "").StatusCode)
+
+	res = post("/apps/"+appID+"/rollback", "")
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	got := deployment(res)
+	assert.Equal(t, first["id"], got["rollbackOf"])
+	assert.Equal(t, "QUEUED", got["status"])
+	spec, _ := got["spec"].(map[string]any)
+	assert.Equal(t, "nginx:1.26", spec["image"])
+
+	res = post("/apps/"+appID+"/rollback", `{"deploymentId":"missing"}`)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	res = post("/apps/"+appID+"/rollback", `{"deploymentId":`)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	res = post("/apps/"+appID+"/rollback", `{"deploymentId":"`+first["id"].(string)+`"}`)
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+}
+
+// TestCancelAndRetryDeployment verifies canceling a queued deployment and retrying it.
+func TestCancelAndRetryDeployment(t *testing.T) {
+	ts, _ := newTestServer(t, "")
+	defer ts.Close()
+
+	created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+	appID, _ := created["id"].(string)
+	post := func(path string) *http.Response {
+		return doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0"+path, nil))
+	}
+	deployment := func(res *http.Response) map[string]any {
+		var got map[string]any
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+		return got
+	}
+
+	res := post("/apps/" + appID + "/deploy")
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	depID, _ := deployment(res)["id"].(string)
+
+	// Running deployments cannot be retried.
+	assert.Equal(t, http.StatusConflict, post("/deployments/"+depID+"/retry").StatusCode)
+
+	req := newRequest(t, http.MethodPost, ts.URL+"/v0/deployments/"+depID+"/cancel", nil)
+	req.Header.Set("If-Match", `"7"`)
+	assert.Equal(t, http.StatusPreconditionFailed, doRequest(t, req).StatusCode)
+
+	res = post("/deployments/" + depID + "/cancel")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "CANCELED", deployment(res)["status"])
+
+	res = post("/deployments/" + depID + "/retry")
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	retry := deployment(res)
+	assert.Equal(t, "QUEUED", retry["status"])
+	assert.Equal(t, depID, retry["retryOf"])
+
+	assert.Equal(t, http.StatusNotFound, post("/deployments/missing/cancel").StatusCode)
+	assert.Equal(t, http.StatusNotFound, post("/deployments/missing/retry").StatusCode)
+}
+
+// TestGetDeployment verifies the deployment detail includes its app, timings and queue position.
+func TestGetDeployment(t *testing.T) {
+	st := store.NewMemoryStore()
+	svc := service.NewAppServiceWithRuntime(st, stubRuntime{})
+	ts := httptest.NewServer(http_api.NewServer(svc, "").Router())
+	defer ts.Close()
+
+	created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+	appID, _ := created["id"].(string)
+	deploy := func() string {
+		res := doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0/apps/"+appID+"/deploy", nil))
+		var dep map[string]any
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&dep))
+		id, _ := dep["id"].(string)
+		return id
+	}
+	get := func(id string) (*http.Response, map[string]any) {
+		res := doRequest(t, newRequest(t, http.MethodGet, ts.URL+"/v0/deployments/"+id, nil))
+		var got map[string]any
+		if res.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+		}
+		return res, got
+	}
+	first, second := deploy(), deploy()
+
+	res, got := get(second)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NotEmpty(t, res.Header.Get("ETag"))
+	assert.Equal(t, "QUEUED", got["status"])
+	assert.EqualValues(t, 2, got["queuePosition"])
+	assert.NotContains(t, got, "runtimePort")
+	app, _ := got["app"].(map[string]any)
+	assert.Equal(t, appID, app["id"])
+	assert.Equal(t, "hello", app["name"])
+	spec, _ := got["spec"].(map[string]any)
+	assert.Equal(t, "nginx:latest", spec["image"])
+	timings, _ := got["timings"].(map[string]any)
+	assert.NotEmpty(t, timings["queuedAt"])
+	assert.Nil(t, timings["durationMs"])
+	steps, _ := got["steps"].([]any)
+	if assert.Len(t, steps, 5) {
+		step, _ := steps[0].(map[string]any)
+		assert.Equal(t, "pull_image", step["id"])
+		assert.Equal(t, "Pull image", step["name"])
+		assert.Equal(t, "pending", step["status"])
+		assert.Nil(t, step["startedAt"])
+	}
+
+	process := doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0/deployments/next:process", nil))
+	assert.Equal(t, http.StatusOK, process.StatusCode)
+	_, got = get(first)
+	assert.Equal(t, "RUNNING", got["status"])
+	assert.NotContains(t, got, "queuePosition")
+	assert.EqualValues(t, 80, got["runtimePort"])
+	timings, _ = got["timings"].(map[string]any)
+	assert.NotNil(t, timings["startedAt"])
+	assert.NotNil(t, timings["durationMs"])
+	// The stub runtime reports no steps, so none of them ran
+	steps, _ = got["steps"].([]any)
+	if assert.Len(t, steps, 5) {
+		step, _ := steps[0].(map[string]any)
+		assert.Equal(t, "skipped", step["status"])
+	}
+
+	res, _ = get("missing")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+// TestAppLogs verifies log pages, follow streams and deployment logs over HTTP.
+func TestAppLogs(t *testing.T) {
+	base := time.Now().UTC().Add(time.Hour)
+	lines := []domain.LogEntry{
+		{Timestamp: base, Level: domain.LogLevelInfo, Message: "listening", Source: domain.LogSourceStdout},
+		{Timestamp: base.Add(time.Second), Level: domain.LogLevelWarn, Message: "slow request", Source: domain.LogSourceStderr},
+		{Timestamp: base.Add(2 * time.Second), Level: domain.LogLevelError, Message: "boom", Source: domain.LogSourceStderr},
+	}
+	st := store.NewMemoryStore()
+	svc := service.NewAppServiceWithRuntime(st, stubRuntime{
+		status: &domain.RuntimeStatus{State: domain.RuntimeStateRunning},
+		logs:   lines,
+		build: []domain.LogEntry{
+			{Timestamp: base.Add(-2 * time.Hour), Level: domain.LogLevelInfo, Message: "Pulling from library/nginx", Source: string(domain.StepPullImage)},
+		},
+	})
+	ts := httptest.NewServer(http_api.NewServer(svc, "").Router())
+	defer ts.Close()
+
+	created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+	appID, _ := created["id"].(string)
+	getLogs := func(path string) (*http.Response, map[string]any) {
+		res := doRequest(t, newRequest(t, http.MethodGet, ts.URL+path, nil))
+		var got map[string]any
+		if res.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+		}
+		return res, got
+	}
+
+	res, got := getLogs("/v0/apps/" + appID + "/logs?limit=2")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	logs, _ := got["logs"].([]any)
+	if assert.Len(t, logs, 2) {
+		entry, _ := logs[1].(map[string]any)
+		assert.Equal(t, "slow request", entry["message"])
+		assert.Equal(t, "warn", entry["level"])
+		assert.Equal(t, "stderr", entry["source"])
+		assert.NotEmpty(t, entry["timestamp"])
+	}
+	assert.Equal(t, true, got["hasMore"])
+	cursor, _ := got["cursor"].(string)
+	assert.NotEmpty(t, cursor)
+
+	_, got = getLogs("/v0/apps/" + appID + "/logs?cursor=" + cursor)
+	logs, _ = got["logs"].([]any)
+	if assert.Len(t, logs, 1) {
+		entry, _ := logs[0].(map[string]any)
+		assert.Equal(t, "boom", entry["message"])
+	}
+	assert.Equal(t, false, got["hasMore"])
+
+	// Following streams every line as an event whose id resumes after it
+	res = doRequest(t, newRequest(t, http.MethodGet, ts.URL+"/v0/apps/"+appID+"/logs?follow=true", nil))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	events := strings.Split(strings.TrimSpace(string(body)), "\n\n")
+	if assert.Len(t, events, 3) {
+		assert.Contains(t, events[0], "event: log\n")
+		assert.Contains(t, events[0], `"message":"listening"`)
+		assert.Regexp(t, `(?m)^id: \S+$`, events[0])
+	}
+
+	// Last-Event-ID resumes a dropped stream
+	req := newRequest(t, http.MethodGet, ts.URL+"/v0/apps/"+appID+"/logs?follow=true", nil)
+	req.Header.Set("Last-Event-ID", cursor)
+	res = doRequest(t, req)
+	body, err = io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(body), "event: log"))
+	assert.Contains(t, string(body), `"message":"boom"`)
+
+	// A deployment's logs are its build log and then the output since it started; a queued one has none yet
+	deployRes := doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0/apps/"+appID+"/deploy", nil))
+	var dep map[string]any
+	assert.NoError(t, json.NewDecoder(deployRes.Body).Decode(&dep))
+	depID, _ := dep["id"].(string)
+	_, got = getLogs("/v0/deployments/" + depID + "/logs")
+	logs, _ = got["logs"].([]any)
+	assert.Empty(t, logs)
+	assert.Nil(t, got["cursor"])
+	process := doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0/deployments/next:process", nil))
+	assert.Equal(t, http.StatusOK, process.StatusCode)
+	_, got = getLogs("/v0/deployments/" + depID + "/logs")
+	logs, _ = got["logs"].([]any)
+	if assert.Len(t, logs, 4) {
+		entry, _ := logs[0].(map[string]any)
+		assert.Equal(t, "Pulling from library/nginx", entry["message"])
+		assert.Equal(t, "pull_image", entry["source"])
+	}
+	res = doRequest(t, newRequest(t, http.MethodGet, ts.URL+"/v0/deployments/"+depID+"/logs?follow=true", nil))
+	body, err = io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(body), "event: log"))
+
+	for _, query := range []string{"since=yesterday", "tail=x", "limit=x", "follow=maybe", "cursor=%21"} {
+		res, _ = getLogs("/v0/apps/" + appID + "/logs?" + query)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+	}
+	res, _ = getLogs("/v0/apps/missing/logs")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	res, _ = getLogs("/v0/deployments/missing/logs")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+// readEvent reads the next server-sent event from r as its field lines, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return fields
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		name, value, _ := strings.Cut(line, ": ")
+		fields[name] = value
+	}
+}
+
+// TestEvents verifies deployment and app changes stream as events that resume from Last-Event-ID.
+func TestEvents(t *testing.T) {
+	ts, _ := newTestServer(t, "")
+	defer ts.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+	appID, _ := created["id"].(string)
+	subscribe := func(lastEventID string) *http.Response {
+		req := newRequest(t, http.MethodGet, ts.URL+"/v0/events?appId="+appID, nil).WithContext(ctx)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		return doRequest(t, req)
+	}
+
+	res := subscribe("")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	stream := bufio.NewReader(res.Body)
+
+	deployRes := doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0/apps/"+appID+"/deploy", nil))
+	assert.Equal(t, http.StatusAccepted, deployRes.StatusCode)
+
+	first := readEvent(t, stream)
+	assert.Equal(t, "deployment.status", first["event"])
+	assert.NotEmpty(t, first["id"])
+	var dep map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(first["data"]), &dep))
+	assert.Equal(t, "deployment.status", dep["type"])
+	assert.Equal(t, appID, dep["appId"])
+	deployment, _ := dep["deployment"].(map[string]any)
+	assert.Equal(t, "QUEUED", deployment["status"])
+	assert.Nil(t, dep["app"])
+
+	second := readEvent(t, stream)
+	assert.Equal(t, "app.status", second["event"])
+	var appEvent map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(second["data"]), &appEvent))
+	app, _ := appEvent["app"].(map[string]any)
+	assert.Equal(t, "BUILDING", app["status"])
+	assert.Equal(t, "hello", app["name"])
+
+	// A reconnecting client picks up after the last event it saw
+	resumed := readEvent(t, bufio.NewReader(subscribe(first["id"]).Body))
+	assert.Equal(t, second["id"], resumed["id"])
+	assert.Equal(t, "app.status", resumed["event"])
+
+	reset := readEvent(t, bufio.NewReader(subscribe("999999").Body))
+	assert.Equal(t, "reset", reset["event"])
+
+	res = subscribe("x")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	res = doRequest(t, newRequest(t, http.MethodGet, ts.URL+"/v0/events?appId=missing", nil))
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+// TestCloseStreams verifies closing the streams ends open event and log streams, as a shutdown does.
+func TestCloseStreams(t *testing.T) {
+	st := store.NewMemoryStore()
+	rt, _ := docker.New(docker.WithNamePrefix("spacescale-http-api-"))
+	api := http_api.NewServer(service.NewAppServiceWithRuntime(st, rt), "")
+	ts := httptest.NewServer(api.Router())
+	defer ts.Close()
+
+	created := createApp(t, ts, "hello", "nginx:latest", nil, nil, nil)
+	appID, _ := created["id"].(string)
+	deployRes := doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0/apps/"+appID+"/deploy", nil))
+	var dep map[string]any
+	assert.NoError(t, json.NewDecoder(deployRes.Body).Decode(&dep))
+	depID, _ := dep["id"].(string)
+
+	// A queued deployment has no build log yet, so following it waits for lines
+	logsDone := make(chan *http.Response, 1)
+	go func() {
+		res, err := http.DefaultClient.Do(newRequest(t, http.MethodGet, ts.URL+"/v0/deployments/"+depID+"/logs?follow=true", nil))
+		if assert.NoError(t, err) {
+			_, _ = io.ReadAll(res.Body)
+			_ = res.Body.Close()
+		}
+		logsDone <- res
+	}()
+	events := doRequest(t, newRequest(t, http.MethodGet, ts.URL+"/v0/events?appId="+appID, nil))
+	assert.Equal(t, http.StatusOK, events.StatusCode)
+
+	api.CloseStreams()
+	eventsDone := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(events.Body)
+		eventsDone <- err
+	}()
+	select {
+	case err := <-eventsDone:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("event stream stayed open")
+	}
+	select {
+	case res := <-logsDone:
+		if assert.NotNil(t, res) {
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("log stream stayed open")
+	}
+}
+
+// TestWorkerProtocol verifies a worker claims, heartbeats and reports a deployment with its token.
+func TestWorkerProtocol(t *testing.T) {
+	ts, _ := newTestServer(t, "secret")
+	defer ts.Close()
+
+	call := func(path, token string, body any) *http.Response {
+		payload, err := json.Marshal(body)
+		assert.NoError(t, err)
+		req := newJSONRequest(t, http.MethodPost, ts.URL+"/v0/deployments/"+path, payload)
+		if token != "" {
+			req.Header.Set("X-Worker-Token", token)
+		}
+		return doRequest(t, req)
+	}
+
+	res := call("next:claim", "", map[string]string{"workerId": "worker-1"})
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res = call("next:claim", "secret", map[string]string{"workerId": "worker-1"})
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	res = call("next:claim", "secret", map[string]string{})
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	created := createApp(t, ts, "hello", "nginx:latest", ptrInt(8080), nil, map[string]string{"A": "1"})
+	appID := created["id"].(string)
+	res = doRequest(t, newRequest(t, http.MethodPost, ts.URL+"/v0/apps/"+appID+"/deploy", nil))
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+	res = call("next:claim", "secret", map[string]string{"workerId": "worker-1"})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var claim struct {
+		Deployment   map[string]any `json:"deployment"`
+		App          map[string]any `json:"app"`
+		LeaseSeconds int            `json:"leaseSeconds"`
+	}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&claim))
+	depID, _ := claim.Deployment["id"].(string)
+	assert.Equal(t, "DEPLOYING", claim.Deployment["status"])
+	assert.Equal(t, map[string]any{"A": "1"}, claim.App["env"])
+	assert.Positive(t, claim.LeaseSeconds)
+
+	res = call(depID+"/heartbeat", "secret", map[string]any{"workerId": "worker-2"})
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+	res = call(depID+"/heartbeat", "secret", map[string]any{
+		"workerId": "worker-1",
+		"steps":    []map[string]any{{"step": "pull_image"}, {"step": "pull_image", "finished": true}},
+		"logs":     []map[string]any{{"timestamp": time.Now().UTC(), "level": "info", "message": "pulled"}},
+	})
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	res = call(depID+"/report", "secret", map[string]any{"workerId": "worker-1", "url": "https://hello.example.com"})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var done map[string]any
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&done))
+	assert.Equal(t, "RUNNING", done["status"])
+	assert.Equal(t, "https://hello.example.com", done["url"])
+
+	// The claim ended with the report
+	res = call(depID+"/report", "secret", map[string]any{"workerId": "worker-1"})
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+}
@@ -0,0 +1,79 @@
+// Server-sent event helpers for streaming endpoints.
+package http_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// sseWriter writes server-sent events and flushes each one to the client.
+// Headers go out with the first event, so an error found before then can still be a normal response.
+type sseWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	started bool
+}
+
+// newSSEWriter wraps w for streaming events.
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	return &sseWriter{w: w, rc: http.NewResponseController(w)}
+}
+
+// Started reports whether the stream headers were sent.
+func (s *sseWriter) Started() bool {
+	return s.started
+}
+
+// Start sends the stream headers if they have not been sent yet.
+func (s *sseWriter) Start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	h := s.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// Ask reverse proxies such as nginx not to buffer the stream
+	h.Set("X-Accel-Buffering", "no")
+	s.w.WriteHeader(http.StatusOK)
+	return s.rc.Flush()
+}
+
+// Send writes one event with data encoded as JSON. An empty id leaves the client's last id alone.
+func (s *sseWriter) Send(id, event string, data any) error {
+	if err := s.Start(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	if id != "" {
+		fmt.Fprintf(&b, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	fmt.Fprintf(&b, "data: %s\n\n", body)
+	return s.write(b.String())
+}
+
+// Comment writes a comment line, which clients ignore; it keeps idle connections from timing out.
+func (s *sseWriter) Comment(text string) error {
+	if err := s.Start(); err != nil {
+		return err
+	}
+	return s.write(": " + text + "\n\n")
+}
+
+// write sends raw stream text and flushes it.
+func (s *sseWriter) write(text string) error {
+	if _, err := s.w.Write([]byte(text)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
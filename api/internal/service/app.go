@@ -10,7 +10,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/t0gun/spacescale/internal/config"
 	"github.com/t0gun/spacescale/internal/contracts"
 	"github.com/t0gun/spacescale/internal/domain"
 )
@@ -20,37 +22,84 @@ import (
 type AppService struct {
 	store   contracts.Store
 	runtime contracts.Runtime
+
+	// workerID identifies this process when it claims queued deployments
+	workerID string
+	// lease is how long a claim lasts before it must be renewed
+	lease time.Duration
+	// maxAttempts bounds how many times one deployment is claimed
+	maxAttempts int
+	// reaper configures recovery of stuck deployments
+	reaper ReaperConfig
+	// inflight cancels deploys this process is running when their deployment is canceled
+	inflight inflightDeploys
+	// events publishes deployment and app changes to subscribers
+	events *eventBus
 }
 
+// defaultLease is long enough to cover a few missed heartbeats on a slow store.
+const defaultLease = time.Minute
+
+// Option configures AppService construction.
+type Option func(*AppService)
+
+// WithWorkerID sets the id recorded on deployments this service claims.
+func WithWorkerID(id string) Option { return func(s *AppService) { s.workerID = id } }
+
+// WithLease sets how long a deployment claim lasts without a heartbeat.
+func WithLease(d time.Duration) Option { return func(s *AppService) { s.lease = d } }
+
 // NewAppService builds an app service without a runtime.
-func NewAppService(store contracts.Store) *AppService {
-	return &AppService{store: store}
+func NewAppService(store contracts.Store, opts ...Option) *AppService {
+	return newAppService(store, nil, opts)
 }
 
 // NewAppServiceWithRuntime builds an app service with a runtime.
-func NewAppServiceWithRuntime(store contracts.Store, rt contracts.Runtime) *AppService {
-	return &AppService{store: store, runtime: rt}
+func NewAppServiceWithRuntime(store contracts.Store, rt contracts.Runtime, opts ...Option) *AppService {
+	return newAppService(store, rt, opts)
+}
+
+// newAppService applies defaults and options.
+func newAppService(store contracts.Store, rt contracts.Runtime, opts []Option) *AppService {
+	s := &AppService{
+		store:       store,
+		runtime:     rt,
+		workerID:    config.DefaultWorkerID(),
+		lease:       defaultLease,
+		maxAttempts: defaultMaxAttempts,
+		reaper: ReaperConfig{
+			Deadline: defaultReaperDeadline,
+			Interval: defaultReaperInterval,
+		},
+		events: newEventBus(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // CreateAppParams collects the input needed to create a new application
 // Validation is performed in the domain constructor
 type CreateAppParams struct {
-	Name   string
-	Image  string
-	Port   *int
-	Expose *bool
-	Env    map[string]string
+	Name        string
+	Image       string
+	Port        *int
+	Expose      *bool
+	Env         map[string]string
+	HealthCheck *domain.HealthCheck
 }
 
 // CreateApp validates input and stores a new app.
 func (s *AppService) CreateApp(ctx context.Context, p CreateAppParams) (domain.App, error) {
 	// Build and validate the domain object first to keep rules in one place
 	app, err := domain.NewApp(domain.NewAppParams{
-		Name:   p.Name,
-		Image:  p.Image,
-		Port:   p.Port,
-		Expose: p.Expose,
-		Env:    p.Env,
+		Name:        p.Name,
+		Image:       p.Image,
+		Port:        p.Port,
+		Expose:      p.Expose,
+		Env:         p.Env,
+		HealthCheck: p.HealthCheck,
 	})
 	if err != nil {
 		return domain.App{}, fmt.Errorf("%w: %v", ErrInvalidInput, err)
@@ -67,9 +116,53 @@ func (s *AppService) CreateApp(ctx context.Context, p CreateAppParams) (domain.A
 	return app, nil
 }
 
-// ListApps returns all stored apps.
-func (s *AppService) ListApps(ctx context.Context) ([]domain.App, error) {
-	return s.store.ListApps(ctx)
+// ListAppsParams filters and pages the app list
+// Empty filters match every app
+type ListAppsParams struct {
+	Status     domain.AppStatus
+	NamePrefix string
+	Page       int // 1-based, 0 means the first page
+	PageSize   int // 0 means DefaultPageSize
+}
+
+// AppList is one page of apps and the total number of matches
+// Latest holds each listed app's newest deployment, keyed by app id
+type AppList struct {
+	Apps     []domain.App
+	Latest   map[string]domain.Deployment
+	Total    int
+	Page     int
+	PageSize int
+}
+
+// ListApps returns one page of apps sorted by creation time.
+func (s *AppService) ListApps(ctx context.Context, p ListAppsParams) (AppList, error) {
+	if p.Status != "" && !p.Status.Valid() {
+		return AppList{}, fmt.Errorf("%w: unknown status %q", ErrInvalidInput, p.Status)
+	}
+	window, page, pageSize, err := resolvePage(p.Page, p.PageSize)
+	if err != nil {
+		return AppList{}, err
+	}
+
+	apps, total, err := s.store.ListApps(ctx, contracts.AppFilter{
+		Status:     p.Status,
+		NamePrefix: p.NamePrefix,
+		Page:       window,
+	})
+	if err != nil {
+		return AppList{}, err
+	}
+
+	ids := make([]string, 0, len(apps))
+	for _, a := range apps {
+		ids = append(ids, a.ID)
+	}
+	latest, err := s.store.LatestDeployments(ctx, ids)
+	if err != nil {
+		return AppList{}, err
+	}
+	return AppList{Apps: apps, Latest: latest, Total: total, Page: page, PageSize: pageSize}, nil
 }
 
 // GetAppByID returns a single app by id.
@@ -86,3 +179,95 @@ func (s *AppService) GetAppByID(ctx context.Context, id string) (domain.App, err
 	}
 	return app, nil
 }
+
+// UpdateAppParams holds a partial update for an app
+// Nil fields are left alone; a non-nil empty Env clears the env
+// and a HealthCheck without a Type removes the health check
+// IfVersion, when set, refuses the update unless the app is still at that version
+type UpdateAppParams struct {
+	AppID       string
+	IfVersion   int64
+	Name        *string
+	Image       *string
+	Port        *int
+	Expose      *bool
+	Env         map[string]string
+	HealthCheck *domain.HealthCheck
+}
+
+// AppUpdate is an updated app and whether its running deployment is now out of date
+type AppUpdate struct {
+	App              domain.App
+	RedeployRequired bool
+}
+
+// maxUpdateRetries bounds how often an unconditional update re-reads an app that changed underneath it.
+const maxUpdateRetries = 3
+
+// UpdateApp applies a partial update to an app.
+// Without IfVersion the update is retried when a concurrent write, such as a status change, wins the race.
+func (s *AppService) UpdateApp(ctx context.Context, p UpdateAppParams) (AppUpdate, error) {
+	if p.AppID == "" {
+		return AppUpdate{}, fmt.Errorf("%w: app id is required", ErrInvalidInput)
+	}
+	patch := domain.AppPatch{
+		Name:        p.Name,
+		Image:       p.Image,
+		Port:        p.Port,
+		Expose:      p.Expose,
+		Env:         p.Env,
+		HealthCheck: p.HealthCheck,
+	}
+	if patch.Empty() {
+		return AppUpdate{}, fmt.Errorf("%w: nothing to update", ErrInvalidInput)
+	}
+
+	for attempt := 0; ; attempt++ {
+		current, err := s.GetAppByID(ctx, p.AppID)
+		if err != nil {
+			return AppUpdate{}, err
+		}
+		if p.IfVersion != 0 && current.Version != p.IfVersion {
+			return AppUpdate{}, fmt.Errorf("%w: app is at version %d", ErrVersionMismatch, current.Version)
+		}
+
+		patched, err := current.Patch(patch)
+		if err != nil {
+			return AppUpdate{}, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+		stored, err := s.store.UpdateApp(ctx, patched)
+		switch {
+		case err == nil:
+			redeploy, err := s.redeployRequired(ctx, current, stored)
+			if err != nil {
+				return AppUpdate{}, err
+			}
+			return AppUpdate{App: stored, RedeployRequired: redeploy}, nil
+		case errors.Is(err, contracts.ErrVersionMismatch):
+			if p.IfVersion != 0 || attempt+1 >= maxUpdateRetries {
+				return AppUpdate{}, ErrVersionMismatch
+			}
+		case errors.Is(err, contracts.ErrConflict):
+			return AppUpdate{}, ErrConflict
+		case errors.Is(err, contracts.ErrNotFound):
+			return AppUpdate{}, ErrNotFound
+		default:
+			return AppUpdate{}, err
+		}
+	}
+}
+
+// redeployRequired reports whether the app's latest deployment no longer matches the updated app.
+// Apps that were never deployed have nothing to redeploy.
+func (s *AppService) redeployRequired(ctx context.Context, before, after domain.App) (bool, error) {
+	latest, err := s.store.LatestDeployments(ctx, []string{after.ID})
+	if err != nil {
+		return false, err
+	}
+	dep, ok := latest[after.ID]
+	if !ok {
+		return false, nil
+	}
+	// The name picks the container and its hostname, so a rename also needs a new deployment.
+	return before.Name != after.Name || !dep.Spec.Same(domain.SnapshotSpec(after)), nil
+}
@@ -0,0 +1,65 @@
+// App status kept in step with deployments
+// An app reports the state of its latest deployment
+// Older deployments finishing late never overwrite a newer one's state
+// Syncing is best effort; the deployment record stays the source of truth
+// The latest deployment also supplies the url shown on the app
+
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// syncAppStatus mirrors dep's state onto its app when dep is the app's latest deployment.
+// Failures are logged rather than returned because the deployment outcome is already stored.
+func (s *AppService) syncAppStatus(ctx context.Context, dep domain.Deployment) {
+	latest, err := s.store.LatestDeployments(ctx, []string{dep.AppID})
+	if err != nil {
+		log.Printf("sync app %s status: %v", dep.AppID, err)
+		return
+	}
+	if cur, ok := latest[dep.AppID]; !ok || cur.ID != dep.ID {
+		return
+	}
+
+	want := dep.Status.AppStatus()
+	app, err := s.store.GetAppByID(ctx, dep.AppID)
+	if err != nil {
+		if !errors.Is(err, contracts.ErrNotFound) {
+			log.Printf("sync app %s status: %v", dep.AppID, err)
+		}
+		return
+	}
+	if app.Status == want || app.Status == domain.AppStatusDeleting {
+		return
+	}
+	updated, err := s.store.UpdateAppStatus(ctx, dep.AppID, want)
+	if err != nil {
+		if !errors.Is(err, contracts.ErrNotFound) {
+			log.Printf("sync app %s status: %v", dep.AppID, err)
+		}
+		return
+	}
+	s.appChanged(updated)
+}
+
+// LatestDeployment returns the newest deployment of an app, or nil when it has none.
+func (s *AppService) LatestDeployment(ctx context.Context, appID string) (*domain.Deployment, error) {
+	if appID == "" {
+		return nil, ErrInvalidInput
+	}
+	latest, err := s.store.LatestDeployments(ctx, []string{appID})
+	if err != nil {
+		return nil, err
+	}
+	dep, ok := latest[appID]
+	if !ok {
+		return nil, nil
+	}
+	return &dep, nil
+}
@@ -0,0 +1,114 @@
+// Tests for app status synchronization
+// Tests follow an app through deploy, success and failure
+// Tests verify a late finish of an older deployment is ignored
+// Tests cover the latest deployment lookup
+// These tests keep app status honest
+
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// TestAppStatusFollowsDeployments verifies the app mirrors its latest deployment.
+func TestAppStatusFollowsDeployments(t *testing.T) {
+	t.Run("deploy then succeed", func(t *testing.T) {
+		ctx := context.Background()
+		st := store.NewMemoryStore()
+		rt := &fakeRuntime{url: ptrString("https://hello.example.com")}
+		svc := service.NewAppServiceWithRuntime(st, rt)
+
+		app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+		require.NoError(t, err)
+		assert.Equal(t, domain.AppStatusCreated, app.Status)
+
+		_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+		require.NoError(t, err)
+		got, err := svc.GetAppByID(ctx, app.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.AppStatusBuilding, got.Status)
+
+		_, err = svc.ProcessNextDeployment(ctx)
+		require.NoError(t, err)
+		got, err = svc.GetAppByID(ctx, app.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.AppStatusRunning, got.Status)
+		assert.Greater(t, got.Version, app.Version)
+	})
+
+	t.Run("deploy then fail", func(t *testing.T) {
+		ctx := context.Background()
+		st := store.NewMemoryStore()
+		rt := &fakeRuntime{err: errors.New("boom")}
+		svc := service.NewAppServiceWithRuntime(st, rt)
+
+		app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+		require.NoError(t, err)
+		_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+		require.NoError(t, err)
+
+		_, err = svc.ProcessNextDeployment(ctx)
+		assert.Error(t, err)
+		got, err := svc.GetAppByID(ctx, app.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.AppStatusFailed, got.Status)
+	})
+
+	t.Run("older deployment finishing does not win", func(t *testing.T) {
+		ctx := context.Background()
+		st := store.NewMemoryStore()
+		rt := &fakeRuntime{url: ptrString("https://hello.example.com")}
+		svc := service.NewAppServiceWithRuntime(st, rt)
+
+		app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+		require.NoError(t, err)
+		first, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+		require.NoError(t, err)
+		second, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+		require.NoError(t, err)
+
+		done, err := svc.ProcessNextDeployment(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, done.ID)
+
+		got, err := svc.GetAppByID(ctx, app.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.AppStatusBuilding, got.Status)
+
+		latest, err := svc.LatestDeployment(ctx, app.ID)
+		require.NoError(t, err)
+		require.NotNil(t, latest)
+		assert.Equal(t, second.ID, latest.ID)
+	})
+}
+
+// TestLatestDeployment verifies lookups for apps with and without deployments.
+func TestLatestDeployment(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	svc := service.NewAppService(st)
+
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+
+	latest, err := svc.LatestDeployment(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Nil(t, latest)
+
+	_, err = svc.LatestDeployment(ctx, "")
+	assert.ErrorIs(t, err, service.ErrInvalidInput)
+
+	dep, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	list, err := svc.ListApps(ctx, service.ListAppsParams{})
+	require.NoError(t, err)
+	assert.Equal(t, dep.ID, list.Latest[app.ID].ID)
+}
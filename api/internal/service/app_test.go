@@ -12,6 +12,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
 	"github.com/t0gun/spacescale/internal/service"
 )
 
@@ -147,3 +149,133 @@ func TestGetAppByID(t *testing.T) {
 		assert.Equal(t, created.Port, app.Port)
 	})
 }
+
+// TestListApps verifies app listing with filters and pages.
+func TestListApps(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	svc := service.NewAppService(st)
+
+	for _, name := range []string{"web-a", "web-b", "api"} {
+		_, err := svc.CreateApp(ctx, service.CreateAppParams{Name: name, Image: "nginx:latest"})
+		assert.NoError(t, err)
+	}
+
+	list, err := svc.ListApps(ctx, service.ListAppsParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, list.Total)
+	assert.Len(t, list.Apps, 3)
+	assert.Equal(t, "web-a", list.Apps[0].Name)
+	assert.Equal(t, 1, list.Page)
+	assert.Equal(t, service.DefaultPageSize, list.PageSize)
+
+	list, err = svc.ListApps(ctx, service.ListAppsParams{NamePrefix: "web-", Page: 2, PageSize: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, list.Total)
+	assert.Len(t, list.Apps, 1)
+	assert.Equal(t, "web-b", list.Apps[0].Name)
+
+	list, err = svc.ListApps(ctx, service.ListAppsParams{Status: domain.AppStatusRunning})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, list.Total)
+	assert.Empty(t, list.Apps)
+
+	_, err = svc.ListApps(ctx, service.ListAppsParams{Status: "NOPE"})
+	assert.ErrorIs(t, err, service.ErrInvalidInput)
+}
+
+// TestUpdateApp verifies partial updates, renames and the redeploy hint.
+func TestUpdateApp(t *testing.T) {
+	newSvc := func(t *testing.T) (*service.AppService, *store.MemoryStore, domain.App) {
+		t.Helper()
+		st := store.NewMemoryStore()
+		svc := service.NewAppService(st)
+		app, err := svc.CreateApp(context.Background(), service.CreateAppParams{Name: "hello", Image: "nginx:latest", Port: ptrInt(8080)})
+		assert.NoError(t, err)
+		return svc, st, app
+	}
+
+	t.Run("ok: rename keeps name lookup consistent", func(t *testing.T) {
+		ctx := context.Background()
+		svc, st, app := newSvc(t)
+
+		upd, err := svc.UpdateApp(ctx, service.UpdateAppParams{AppID: app.ID, Name: ptrString("renamed")})
+		assert.NoError(t, err)
+		assert.Equal(t, "renamed", upd.App.Name)
+		assert.Equal(t, app.Image, upd.App.Image)
+		assert.Equal(t, app.Version+1, upd.App.Version)
+		assert.False(t, upd.RedeployRequired)
+
+		byName, err := st.GetAppByName(ctx, "renamed")
+		assert.NoError(t, err)
+		assert.Equal(t, app.ID, byName.ID)
+		_, err = st.GetAppByName(ctx, "hello")
+		assert.ErrorIs(t, err, contracts.ErrNotFound)
+	})
+
+	t.Run("redeploy required only when the deployed spec changes", func(t *testing.T) {
+		ctx := context.Background()
+		svc, _, app := newSvc(t)
+		_, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+		assert.NoError(t, err)
+
+		// Same image as deployed, so nothing to roll out.
+		upd, err := svc.UpdateApp(ctx, service.UpdateAppParams{AppID: app.ID, Image: ptrString("nginx:latest")})
+		assert.NoError(t, err)
+		assert.False(t, upd.RedeployRequired)
+
+		upd, err = svc.UpdateApp(ctx, service.UpdateAppParams{AppID: app.ID, Env: map[string]string{"A": "1"}})
+		assert.NoError(t, err)
+		assert.True(t, upd.RedeployRequired)
+		assert.Equal(t, map[string]string{"A": "1"}, upd.App.Env)
+	})
+
+	t.Run("health check changes need a redeploy", func(t *testing.T) {
+		ctx := context.Background()
+		svc, _, app := newSvc(t)
+		_, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+		assert.NoError(t, err)
+
+		upd, err := svc.UpdateApp(ctx, service.UpdateAppParams{
+			AppID:       app.ID,
+			HealthCheck: &domain.HealthCheck{Type: domain.HealthCheckHTTP, Path: "/healthz"},
+		})
+		assert.NoError(t, err)
+		assert.True(t, upd.RedeployRequired)
+		assert.Equal(t, "/healthz", upd.App.HealthCheck.Path)
+
+		// An empty check removes it, matching the deployed spec again.
+		upd, err = svc.UpdateApp(ctx, service.UpdateAppParams{AppID: app.ID, HealthCheck: &domain.HealthCheck{}})
+		assert.NoError(t, err)
+		assert.Nil(t, upd.App.HealthCheck)
+		assert.False(t, upd.RedeployRequired)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ctx := context.Background()
+		svc, _, app := newSvc(t)
+		_, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "taken", Image: "nginx:latest"})
+		assert.NoError(t, err)
+
+		tests := []struct {
+			label string
+			p     service.UpdateAppParams
+			err   error
+		}{
+			{"empty id", service.UpdateAppParams{Name: ptrString("x")}, service.ErrInvalidInput},
+			{"empty patch", service.UpdateAppParams{AppID: app.ID}, service.ErrInvalidInput},
+			{"bad name", service.UpdateAppParams{AppID: app.ID, Name: ptrString("Bad_Name")}, service.ErrInvalidInput},
+			{"bad port", service.UpdateAppParams{AppID: app.ID, Port: ptrInt(0)}, service.ErrInvalidInput},
+			{"bad health check", service.UpdateAppParams{AppID: app.ID, HealthCheck: &domain.HealthCheck{Type: "grpc"}}, service.ErrInvalidInput},
+			{"missing app", service.UpdateAppParams{AppID: "missing", Name: ptrString("x")}, service.ErrNotFound},
+			{"name taken", service.UpdateAppParams{AppID: app.ID, Name: ptrString("taken")}, service.ErrConflict},
+			{"stale version", service.UpdateAppParams{AppID: app.ID, IfVersion: app.Version + 5, Name: ptrString("x")}, service.ErrVersionMismatch},
+		}
+		for _, tt := range tests {
+			t.Run(tt.label, func(t *testing.T) {
+				_, err := svc.UpdateApp(ctx, tt.p)
+				assert.ErrorIs(t, err, tt.err)
+			})
+		}
+	})
+}
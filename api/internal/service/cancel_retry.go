@@ -0,0 +1,150 @@
+// Service logic for canceling and retrying deployments
+// Canceling a queued deployment takes it off the queue before a worker claims it
+// Canceling an in-flight deployment also cancels the context its runtime deploy runs under
+// Retrying clones a FAILED or CANCELED deployment's spec into a new queued deployment
+// The retry links back to the deployment it was cloned from
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// CancelDeploymentParams identifies the deployment to cancel
+// IfVersion, when set, refuses the cancel unless the deployment is still at that version
+type CancelDeploymentParams struct {
+	DeploymentID string
+	IfVersion    int64
+}
+
+// RetryDeploymentParams identifies the deployment to retry
+// Force allows retrying on a paused app, as it does for DeployApp
+type RetryDeploymentParams struct {
+	DeploymentID string
+	Force        bool
+}
+
+// CancelDeployment cancels a queued or in-flight deployment and returns it.
+// Canceling a deployment that is already CANCELED returns it unchanged; one that finished otherwise is a conflict,
+// as is one whose runtime already began switching traffic to the new container.
+func (s *AppService) CancelDeployment(ctx context.Context, p CancelDeploymentParams) (domain.Deployment, error) {
+	dep, err := s.getDeployment(ctx, p.DeploymentID)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	if p.IfVersion != 0 && dep.Version != p.IfVersion {
+		return domain.Deployment{}, fmt.Errorf("%w: deployment is at version %d", ErrVersionMismatch, dep.Version)
+	}
+	dep, err = s.cancelDeployment(ctx, dep)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	if dep.Status != domain.DeploymentStatusCanceled {
+		return domain.Deployment{}, fmt.Errorf("%w: deployment already finished as %s", ErrConflict, dep.Status)
+	}
+	return dep, nil
+}
+
+// RetryDeployment queues a new deployment with the spec of a FAILED or CANCELED one.
+func (s *AppService) RetryDeployment(ctx context.Context, p RetryDeploymentParams) (domain.Deployment, error) {
+	orig, err := s.getDeployment(ctx, p.DeploymentID)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	if orig.Status != domain.DeploymentStatusFailed && orig.Status != domain.DeploymentStatusCanceled {
+		return domain.Deployment{}, fmt.Errorf("%w: only FAILED or CANCELED deployments can be retried, not %s",
+			ErrConflict, orig.Status)
+	}
+	if _, err := s.deployableApp(ctx, orig.AppID, 0, p.Force); err != nil {
+		return domain.Deployment{}, err
+	}
+
+	dep := domain.NewDeployment(orig.AppID)
+	dep.Spec = orig.Spec
+	// A retried rollback still returns to the same deployment
+	dep.RollbackOf = orig.RollbackOf
+	dep.RetryOf = &orig.ID
+	return s.enqueue(ctx, dep)
+}
+
+// getDeployment loads a deployment by id and maps a missing one to ErrNotFound.
+func (s *AppService) getDeployment(ctx context.Context, id string) (domain.Deployment, error) {
+	if id == "" {
+		return domain.Deployment{}, fmt.Errorf("%w: deployment id is required", ErrInvalidInput)
+	}
+	dep, err := s.store.GetDeploymentByID(ctx, id)
+	if errors.Is(err, contracts.ErrNotFound) {
+		return domain.Deployment{}, ErrNotFound
+	}
+	return dep, err
+}
+
+// inflightDeploys holds the deploys this process is running, keyed by deployment id.
+// Deploys on other workers notice a cancel when their next lease renewal fails.
+type inflightDeploys struct {
+	mu      sync.Mutex
+	deploys map[string]inflightDeploy
+}
+
+// inflightDeploy is one deploy running in this process.
+type inflightDeploy struct {
+	cancel context.CancelFunc
+	// done is closed once the deploy has returned and its outcome is stored
+	done chan struct{}
+}
+
+// track registers cancel for depID and returns a func that unregisters it and marks the deploy done.
+func (f *inflightDeploys) track(depID string, cancel context.CancelFunc) (untrack func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.deploys == nil {
+		f.deploys = make(map[string]inflightDeploy)
+	}
+	d := inflightDeploy{cancel: cancel, done: make(chan struct{})}
+	f.deploys[depID] = d
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.deploys, depID)
+		close(d.done)
+	}
+}
+
+// cancel cancels the deploy of depID if this process is running it.
+func (f *inflightDeploys) cancel(depID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if d, ok := f.deploys[depID]; ok {
+		d.cancel()
+	}
+}
+
+// running reports whether this process is running the deploy of depID.
+func (f *inflightDeploys) running(depID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.deploys[depID]
+	return ok
+}
+
+// wait blocks until this process is no longer running the deploy of depID or ctx is done.
+func (f *inflightDeploys) wait(ctx context.Context, depID string) error {
+	f.mu.Lock()
+	d, ok := f.deploys[depID]
+	f.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
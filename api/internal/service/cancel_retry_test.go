@@ -0,0 +1,273 @@
+// Tests for canceling and retrying deployments
+// Tests verify a canceled queued deployment is never claimed
+// Tests verify an in-flight cancel stops the runtime deploy, in this process or another
+// Tests verify a cancel is refused once the deploy began switching traffic
+// Tests verify retries clone failed or canceled deployments and link back to them
+// These tests keep finished deployments from being canceled or retried twice over
+
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// startedRuntime signals when a deploy starts and then blocks until its context ends.
+type startedRuntime struct {
+	noopLifecycle
+	started chan struct{}
+}
+
+// Deploy reports the start and waits for a cancel.
+func (r startedRuntime) Deploy(ctx context.Context, app domain.App, _ contracts.DeployProgress) (*string, error) {
+	close(r.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// switchRuntime pauses before and during the switch so a test can cancel at either point.
+type switchRuntime struct {
+	noopLifecycle
+	// reached gets where the deploy paused and resume lets it go on
+	reached chan string
+	resume  chan struct{}
+}
+
+// Deploy switches like the docker runtime: a cancel that lands before the switch starts stops it.
+func (r switchRuntime) Deploy(ctx context.Context, _ domain.App, progress contracts.DeployProgress) (*string, error) {
+	r.pause("before")
+	progress.StepStarted(domain.StepReplaceContainer)
+	if err := ctx.Err(); err != nil {
+		progress.StepFinished(domain.StepReplaceContainer, err)
+		return nil, err
+	}
+	r.pause("during")
+	progress.StepFinished(domain.StepReplaceContainer, nil)
+	url := "https://hello.example.com"
+	return &url, nil
+}
+
+// pause reports at and waits to be resumed.
+func (r switchRuntime) pause(at string) {
+	r.reached <- at
+	<-r.resume
+}
+
+// TestCancelDeployment_Queued verifies a canceled queued deployment leaves the queue.
+func TestCancelDeployment_Queued(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &fakeRuntime{}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	queued, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+
+	canceled, err := svc.CancelDeployment(ctx, service.CancelDeploymentParams{DeploymentID: queued.ID, IfVersion: queued.Version})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusCanceled, canceled.Status)
+	assert.NotNil(t, canceled.CompletedAt)
+
+	_, err = svc.ProcessNextDeployment(ctx)
+	assert.ErrorIs(t, err, service.ErrNoWork)
+	assert.Zero(t, rt.called)
+
+	// Canceling again is a no-op.
+	again, err := svc.CancelDeployment(ctx, service.CancelDeploymentParams{DeploymentID: queued.ID})
+	require.NoError(t, err)
+	assert.Equal(t, canceled.Version, again.Version)
+}
+
+// TestCancelDeployment_InFlight verifies a cancel stops a running deploy without a failure being recorded.
+func TestCancelDeployment_InFlight(t *testing.T) {
+	tests := []struct {
+		label string
+		// otherProcess cancels through a separate service, so only the failed lease renewal stops the deploy
+		otherProcess bool
+	}{
+		{label: "same process"},
+		{label: "other process", otherProcess: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			ctx := context.Background()
+			st := store.NewMemoryStore()
+			rt := startedRuntime{started: make(chan struct{})}
+			// The same-process cancel must not wait for a renewal, so its lease outlives the test
+			lease := time.Hour
+			if tt.otherProcess {
+				lease = 30 * time.Millisecond
+			}
+			worker := service.NewAppServiceWithRuntime(st, rt, service.WithLease(lease))
+			api := worker
+			if tt.otherProcess {
+				api = service.NewAppService(st)
+			}
+
+			app, err := api.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+			require.NoError(t, err)
+			dep, err := api.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+			require.NoError(t, err)
+
+			type result struct {
+				dep domain.Deployment
+				err error
+			}
+			done := make(chan result, 1)
+			go func() {
+				d, err := worker.ProcessNextDeployment(ctx)
+				done <- result{d, err}
+			}()
+			<-rt.started
+
+			canceled, err := api.CancelDeployment(ctx, service.CancelDeploymentParams{DeploymentID: dep.ID})
+			require.NoError(t, err)
+			assert.Equal(t, domain.DeploymentStatusCanceled, canceled.Status)
+
+			select {
+			case res := <-done:
+				assert.NoError(t, res.err)
+				assert.Equal(t, domain.DeploymentStatusCanceled, res.dep.Status)
+				assert.Nil(t, res.dep.Error)
+			case <-time.After(5 * time.Second):
+				t.Fatal("deploy was not canceled")
+			}
+		})
+	}
+}
+
+// TestRetryDeployment verifies retries clone failed and canceled deployments only.
+func TestRetryDeployment(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &fakeRuntime{err: errors.New("pull failed")}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:1.26"})
+	require.NoError(t, err)
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	failed, _ := svc.ProcessNextDeployment(ctx)
+	require.Equal(t, domain.DeploymentStatusFailed, failed.Status)
+
+	// The retry deploys what the failed deployment tried to, not the edited app.
+	image := "nginx:1.27"
+	_, err = svc.UpdateApp(ctx, service.UpdateAppParams{AppID: app.ID, Image: &image})
+	require.NoError(t, err)
+	retry, err := svc.RetryDeployment(ctx, service.RetryDeploymentParams{DeploymentID: failed.ID})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusQueued, retry.Status)
+	assert.Equal(t, failed.ID, *retry.RetryOf)
+	assert.Equal(t, "nginx:1.26", retry.Spec.Image)
+
+	rt.err = nil
+	running, err := svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, retry.ID, running.ID)
+
+	queued, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	canceled, err := svc.CancelDeployment(ctx, service.CancelDeploymentParams{DeploymentID: queued.ID})
+	require.NoError(t, err)
+	_, err = svc.RetryDeployment(ctx, service.RetryDeploymentParams{DeploymentID: canceled.ID})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		label string
+		run   func() error
+		err   error
+	}{
+		{"retry running", func() error {
+			_, err := svc.RetryDeployment(ctx, service.RetryDeploymentParams{DeploymentID: running.ID})
+			return err
+		}, service.ErrConflict},
+		{"retry missing", func() error {
+			_, err := svc.RetryDeployment(ctx, service.RetryDeploymentParams{DeploymentID: "missing"})
+			return err
+		}, service.ErrNotFound},
+		{"retry empty id", func() error {
+			_, err := svc.RetryDeployment(ctx, service.RetryDeploymentParams{})
+			return err
+		}, service.ErrInvalidInput},
+		{"cancel running", func() error {
+			_, err := svc.CancelDeployment(ctx, service.CancelDeploymentParams{DeploymentID: running.ID})
+			return err
+		}, service.ErrConflict},
+		{"cancel stale version", func() error {
+			_, err := svc.CancelDeployment(ctx, service.CancelDeploymentParams{DeploymentID: failed.ID, IfVersion: 99})
+			return err
+		}, service.ErrVersionMismatch},
+		{"cancel missing", func() error {
+			_, err := svc.CancelDeployment(ctx, service.CancelDeploymentParams{DeploymentID: "missing"})
+			return err
+		}, service.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			assert.ErrorIs(t, tt.run(), tt.err)
+		})
+	}
+}
+
+// TestCancelDeployment_Switch verifies a cancel before the switch stops the deploy and one during it is refused.
+func TestCancelDeployment_Switch(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := switchRuntime{reached: make(chan string), resume: make(chan struct{})}
+	worker := service.NewAppServiceWithRuntime(st, rt, service.WithLease(time.Hour))
+	// Canceling through another service leaves only the recorded switch to stop the deploy
+	api := service.NewAppService(st)
+
+	app, err := api.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+
+	type result struct {
+		dep domain.Deployment
+		err error
+	}
+	process := func() <-chan result {
+		done := make(chan result, 1)
+		go func() {
+			d, err := worker.ProcessNextDeployment(ctx)
+			done <- result{d, err}
+		}()
+		return done
+	}
+
+	// Canceled before the switch: the deploy stops and stays CANCELED
+	first, err := api.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	done := process()
+	assert.Equal(t, "before", <-rt.reached)
+	_, err = api.CancelDeployment(ctx, service.CancelDeploymentParams{DeploymentID: first.ID})
+	require.NoError(t, err)
+	rt.resume <- struct{}{}
+	res := <-done
+	require.NoError(t, res.err)
+	assert.Equal(t, domain.DeploymentStatusCanceled, res.dep.Status)
+
+	// Canceled while switching: the cancel is refused and the deploy finishes RUNNING
+	second, err := api.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	done = process()
+	assert.Equal(t, "before", <-rt.reached)
+	rt.resume <- struct{}{}
+	assert.Equal(t, "during", <-rt.reached)
+	_, err = api.CancelDeployment(ctx, service.CancelDeploymentParams{DeploymentID: second.ID})
+	assert.ErrorIs(t, err, service.ErrConflict)
+	rt.resume <- struct{}{}
+	res = <-done
+	require.NoError(t, res.err)
+	assert.Equal(t, domain.DeploymentStatusRunning, res.dep.Status)
+}
@@ -0,0 +1,159 @@
+// Service logic for deleting apps
+// The app is marked DELETING first so it takes no new deployments, then unfinished ones are canceled
+// The delete waits for deploys in this process to stop and refuses while another worker may still be deploying
+// The runtime then tears down the app's containers and routing
+// Store records go last so a failed teardown can simply be retried
+// Deleting an app that is already gone succeeds
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// DeleteAppParams identifies the app to delete
+// IfVersion, when set, refuses the delete unless the app is still at that version
+type DeleteAppParams struct {
+	AppID     string
+	IfVersion int64
+}
+
+// DeleteApp marks an app DELETING, cancels its unfinished deployments, tears it down in the runtime and removes
+// its records. It returns ErrConflict while a worker in another process may still be deploying the app; the delete
+// can be retried once that worker has stopped. A teardown failure returns ErrTeardown and keeps the records so the
+// delete can be retried. The app stays DELETING, refusing new deployments, until a delete goes through.
+func (s *AppService) DeleteApp(ctx context.Context, p DeleteAppParams) error {
+	if p.AppID == "" {
+		return fmt.Errorf("%w: app id is required", ErrInvalidInput)
+	}
+	if s.runtime == nil {
+		return ErrNoRuntime
+	}
+
+	app, err := s.store.GetAppByID(ctx, p.AppID)
+	if errors.Is(err, contracts.ErrNotFound) {
+		// Already deleted
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if p.IfVersion != 0 && app.Version != p.IfVersion {
+		return fmt.Errorf("%w: app is at version %d", ErrVersionMismatch, app.Version)
+	}
+
+	// Deploys check the mark, so none queued or claimed from here on starts a container
+	if app.Status != domain.AppStatusDeleting {
+		app, err = s.setAppStatus(ctx, app.ID, domain.AppStatusDeleting)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.cancelUnfinished(ctx, app.ID); err != nil {
+		return err
+	}
+	if err := s.stopDeploys(ctx, app.ID); err != nil {
+		return err
+	}
+	if err := s.runtime.Remove(ctx, app); err != nil {
+		return fmt.Errorf("%w: %v", ErrTeardown, err)
+	}
+
+	err = s.store.DeleteApp(ctx, app.ID)
+	if errors.Is(err, contracts.ErrNotFound) {
+		// A concurrent delete finished first
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.events.publish(Event{Type: EventAppDeleted, AppID: app.ID, App: &app})
+	return nil
+}
+
+// cancelUnfinished cancels every queued or in-progress deployment of an app.
+// A worker still holding one finds its claim gone on its next write.
+// One that is already switching traffic is left to finish; stopDeploys deals with it.
+func (s *AppService) cancelUnfinished(ctx context.Context, appID string) error {
+	deps, _, err := s.store.ListDeploymentsByAppID(ctx, appID, contracts.DeploymentFilter{})
+	if err != nil {
+		return err
+	}
+	for _, dep := range deps {
+		_, err := s.cancelDeployment(ctx, dep)
+		if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, domain.ErrSwitching) {
+			return err
+		}
+	}
+	return nil
+}
+
+// stopDeploys waits for this process's deploys of an app to return, so none creates a container after the teardown.
+// It returns ErrConflict if a worker elsewhere may still be deploying the app: one still holds an in-progress
+// deployment, or held a canceled one under a lease that has not run out, since it only stops at its next heartbeat.
+func (s *AppService) stopDeploys(ctx context.Context, appID string) error {
+	deps, _, err := s.store.ListDeploymentsByAppID(ctx, appID, contracts.DeploymentFilter{})
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, dep := range deps {
+		if s.inflight.running(dep.ID) {
+			if err := s.inflight.wait(ctx, dep.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if dep.ClaimedBy == nil || *dep.ClaimedBy == s.workerID || dep.LeaseExpiresAt == nil {
+			continue
+		}
+		canceling := dep.Status == domain.DeploymentStatusCanceled && dep.LeaseExpiresAt.After(now)
+		if dep.Status.InProgress() || canceling {
+			return fmt.Errorf("%w: deployment %s may still be running on worker %s; retry after %s",
+				ErrConflict, dep.ID, *dep.ClaimedBy, dep.LeaseExpiresAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// cancelDeployment cancels dep unless it already finished, re-reading it when a worker moved it meanwhile.
+// It returns the deployment as last seen, which is unchanged when it had already finished.
+// A worker running the deployment in this process has its deploy context canceled too.
+func (s *AppService) cancelDeployment(ctx context.Context, dep domain.Deployment) (domain.Deployment, error) {
+	for attempt := 0; ; attempt++ {
+		if !domain.CanTransition(dep.Status, domain.DeploymentStatusCanceled) {
+			return dep, nil
+		}
+		if err := dep.Cancel(); err != nil {
+			return domain.Deployment{}, fmt.Errorf("%w: %w", ErrConflict, err)
+		}
+		canceled, err := s.store.UpdateDeployment(ctx, dep)
+		switch {
+		case err == nil:
+			s.inflight.cancel(canceled.ID)
+			s.deploymentChanged(ctx, canceled)
+			return canceled, nil
+		case errors.Is(err, contracts.ErrNotFound):
+			return domain.Deployment{}, ErrNotFound
+		case !errors.Is(err, contracts.ErrVersionMismatch):
+			return domain.Deployment{}, err
+		case attempt+1 >= maxUpdateRetries:
+			return domain.Deployment{}, ErrVersionMismatch
+		}
+		if dep, err = s.store.GetDeploymentByID(ctx, dep.ID); err != nil {
+			if errors.Is(err, contracts.ErrNotFound) {
+				return domain.Deployment{}, ErrNotFound
+			}
+			return domain.Deployment{}, err
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Tests for deleting apps
+// Tests verify unfinished deployments are canceled and later deploys refused before teardown
+// Tests verify the teardown waits for a deploy in this process and refuses while another worker may be deploying
+// Tests verify records are removed only after the runtime succeeds
+// Tests cover repeat deletes and stale versions
+// These tests keep deletes safe to retry
+
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// TestDeleteApp verifies delete input handling and idempotency.
+func TestDeleteApp(t *testing.T) {
+	tests := []struct {
+		label     string
+		appExists bool
+		appID     string
+		ifVersion int64
+		noRuntime bool
+		err       error
+	}{
+		{label: "invalid input: empty app id", appID: "", err: service.ErrInvalidInput},
+		{label: "no runtime", appExists: true, noRuntime: true, err: service.ErrNoRuntime},
+		{label: "ok: app already gone", appID: "missing"},
+		{label: "ok: deletes app", appExists: true},
+		{label: "ok: if version matches", appExists: true, ifVersion: 1},
+		{label: "version mismatch: stale if version", appExists: true, ifVersion: 7, err: service.ErrVersionMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			ctx := context.Background()
+			st := store.NewMemoryStore()
+			rt := &fakeRuntime{}
+			svc := service.NewAppServiceWithRuntime(st, rt)
+			if tt.noRuntime {
+				svc = service.NewAppService(st)
+			}
+
+			appID := tt.appID
+			if tt.appExists {
+				app, err := domain.NewApp(domain.NewAppParams{Name: "hello", Image: "nginx:latest"})
+				require.NoError(t, err)
+				require.NoError(t, st.CreateApp(ctx, app))
+				appID = app.ID
+			}
+
+			err := svc.DeleteApp(ctx, service.DeleteAppParams{AppID: appID, IfVersion: tt.ifVersion})
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				if tt.appExists {
+					_, gerr := st.GetAppByID(ctx, appID)
+					assert.NoError(t, gerr, "app must survive a refused delete")
+				}
+				return
+			}
+			assert.NoError(t, err)
+			_, gerr := st.GetAppByID(ctx, appID)
+			assert.ErrorIs(t, gerr, contracts.ErrNotFound)
+			if tt.appExists {
+				assert.Equal(t, []string{appID}, rt.removed)
+			}
+		})
+	}
+}
+
+// TestDeleteApp_CancelsAndCascades verifies queued work is canceled and history is removed with the app.
+func TestDeleteApp_CancelsAndCascades(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &fakeRuntime{}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	finished, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	_, err = svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+	queued, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.DeleteApp(ctx, service.DeleteAppParams{AppID: app.ID}))
+	assert.Equal(t, []string{app.ID}, rt.removed)
+
+	for _, id := range []string{finished.ID, queued.ID} {
+		_, err := st.GetDeploymentByID(ctx, id)
+		assert.ErrorIs(t, err, contracts.ErrNotFound)
+	}
+	_, err = svc.ProcessNextDeployment(ctx)
+	assert.ErrorIs(t, err, service.ErrNoWork)
+
+	// A second delete is a no-op.
+	assert.NoError(t, svc.DeleteApp(ctx, service.DeleteAppParams{AppID: app.ID}))
+	assert.Len(t, rt.removed, 1)
+}
+
+// TestDeleteApp_TeardownFailure verifies a failed teardown keeps the records so the delete can be retried.
+func TestDeleteApp_TeardownFailure(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &fakeRuntime{removeErr: errors.New("daemon unreachable")}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	queued, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+
+	err = svc.DeleteApp(ctx, service.DeleteAppParams{AppID: app.ID})
+	assert.ErrorIs(t, err, service.ErrTeardown)
+	assert.ErrorContains(t, err, "daemon unreachable")
+
+	// The app is still there and its queued deployment will not run.
+	_, err = st.GetAppByID(ctx, app.ID)
+	assert.NoError(t, err)
+	dep, err := st.GetDeploymentByID(ctx, queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusCanceled, dep.Status)
+
+	// Retrying once the runtime recovers finishes the job.
+	rt.removeErr = nil
+	require.NoError(t, svc.DeleteApp(ctx, service.DeleteAppParams{AppID: app.ID}))
+	_, err = st.GetAppByID(ctx, app.ID)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+}
+
+// teardownRuntime records the order its deploy returns and the app is removed in.
+type teardownRuntime struct {
+	noopLifecycle
+	started chan struct{}
+
+	mu     sync.Mutex
+	events []string
+}
+
+// Deploy waits for a cancel and takes a moment to clean up before returning.
+func (r *teardownRuntime) Deploy(ctx context.Context, app domain.App, _ contracts.DeployProgress) (*string, error) {
+	close(r.started)
+	<-ctx.Done()
+	time.Sleep(20 * time.Millisecond)
+	r.record("deploy returned")
+	return nil, ctx.Err()
+}
+
+// Remove records the teardown.
+func (r *teardownRuntime) Remove(ctx context.Context, app domain.App) error {
+	r.record("removed")
+	return nil
+}
+
+// record appends an event.
+func (r *teardownRuntime) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// TestDeleteApp_WaitsForDeploy verifies the teardown runs only once a deploy in this process has returned.
+func TestDeleteApp_WaitsForDeploy(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &teardownRuntime{started: make(chan struct{})}
+	svc := service.NewAppServiceWithRuntime(st, rt, service.WithLease(time.Hour))
+
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	go func() { _, _ = svc.ProcessNextDeployment(ctx) }()
+	<-rt.started
+
+	require.NoError(t, svc.DeleteApp(ctx, service.DeleteAppParams{AppID: app.ID}))
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	assert.Equal(t, []string{"deploy returned", "removed"}, rt.events)
+}
+
+// TestDeleteApp_RemoteWorker verifies the delete is refused until a worker elsewhere can no longer be deploying.
+func TestDeleteApp_RemoteWorker(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &fakeRuntime{}
+	svc := service.NewAppServiceWithRuntime(st, rt, service.WithLease(50*time.Millisecond))
+	claim := claimOne(t, svc)
+
+	err := svc.DeleteApp(ctx, service.DeleteAppParams{AppID: claim.App.ID})
+	assert.ErrorIs(t, err, service.ErrConflict)
+	assert.Empty(t, rt.removed)
+
+	// The deployment was canceled, so the worker stops at its next heartbeat
+	dep, err := st.GetDeploymentByID(ctx, claim.Deployment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusCanceled, dep.Status)
+	err = svc.HeartbeatDeployment(ctx, service.HeartbeatParams{DeploymentID: dep.ID, WorkerID: "worker-1"})
+	assert.ErrorIs(t, err, service.ErrLeaseLost)
+
+	// Once its lease has run out the delete goes through
+	assert.Eventually(t, func() bool {
+		return svc.DeleteApp(ctx, service.DeleteAppParams{AppID: claim.App.ID}) == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{claim.App.ID}, rt.removed)
+}
+
+// TestDeleteApp_DeployDuringDelete verifies a deploy arriving between the cancel and the teardown never reaches the runtime.
+func TestDeleteApp_DeployDuringDelete(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &fakeRuntime{}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+
+	var late domain.Deployment
+	rt.beforeRemove = func() {
+		// A new deploy is refused once the delete has begun
+		_, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+		assert.ErrorIs(t, err, service.ErrConflict)
+
+		// One that passed its check before the delete began is queued anyway, and a worker claims it
+		late = domain.NewDeployment(app.ID)
+		late.Spec = domain.SnapshotSpec(app)
+		require.NoError(t, st.CreateDeployment(ctx, late))
+		dep, err := svc.ProcessNextDeployment(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DeploymentStatusCanceled, dep.Status)
+	}
+
+	require.NoError(t, svc.DeleteApp(ctx, service.DeleteAppParams{AppID: app.ID}))
+	assert.NotEmpty(t, late.ID)
+	assert.Zero(t, rt.called)
+	assert.Equal(t, []string{app.ID}, rt.removed)
+	_, err = st.GetAppByID(ctx, app.ID)
+	assert.ErrorIs(t, err, contracts.ErrNotFound)
+}
@@ -10,39 +10,87 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/t0gun/spacescale/internal/contracts"
 	"github.com/t0gun/spacescale/internal/domain"
 )
 
 // DeployAppParams contains the input needed to request a deployment
+// IfVersion, when set, refuses the deploy unless the app is still at that version
+// Force allows deploying a paused app, which ends the pause
 type DeployAppParams struct {
-	AppID string
+	AppID     string
+	IfVersion int64
+	Force     bool
 }
 
 // ListDeploymentsParams identifies which app to list deployments for
+// Status narrows the list and Page/PageSize select a window of it
 type ListDeploymentsParams struct {
-	AppID string
+	AppID    string
+	Status   domain.DeploymentStatus
+	Page     int // 1-based, 0 means the first page
+	PageSize int // 0 means DefaultPageSize
+}
+
+// DeploymentList is one page of deployments and the total number of matches
+type DeploymentList struct {
+	Deployments []domain.Deployment
+	Total       int
+	Page        int
+	PageSize    int
+}
+
+// DeploymentDetail is a deployment together with its app
+// QueuePosition is 1 for the next deployment a worker will claim and 0 once it has left the queue
+type DeploymentDetail struct {
+	Deployment    domain.Deployment
+	App           domain.App
+	QueuePosition int
 }
 
 // DeployApp creates a queued deployment for an app.
 func (s *AppService) DeployApp(ctx context.Context, p DeployAppParams) (domain.Deployment, error) {
-	if p.AppID == "" {
-		return domain.Deployment{}, fmt.Errorf("%w: app id is required", ErrInvalidInput)
+	app, err := s.deployableApp(ctx, p.AppID, p.IfVersion, p.Force)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+
+	// Create a queued deployment record that runs the app as it is right now
+	dep := domain.NewDeployment(p.AppID)
+	dep.Spec = domain.SnapshotSpec(app)
+	return s.enqueue(ctx, dep)
+}
+
+// deployableApp loads an app that may take a new deployment.
+// A paused app only takes one when forced, and an app being deleted takes none.
+func (s *AppService) deployableApp(ctx context.Context, appID string, ifVersion int64, force bool) (domain.App, error) {
+	if appID == "" {
+		return domain.App{}, fmt.Errorf("%w: app id is required", ErrInvalidInput)
 	}
 
 	// Ensure the app exists before creating a deployment record
-	_, err := s.store.GetAppByID(ctx, p.AppID)
+	app, err := s.store.GetAppByID(ctx, appID)
 	if err != nil {
 		if errors.Is(err, contracts.ErrNotFound) {
-			return domain.Deployment{}, ErrNotFound
+			return domain.App{}, ErrNotFound
 		}
-		return domain.Deployment{}, err
+		return domain.App{}, err
+	}
+	if ifVersion != 0 && app.Version != ifVersion {
+		return domain.App{}, fmt.Errorf("%w: app is at version %d", ErrVersionMismatch, app.Version)
+	}
+	if app.Status == domain.AppStatusDeleting {
+		return domain.App{}, fmt.Errorf("%w: app is being deleted", ErrConflict)
+	}
+	if app.Status == domain.AppStatusPaused && !force {
+		return domain.App{}, fmt.Errorf("%w: app is paused; resume it or force the deploy", ErrConflict)
 	}
+	return app, nil
+}
 
-	// Create a queued deployment record
-	dep := domain.NewDeployment(p.AppID)
+// enqueue stores a new queued deployment and mirrors it onto its app.
+func (s *AppService) enqueue(ctx context.Context, dep domain.Deployment) (domain.Deployment, error) {
 	if err := s.store.CreateDeployment(ctx, dep); err != nil {
 		if errors.Is(err, contracts.ErrNotFound) {
 			// Store enforces that the app must exist
@@ -50,6 +98,7 @@ func (s *AppService) DeployApp(ctx context.Context, p DeployAppParams) (domain.D
 		}
 		return domain.Deployment{}, err
 	}
+	s.deploymentChanged(ctx, dep)
 	return dep, nil
 }
 
@@ -58,74 +107,238 @@ func (s *AppService) ProcessNextDeployment(ctx context.Context) (domain.Deployme
 	if s.runtime == nil {
 		return domain.Deployment{}, ErrNoRuntime
 	}
+	dep, app, err := s.claimNext(ctx, s.workerID)
+	if err != nil {
+		return dep, err
+	}
+	if !dep.Status.InProgress() {
+		// It ended before reaching the runtime
+		return dep, nil
+	}
 
-	// Grab the next queued deployment in FIFO order
-	dep, err := s.store.TakeNextQueuedDeployment(ctx)
+	// Keep the claim alive while the runtime works; losing it or a cancel stops the deploy
+	deployCtx, cancelDeploy := context.WithCancel(ctx)
+	defer cancelDeploy()
+	defer s.inflight.track(dep.ID, cancelDeploy)()
+	if current, err := s.store.GetDeploymentByID(ctx, dep.ID); err != nil || current.Status == domain.DeploymentStatusCanceled {
+		// An app delete canceled it before it was tracked, so the delete did not wait for it
+		return s.claimGone(ctx, dep)
+	}
+	lease := s.keepLease(ctx, dep.ID, cancelDeploy)
+
+	// Run the runtime deploy and capture a URL or an error; its step reports and build log are stored as they come
+	steps := s.newStepRecorder(ctx, &dep, cancelDeploy)
+	url, err := s.runtime.Deploy(deployCtx, app, steps)
+	steps.flush()
+	if lease.Stop() {
+		// Another worker may own the deployment now, so its record is not ours to write
+		return s.claimGone(ctx, dep)
+	}
+	if err != nil && deployCtx.Err() != nil && ctx.Err() == nil {
+		// The deploy was stopped from here by a cancel, or because its switch could not be recorded
+		return s.claimGone(ctx, dep)
+	}
+	if err != nil && ctx.Err() != nil {
+		// The caller stopped waiting, so the deploy did not fail on its own merits
+		return s.requeueInterrupted(ctx, dep, err)
+	}
+	if err != nil {
+		return s.recordFailure(ctx, dep, err)
+	}
+
+	// Mark deployment as running with the resolved URL
+	if err := dep.Succeed(url); err != nil {
+		return domain.Deployment{}, fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	running, err := s.updateClaimed(ctx, dep)
+	if errors.Is(err, ErrLeaseLost) {
+		return s.claimGone(ctx, dep)
+	}
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	dep = running
+	s.deploymentChanged(ctx, dep)
+
+	return dep, nil
+
+}
+
+// claimNext claims the next queued deployment for workerID and moves it to DEPLOYING.
+// It returns the app with the deployment's spec applied, ready for the runtime.
+// A deployment that fails before that is returned FAILED along with the reason.
+// One that gave up after too many attempts, or whose app is being deleted, is returned FAILED or CANCELED
+// with an empty app and no error, since ending it is the claim's outcome rather than a fault.
+func (s *AppService) claimNext(ctx context.Context, workerID string) (domain.Deployment, domain.App, error) {
+	// Claim the next queued deployment in FIFO order under this worker's lease
+	dep, err := s.store.TakeNextQueuedDeployment(ctx, workerID, s.lease)
 	if err != nil {
 		if errors.Is(err, contracts.ErrNotFound) {
-			return domain.Deployment{}, ErrNoWork
+			return domain.Deployment{}, domain.App{}, ErrNoWork
 		}
-		return domain.Deployment{}, err
+		return domain.Deployment{}, domain.App{}, err
+	}
+
+	// A deployment that keeps getting reclaimed is crashing its workers; stop retrying it
+	if dep.Attempts > s.maxAttempts {
+		msg := fmt.Sprintf("deployment gave up after %d attempts", dep.Attempts-1)
+		failed, err := s.failDeployment(ctx, dep, msg)
+		if err != nil {
+			return domain.Deployment{}, domain.App{}, err
+		}
+		return failed, domain.App{}, nil
 	}
 
 	// Mark deployment as building before interacting with the runtime
-	dep.Status = domain.DeploymentStatusBuilding
-	dep.UpdatedAt = time.Now()
-	if err := s.store.UpdateDeployment(ctx, dep); err != nil {
-		return domain.Deployment{}, err
+	if err := dep.Start(); err != nil {
+		return domain.Deployment{}, domain.App{}, fmt.Errorf("%w: %v", ErrConflict, err)
 	}
+	dep, err = s.updateClaimed(ctx, dep)
+	if err != nil {
+		return domain.Deployment{}, domain.App{}, err
+	}
+	s.deploymentChanged(ctx, dep)
 
-	// Load app data needed for the runtime deployment
+	// Load the app for its identity; what gets deployed comes from the frozen spec
 	app, err := s.store.GetAppByID(ctx, dep.AppID)
 	if err != nil {
-		msg := err.Error()
-		dep.Status = domain.DeploymentStatusFailed
-		dep.Error = &msg
-		dep.UpdatedAt = time.Now().UTC()
-		_ = s.store.UpdateDeployment(ctx, dep)
-		return dep, fmt.Errorf("runtime deploy failed: %w", err)
+		failed, err := s.recordFailure(ctx, dep, err)
+		return failed, domain.App{}, err
 	}
 
-	// Run the runtime deploy and capture a URL or an error
-	url, err := s.runtime.Deploy(ctx, app)
+	// An app being deleted takes no new deployments; its delete tears down whatever is left
+	if app.Status == domain.AppStatusDeleting {
+		if err := dep.Cancel(); err != nil {
+			return domain.Deployment{}, domain.App{}, fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		canceled, err := s.updateClaimed(ctx, dep)
+		if err != nil {
+			return domain.Deployment{}, domain.App{}, err
+		}
+		s.deploymentChanged(ctx, canceled)
+		return canceled, domain.App{}, nil
+	}
+
+	// Hand over to the runtime
+	if err := dep.MarkDeploying(); err != nil {
+		return domain.Deployment{}, domain.App{}, fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	dep, err = s.updateClaimed(ctx, dep)
 	if err != nil {
-		msg := err.Error()
-		dep.Status = domain.DeploymentStatusFailed
-		dep.Error = &msg
-		dep.UpdatedAt = time.Now().UTC()
-		_ = s.store.UpdateDeployment(ctx, dep)
-		return dep, fmt.Errorf("runtime deploy failed: %w", err)
+		return domain.Deployment{}, domain.App{}, err
 	}
+	s.deploymentChanged(ctx, dep)
+	return dep, dep.Spec.Apply(app), nil
+}
 
-	// Mark deployment as running with the resolved URL
-	dep.Status = domain.DeploymentStatusRunning
-	dep.URL = url
-	dep.Error = nil
-	dep.UpdatedAt = time.Now().UTC()
-	if err := s.store.UpdateDeployment(ctx, dep); err != nil {
-		return domain.Deployment{}, err
+// claimGone reports a deployment whose claim ended while this worker ran it.
+// A cancel is a normal outcome and returns the canceled record; anything else is ErrLeaseLost.
+func (s *AppService) claimGone(ctx context.Context, dep domain.Deployment) (domain.Deployment, error) {
+	current, err := s.store.GetDeploymentByID(ctx, dep.ID)
+	if err == nil && current.Status == domain.DeploymentStatusCanceled {
+		return current, nil
 	}
+	return dep, ErrLeaseLost
+}
 
-	return dep, nil
+// requeueInterrupted hands a claimed deployment whose deploy was interrupted back to the queue.
+// If that fails the claim still runs out and the reaper requeues it later.
+func (s *AppService) requeueInterrupted(ctx context.Context, dep domain.Deployment, cause error) (domain.Deployment, error) {
+	// ctx is already done, so the store writes get their own deadline
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), requeueTimeout)
+	defer cancel()
+	requeued, err := s.requeueClaimed(ctx, dep)
+	if err != nil {
+		return dep, fmt.Errorf("deploy interrupted: %w; requeue: %v", cause, err)
+	}
+	return requeued, fmt.Errorf("deploy interrupted and requeued: %w", cause)
+}
+
+// requeueClaimed puts a claimed deployment back in the queue and returns the stored record.
+func (s *AppService) requeueClaimed(ctx context.Context, dep domain.Deployment) (domain.Deployment, error) {
+	requeued := dep
+	if err := requeued.Requeue(); err != nil {
+		return dep, fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	requeued, err := s.store.RequeueDeployment(ctx, requeued)
+	if err != nil {
+		return dep, err
+	}
+	s.deploymentChanged(ctx, requeued)
+	return requeued, nil
+}
 
+// recordFailure stores cause as the reason a claimed deployment failed and returns it wrapped.
+// The stored record is returned when the write succeeds so callers see the final state.
+func (s *AppService) recordFailure(ctx context.Context, dep domain.Deployment, cause error) (domain.Deployment, error) {
+	if err := dep.Fail(cause.Error()); err == nil {
+		if updated, uerr := s.updateClaimed(ctx, dep); uerr == nil {
+			dep = updated
+			s.deploymentChanged(ctx, dep)
+		}
+	}
+	return dep, fmt.Errorf("runtime deploy failed: %w", cause)
 }
 
-// ListDeployments returns deployments for an app.
-func (s *AppService) ListDeployments(ctx context.Context, p ListDeploymentsParams) ([]domain.Deployment, error) {
+// updateClaimed writes a deployment this worker claimed.
+// A version mismatch means the reaper or another worker changed it after our claim, so the claim is gone.
+func (s *AppService) updateClaimed(ctx context.Context, dep domain.Deployment) (domain.Deployment, error) {
+	updated, err := s.store.UpdateDeployment(ctx, dep)
+	if errors.Is(err, contracts.ErrVersionMismatch) {
+		return domain.Deployment{}, ErrLeaseLost
+	}
+	return updated, err
+}
+
+// GetDeploymentByID returns a deployment with its app and, while it is QUEUED, its place in the queue.
+func (s *AppService) GetDeploymentByID(ctx context.Context, id string) (DeploymentDetail, error) {
+	dep, err := s.getDeployment(ctx, id)
+	if err != nil {
+		return DeploymentDetail{}, err
+	}
+	app, err := s.store.GetAppByID(ctx, dep.AppID)
+	if err != nil {
+		if errors.Is(err, contracts.ErrNotFound) {
+			// The app was deleted after the deployment was read
+			return DeploymentDetail{}, ErrNotFound
+		}
+		return DeploymentDetail{}, err
+	}
+	detail := DeploymentDetail{Deployment: dep, App: app}
+	if dep.Status == domain.DeploymentStatusQueued {
+		if detail.QueuePosition, err = s.store.QueuePosition(ctx, dep.ID); err != nil {
+			return DeploymentDetail{}, err
+		}
+	}
+	return detail, nil
+}
+
+// ListDeployments returns one page of an app's deployments in create order.
+func (s *AppService) ListDeployments(ctx context.Context, p ListDeploymentsParams) (DeploymentList, error) {
 	if p.AppID == "" {
-		return nil, ErrInvalidInput
+		return DeploymentList{}, ErrInvalidInput
+	}
+	if p.Status != "" && !p.Status.Valid() {
+		return DeploymentList{}, fmt.Errorf("%w: unknown status %q", ErrInvalidInput, p.Status)
+	}
+	window, page, pageSize, err := resolvePage(p.Page, p.PageSize)
+	if err != nil {
+		return DeploymentList{}, err
 	}
 
 	// Ensure the app exists so missing apps return a not found error
 	if _, err := s.store.GetAppByID(ctx, p.AppID); err != nil {
 		if errors.Is(err, contracts.ErrNotFound) {
-			return nil, ErrNotFound
+			return DeploymentList{}, ErrNotFound
 		}
-		return nil, err
+		return DeploymentList{}, err
 	}
-	deps, err := s.store.ListDeploymentsByAppID(ctx, p.AppID)
+	deps, total, err := s.store.ListDeploymentsByAppID(ctx, p.AppID, contracts.DeploymentFilter{
+		Status: p.Status,
+		Page:   window,
+	})
 	if err != nil {
-		return nil, err
+		return DeploymentList{}, err
 	}
-	return deps, nil
+	return DeploymentList{Deployments: deps, Total: total, Page: page, PageSize: pageSize}, nil
 }
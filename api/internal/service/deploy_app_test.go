@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/t0gun/spacescale/internal/adapters/store"
 	"github.com/t0gun/spacescale/internal/contracts"
 	"github.com/t0gun/spacescale/internal/domain"
@@ -25,12 +26,15 @@ func TestDeployApp(t *testing.T) {
 		label     string
 		appExists bool
 		appID     string
+		ifVersion int64
 		ok        bool
 		err       error
 	}{
 		{label: "invalid input: empty app id", appExists: false, appID: "", ok: false, err: service.ErrInvalidInput},
 		{label: "not found: app missing", appExists: false, appID: "missing", ok: false, err: service.ErrNotFound},
 		{label: "ok: queues deployment", appExists: true, appID: "", ok: true}, // we will create an app use its ID
+		{label: "ok: if version matches", appExists: true, ifVersion: 1, ok: true},
+		{label: "version mismatch: stale if version", appExists: true, ifVersion: 7, ok: false, err: service.ErrVersionMismatch},
 	}
 
 	for _, tt := range tests {
@@ -50,7 +54,7 @@ func TestDeployApp(t *testing.T) {
 				assert.NoError(t, st.CreateApp(ctx, app))
 				appID = app.ID
 			}
-			dep, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: appID})
+			dep, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: appID, IfVersion: tt.ifVersion})
 			if tt.ok {
 				assert.NoError(t, err)
 				assert.NotEmpty(t, dep.ID)
@@ -76,6 +80,7 @@ type storeWithHooks struct {
 	getAppErr    error
 	createDepErr error
 	listDepsErr  error
+	renewErr     error
 }
 
 // GetAppByID returns an app or a configured error.
@@ -95,11 +100,19 @@ func (s storeWithHooks) CreateDeployment(ctx context.Context, dep domain.Deploym
 }
 
 // ListDeploymentsByAppID returns deployments or a configured error.
-func (s storeWithHooks) ListDeploymentsByAppID(ctx context.Context, appID string) ([]domain.Deployment, error) {
+func (s storeWithHooks) ListDeploymentsByAppID(ctx context.Context, appID string, f contracts.DeploymentFilter) ([]domain.Deployment, int, error) {
 	if s.listDepsErr != nil {
-		return nil, s.listDepsErr
+		return nil, 0, s.listDepsErr
 	}
-	return s.Store.ListDeploymentsByAppID(ctx, appID)
+	return s.Store.ListDeploymentsByAppID(ctx, appID, f)
+}
+
+// RenewDeploymentLease renews a lease or returns a configured error.
+func (s storeWithHooks) RenewDeploymentLease(ctx context.Context, id, workerID string, lease time.Duration) error {
+	if s.renewErr != nil {
+		return s.renewErr
+	}
+	return s.Store.RenewDeploymentLease(ctx, id, workerID, lease)
 }
 
 // TestDeployApp_StoreErrors verifies store error handling.
@@ -146,20 +159,161 @@ type fakeRuntime struct {
 	url    *string
 	err    error
 	called int
+
+	removeErr error
+	removed   []string
+	// beforeRemove runs at the start of Remove when set
+	beforeRemove func()
+
+	// state is the simulated workload; empty means nothing is deployed
+	state        domain.RuntimeState
+	restarts     int
+	lifecycleErr error
+	paused       []domain.App
+
+	// logs is the workload's output; logQuery is the last query Logs was asked
+	logs     []domain.LogEntry
+	logQuery contracts.LogQuery
+	// build is logged to the deployment's build log on every deploy
+	build []domain.LogEntry
 }
 
 // Deploy tracks calls and returns configured results.
-func (f *fakeRuntime) Deploy(ctx context.Context, app domain.App) (*string, error) {
+func (f *fakeRuntime) Deploy(ctx context.Context, app domain.App, progress contracts.DeployProgress) (*string, error) {
 	f.called++
+	for _, line := range f.build {
+		progress.Log(line)
+	}
 	if f.err != nil {
 		return nil, f.err
 	}
+	f.state = domain.RuntimeStateRunning
 	if !app.Expose {
 		return nil, nil
 	}
 	return f.url, nil
 }
 
+// Remove records the app id and returns the configured error.
+func (f *fakeRuntime) Remove(ctx context.Context, app domain.App) error {
+	if f.beforeRemove != nil {
+		f.beforeRemove()
+	}
+	if f.removeErr != nil {
+		return f.removeErr
+	}
+	f.removed = append(f.removed, app.ID)
+	f.state = ""
+	return nil
+}
+
+// Stop marks the workload exited.
+func (f *fakeRuntime) Stop(ctx context.Context, app domain.App) error {
+	return f.move(domain.RuntimeStateExited)
+}
+
+// Start marks the workload running.
+func (f *fakeRuntime) Start(ctx context.Context, app domain.App) error {
+	return f.move(domain.RuntimeStateRunning)
+}
+
+// Restart marks the workload running and counts the restart.
+func (f *fakeRuntime) Restart(ctx context.Context, app domain.App) error {
+	if err := f.move(domain.RuntimeStateRunning); err != nil {
+		return err
+	}
+	f.restarts++
+	return nil
+}
+
+// Pause marks the workload exited and records the app it was given.
+func (f *fakeRuntime) Pause(ctx context.Context, app domain.App) error {
+	if err := f.move(domain.RuntimeStateExited); err != nil {
+		return err
+	}
+	f.paused = append(f.paused, app)
+	return nil
+}
+
+// Resume marks the workload running.
+func (f *fakeRuntime) Resume(ctx context.Context, app domain.App) error {
+	return f.move(domain.RuntimeStateRunning)
+}
+
+// Status reports the simulated workload.
+func (f *fakeRuntime) Status(ctx context.Context, app domain.App) (domain.RuntimeStatus, error) {
+	if f.state == "" {
+		return domain.RuntimeStatus{}, contracts.ErrNotFound
+	}
+	return domain.RuntimeStatus{State: f.state, RestartCount: f.restarts}, nil
+}
+
+// move changes the simulated state of a deployed workload.
+func (f *fakeRuntime) move(to domain.RuntimeState) error {
+	if f.lifecycleErr != nil {
+		return f.lifecycleErr
+	}
+	if f.state == "" {
+		return contracts.ErrNotFound
+	}
+	f.state = to
+	return nil
+}
+
+// noopLifecycle gives single-purpose runtime fakes the lifecycle methods they do not exercise.
+type noopLifecycle struct{}
+
+// Stop does nothing.
+func (noopLifecycle) Stop(ctx context.Context, app domain.App) error { return nil }
+
+// Start does nothing.
+func (noopLifecycle) Start(ctx context.Context, app domain.App) error { return nil }
+
+// Restart does nothing.
+func (noopLifecycle) Restart(ctx context.Context, app domain.App) error { return nil }
+
+// Pause does nothing.
+func (noopLifecycle) Pause(ctx context.Context, app domain.App) error { return nil }
+
+// Resume does nothing.
+func (noopLifecycle) Resume(ctx context.Context, app domain.App) error { return nil }
+
+// Remove does nothing.
+func (noopLifecycle) Remove(ctx context.Context, app domain.App) error { return nil }
+
+// Logs reads no lines.
+func (noopLifecycle) Logs(ctx context.Context, app domain.App, q contracts.LogQuery, emit func(domain.LogEntry) error) error {
+	return nil
+}
+
+// Status reports a running workload.
+func (noopLifecycle) Status(ctx context.Context, app domain.App) (domain.RuntimeStatus, error) {
+	return domain.RuntimeStatus{State: domain.RuntimeStateRunning}, nil
+}
+
+// Logs emits the configured lines that match q; following ends once they run out.
+func (f *fakeRuntime) Logs(ctx context.Context, app domain.App, q contracts.LogQuery, emit func(domain.LogEntry) error) error {
+	f.logQuery = q
+	if f.state == "" {
+		return contracts.ErrNotFound
+	}
+	lines := make([]domain.LogEntry, 0, len(f.logs))
+	for _, e := range f.logs {
+		if q.Since == nil || !e.Timestamp.Before(*q.Since) {
+			lines = append(lines, e)
+		}
+	}
+	if q.Tail > 0 && q.Tail < len(lines) {
+		lines = lines[len(lines)-q.Tail:]
+	}
+	for _, e := range lines {
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var _ contracts.Runtime = (*fakeRuntime)(nil)
 
 // TestProcessNextDeployment verifies runtime processing behavior.
@@ -204,6 +358,7 @@ func TestProcessNextDeployment(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NoError(t, st.CreateApp(ctx, app))
 		dep := domain.NewDeployment(app.ID)
+		dep.Spec = domain.SnapshotSpec(app)
 		assert.NoError(t, st.CreateDeployment(ctx, dep))
 
 		got, err := svc.ProcessNextDeployment(ctx)
@@ -238,6 +393,220 @@ func TestProcessNextDeployment(t *testing.T) {
 	})
 }
 
+// blockingRuntime waits for its context to end before returning.
+type blockingRuntime struct{ noopLifecycle }
+
+// Deploy blocks until ctx is canceled.
+func (blockingRuntime) Deploy(ctx context.Context, app domain.App, _ contracts.DeployProgress) (*string, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestProcessNextDeployment_LeaseLost verifies a lost claim cancels the deploy and leaves the record alone.
+func TestProcessNextDeployment_LeaseLost(t *testing.T) {
+	ctx := context.Background()
+	mem := store.NewMemoryStore()
+	st := storeWithHooks{Store: mem, renewErr: contracts.ErrLeaseLost}
+	svc := service.NewAppServiceWithRuntime(st, blockingRuntime{}, service.WithWorkerID("worker-1"), service.WithLease(30*time.Millisecond))
+
+	app, err := domain.NewApp(domain.NewAppParams{Name: "hello", Image: "nginx:latest"})
+	assert.NoError(t, err)
+	assert.NoError(t, mem.CreateApp(ctx, app))
+	dep := domain.NewDeployment(app.ID)
+	assert.NoError(t, mem.CreateDeployment(ctx, dep))
+
+	_, err = svc.ProcessNextDeployment(ctx)
+	assert.ErrorIs(t, err, service.ErrLeaseLost)
+
+	got, err := mem.GetDeploymentByID(ctx, dep.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusDeploying, got.Status)
+	assert.Nil(t, got.Error)
+}
+
+// recordingRuntime remembers the app it was asked to deploy.
+type recordingRuntime struct {
+	noopLifecycle
+	got domain.App
+}
+
+// Deploy records app and succeeds without a URL.
+func (r *recordingRuntime) Deploy(ctx context.Context, app domain.App, _ contracts.DeployProgress) (*string, error) {
+	r.got = app
+	return nil, nil
+}
+
+// TestProcessNextDeployment_DeploysSpec verifies edits made while queued do not change what is deployed.
+func TestProcessNextDeployment_DeploysSpec(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &recordingRuntime{}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{
+		Name:        "hello",
+		Image:       "nginx:1.26",
+		Port:        ptrInt(8080),
+		Env:         map[string]string{"MODE": "blue"},
+		HealthCheck: &domain.HealthCheck{Type: domain.HealthCheckTCP},
+	})
+	assert.NoError(t, err)
+	queued, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, "nginx:1.26", queued.Spec.Image)
+	assert.Equal(t, domain.HashEnv(app.Env), queued.Spec.EnvHash)
+
+	// Edit the app while the deployment waits in the queue.
+	edited, err := st.GetAppByID(ctx, app.ID)
+	assert.NoError(t, err)
+	edited.Image = "nginx:1.27"
+	edited.Port = ptrInt(9090)
+	edited.Env = map[string]string{"MODE": "green"}
+	edited.HealthCheck = nil
+	_, err = st.UpdateApp(ctx, edited)
+	assert.NoError(t, err)
+
+	done, err := svc.ProcessNextDeployment(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, app.ID, rt.got.ID)
+	assert.Equal(t, "nginx:1.26", rt.got.Image)
+	assert.Equal(t, 8080, *rt.got.Port)
+	assert.Equal(t, map[string]string{"MODE": "blue"}, rt.got.Env)
+	assert.Equal(t, domain.HealthCheckTCP, rt.got.HealthCheck.Type)
+	assert.Equal(t, queued.Spec, done.Spec)
+}
+
+// steppingRuntime reports the first steps, logs the pull, resolves port 80 and fails its health check.
+type steppingRuntime struct {
+	noopLifecycle
+	st       contracts.Store
+	depID    string
+	seen     []domain.DeploymentStep // the stored steps while the health check ran
+	seenLogs []domain.LogEntry       // the stored build log while the health check ran
+	seenPort *int                    // the stored runtime port while the health check ran
+}
+
+// Deploy reports each step up to a failing health check.
+func (r *steppingRuntime) Deploy(ctx context.Context, app domain.App, progress contracts.DeployProgress) (*string, error) {
+	for _, step := range []domain.StepID{domain.StepPullImage, domain.StepResolvePort, domain.StepStartContainer} {
+		progress.StepStarted(step)
+		if step == domain.StepPullImage {
+			progress.Log(domain.LogEntry{Timestamp: time.Now().UTC(), Level: domain.LogLevelInfo, Message: "Pulling from library/nginx", Source: string(step)})
+		}
+		if step == domain.StepResolvePort {
+			progress.PortResolved(80)
+		}
+		progress.StepFinished(step, nil)
+	}
+	progress.StepStarted(domain.StepHealthCheck)
+	if dep, err := r.st.GetDeploymentByID(ctx, r.depID); err == nil {
+		r.seen = dep.Steps
+		r.seenPort = dep.RuntimePort
+	}
+	r.seenLogs, _ = r.st.ListDeploymentLogs(ctx, r.depID, contracts.Page{})
+	err := errors.New("container unhealthy")
+	progress.Log(domain.LogEntry{Timestamp: time.Now().UTC(), Level: domain.LogLevelError, Message: err.Error(), Source: string(domain.StepHealthCheck)})
+	progress.StepFinished(domain.StepHealthCheck, err)
+	return nil, err
+}
+
+// TestProcessNextDeployment_Steps verifies step reports and the resolved port are stored as they come and kept on failure.
+func TestProcessNextDeployment_Steps(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	app, err := domain.NewApp(domain.NewAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	require.NoError(t, st.CreateApp(ctx, app))
+	dep := domain.NewDeployment(app.ID)
+	require.NoError(t, st.CreateDeployment(ctx, dep))
+
+	rt := &steppingRuntime{st: st, depID: dep.ID}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+	_, err = svc.ProcessNextDeployment(ctx)
+	assert.ErrorContains(t, err, "container unhealthy")
+
+	// Readers saw the health check running before the deploy finished
+	require.Len(t, rt.seen, 5)
+	assert.Equal(t, domain.StepStatusCompleted, rt.seen[2].Status)
+	assert.Equal(t, domain.StepStatusRunning, rt.seen[3].Status)
+	if assert.Len(t, rt.seenLogs, 1) {
+		assert.Equal(t, "Pulling from library/nginx", rt.seenLogs[0].Message)
+	}
+	// The port the image supplied was recorded once it was resolved
+	if assert.NotNil(t, rt.seenPort) {
+		assert.Equal(t, 80, *rt.seenPort)
+	}
+
+	got, err := st.GetDeploymentByID(ctx, dep.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusFailed, got.Status)
+	require.Len(t, got.Steps, 5)
+	for _, s := range got.Steps[:3] {
+		assert.Equal(t, domain.StepStatusCompleted, s.Status, s.ID)
+		assert.NotNil(t, s.CompletedAt)
+	}
+	failed := got.Steps[3]
+	assert.Equal(t, domain.StepHealthCheck, failed.ID)
+	assert.Equal(t, domain.StepStatusFailed, failed.Status)
+	if assert.NotNil(t, failed.Error) {
+		assert.Equal(t, "container unhealthy", *failed.Error)
+	}
+	assert.Equal(t, domain.StepStatusSkipped, got.Steps[4].Status)
+
+	// The failed deployment's build log keeps what the runtime logged up to the failure
+	page, err := svc.DeploymentLogs(ctx, dep.ID, service.LogsParams{})
+	require.NoError(t, err)
+	if assert.Len(t, page.Entries, 2) {
+		assert.Equal(t, string(domain.StepPullImage), page.Entries[0].Source)
+		assert.Equal(t, domain.LogLevelError, page.Entries[1].Level)
+		assert.Equal(t, "container unhealthy", page.Entries[1].Message)
+	}
+}
+
+// requeueingRuntime simulates the reaper requeueing the deployment while the deploy runs.
+type requeueingRuntime struct {
+	noopLifecycle
+	st    contracts.Store
+	depID string
+}
+
+// Deploy requeues the deployment behind the worker's back and then succeeds.
+func (r requeueingRuntime) Deploy(ctx context.Context, app domain.App, _ contracts.DeployProgress) (*string, error) {
+	dep, err := r.st.GetDeploymentByID(ctx, r.depID)
+	if err != nil {
+		return nil, err
+	}
+	if err := dep.Requeue(); err != nil {
+		return nil, err
+	}
+	if _, err := r.st.RequeueDeployment(ctx, dep); err != nil {
+		return nil, err
+	}
+	url := "https://hello.example.com"
+	return &url, nil
+}
+
+// TestProcessNextDeployment_StaleWrite verifies a worker never overwrites a record changed under it.
+func TestProcessNextDeployment_StaleWrite(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+
+	app, err := domain.NewApp(domain.NewAppParams{Name: "hello", Image: "nginx:latest"})
+	assert.NoError(t, err)
+	assert.NoError(t, st.CreateApp(ctx, app))
+	dep := domain.NewDeployment(app.ID)
+	assert.NoError(t, st.CreateDeployment(ctx, dep))
+
+	svc := service.NewAppServiceWithRuntime(st, requeueingRuntime{st: st, depID: dep.ID})
+	_, err = svc.ProcessNextDeployment(ctx)
+	assert.ErrorIs(t, err, service.ErrLeaseLost)
+
+	got, err := st.GetDeploymentByID(ctx, dep.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusQueued, got.Status)
+	assert.Nil(t, got.URL)
+}
+
 // TestListDeployments verifies list deployments behavior.
 func TestListDeployments(t *testing.T) {
 	t.Run("invalid input: empty app id", func(t *testing.T) {
@@ -245,9 +614,9 @@ func TestListDeployments(t *testing.T) {
 		st := store.NewMemoryStore()
 		svc := service.NewAppService(st)
 
-		deps, err := svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: ""})
+		list, err := svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: ""})
 		assert.ErrorIs(t, err, service.ErrInvalidInput)
-		assert.Nil(t, deps)
+		assert.Nil(t, list.Deployments)
 	})
 
 	t.Run("not found: app missing", func(t *testing.T) {
@@ -255,9 +624,9 @@ func TestListDeployments(t *testing.T) {
 		st := store.NewMemoryStore()
 		svc := service.NewAppService(st)
 
-		deps, err := svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: "missing"})
+		list, err := svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: "missing"})
 		assert.ErrorIs(t, err, service.ErrNotFound)
-		assert.Nil(t, deps)
+		assert.Nil(t, list.Deployments)
 	})
 
 	t.Run("ok: returns deployments in create order", func(t *testing.T) {
@@ -274,11 +643,57 @@ func TestListDeployments(t *testing.T) {
 		assert.NoError(t, st.CreateDeployment(ctx, dep1))
 		assert.NoError(t, st.CreateDeployment(ctx, dep2))
 
-		deps, err := svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: app.ID})
+		list, err := svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: app.ID})
 		assert.NoError(t, err)
-		assert.Len(t, deps, 2)
-		assert.Equal(t, dep1.ID, deps[0].ID)
-		assert.Equal(t, dep2.ID, deps[1].ID)
+		assert.Len(t, list.Deployments, 2)
+		assert.Equal(t, dep1.ID, list.Deployments[0].ID)
+		assert.Equal(t, dep2.ID, list.Deployments[1].ID)
+		assert.Equal(t, 2, list.Total)
+		assert.Equal(t, 1, list.Page)
+		assert.Equal(t, service.DefaultPageSize, list.PageSize)
+	})
+
+	t.Run("ok: pages and filters", func(t *testing.T) {
+		ctx := context.Background()
+		st := store.NewMemoryStore()
+		svc := service.NewAppService(st)
+
+		app, err := domain.NewApp(domain.NewAppParams{Name: "hello", Image: "nginx:latest"})
+		assert.NoError(t, err)
+		assert.NoError(t, st.CreateApp(ctx, app))
+		deps := make([]domain.Deployment, 0, 3)
+		for i := 0; i < 3; i++ {
+			dep := domain.NewDeployment(app.ID)
+			assert.NoError(t, st.CreateDeployment(ctx, dep))
+			deps = append(deps, dep)
+		}
+		deps[1].Status = domain.DeploymentStatusFailed
+		deps[1], err = st.UpdateDeployment(ctx, deps[1])
+		assert.NoError(t, err)
+
+		list, err := svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: app.ID, Page: 2, PageSize: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, list.Total)
+		assert.Len(t, list.Deployments, 1)
+		assert.Equal(t, deps[2].ID, list.Deployments[0].ID)
+
+		list, err = svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: app.ID, Status: domain.DeploymentStatusFailed})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, list.Total)
+		assert.Equal(t, deps[1].ID, list.Deployments[0].ID)
+	})
+
+	t.Run("invalid input: bad status or page", func(t *testing.T) {
+		ctx := context.Background()
+		st := store.NewMemoryStore()
+		svc := service.NewAppService(st)
+
+		_, err := svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: "x", Status: "NOPE"})
+		assert.ErrorIs(t, err, service.ErrInvalidInput)
+		_, err = svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: "x", PageSize: service.MaxPageSize + 1})
+		assert.ErrorIs(t, err, service.ErrInvalidInput)
+		_, err = svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: "x", Page: -1})
+		assert.ErrorIs(t, err, service.ErrInvalidInput)
 	})
 
 	t.Run("store error bubbles up", func(t *testing.T) {
@@ -294,8 +709,43 @@ func TestListDeployments(t *testing.T) {
 		}
 		svc := service.NewAppService(st)
 
-		deps, err := svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: app.ID})
+		list, err := svc.ListDeployments(ctx, service.ListDeploymentsParams{AppID: app.ID})
 		assert.Error(t, err)
-		assert.Nil(t, deps)
+		assert.Nil(t, list.Deployments)
 	})
 }
+
+// TestGetDeploymentByID verifies the detail carries the app and a queue position only while queued.
+func TestGetDeploymentByID(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	svc := service.NewAppServiceWithRuntime(st, &fakeRuntime{})
+
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	first, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	second, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+
+	got, err := svc.GetDeploymentByID(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, got.Deployment.ID)
+	assert.Equal(t, app.ID, got.App.ID)
+	assert.Equal(t, 2, got.QueuePosition)
+
+	_, err = svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+	got, err = svc.GetDeploymentByID(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.QueuePosition)
+	got, err = svc.GetDeploymentByID(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusRunning, got.Deployment.Status)
+	assert.Zero(t, got.QueuePosition)
+
+	_, err = svc.GetDeploymentByID(ctx, "missing")
+	assert.ErrorIs(t, err, service.ErrNotFound)
+	_, err = svc.GetDeploymentByID(ctx, "")
+	assert.ErrorIs(t, err, service.ErrInvalidInput)
+}
@@ -9,7 +9,12 @@ var (
 	ErrInvalidInput = errors.New("invalid input")
 	ErrConflict     = errors.New("conflict")
 	ErrNotFound     = errors.New("not found")
+	// ErrVersionMismatch means the caller's copy of a record is stale.
+	ErrVersionMismatch = errors.New("version mismatch")
 
 	ErrNoWork    = errors.New("no queued deployments")
 	ErrNoRuntime = errors.New("runtime not configured")
+	ErrLeaseLost = errors.New("deployment lease lost")
+	// ErrTeardown means the runtime could not remove what it runs for an app.
+	ErrTeardown = errors.New("runtime teardown failed")
 )
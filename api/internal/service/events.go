@@ -0,0 +1,195 @@
+// Service events for following deployments and apps as they change
+// Deployment transitions and app status changes are published once they are stored
+// Events are numbered in publish order and the most recent ones are kept for replay
+// A subscriber resumes after the last event it saw while that event is still kept
+// The bus lives in this process, so each API replica only sees its own writes
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// EventType names what an event reports.
+type EventType string
+
+// Event types.
+const (
+	EventDeploymentStatus EventType = "deployment.status"
+	EventAppStatus        EventType = "app.status"
+	EventAppDeleted       EventType = "app.deleted"
+	// EventReset tells a resuming subscriber the events it missed are gone, so it should reload what it shows
+	EventReset EventType = "reset"
+)
+
+// Event is one change published on the bus
+// Deployment is set for deployment events and App for app events
+type Event struct {
+	ID         uint64
+	Type       EventType
+	AppID      string
+	At         time.Time
+	Deployment *domain.Deployment
+	App        *domain.App
+}
+
+// Bus sizes.
+const (
+	// eventHistory is how many recent events are kept for subscribers that resume
+	eventHistory = 1024
+	// eventSubscriberBuffer is how far a subscriber may fall behind before it is dropped
+	eventSubscriberBuffer = 64
+)
+
+// EventsParams selects which events to follow
+// LastEventID resumes after that event; empty starts with the next one published
+type EventsParams struct {
+	AppID       string // only this app's events; empty follows every app
+	LastEventID string
+}
+
+// EventSubscription receives events until it is closed or falls too far behind
+type EventSubscription struct {
+	bus *eventBus
+	sub *eventSub
+}
+
+// Events returns the channel events arrive on. It is closed when the subscriber
+// falls behind, after which it should resubscribe from the last event it handled.
+func (e *EventSubscription) Events() <-chan Event {
+	return e.sub.ch
+}
+
+// Close stops delivery. It is safe to call more than once.
+func (e *EventSubscription) Close() {
+	e.bus.unsubscribe(e.sub)
+}
+
+// SubscribeEvents follows deployment and app changes, first replaying those after p.LastEventID.
+// A LastEventID the bus no longer has, or never had, yields a single EventReset before live events.
+func (s *AppService) SubscribeEvents(ctx context.Context, p EventsParams) (*EventSubscription, error) {
+	var after uint64
+	if p.LastEventID != "" {
+		id, err := strconv.ParseUint(p.LastEventID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid last event id", ErrInvalidInput)
+		}
+		after = id
+	}
+	if p.AppID != "" {
+		if _, err := s.store.GetAppByID(ctx, p.AppID); err != nil {
+			if errors.Is(err, contracts.ErrNotFound) {
+				return nil, ErrNotFound
+			}
+			return nil, err
+		}
+	}
+	return &EventSubscription{bus: s.events, sub: s.events.subscribe(p.AppID, after)}, nil
+}
+
+// deploymentChanged publishes dep's stored state and mirrors it onto its app.
+func (s *AppService) deploymentChanged(ctx context.Context, dep domain.Deployment) {
+	s.events.publish(Event{Type: EventDeploymentStatus, AppID: dep.AppID, Deployment: &dep})
+	s.syncAppStatus(ctx, dep)
+}
+
+// appChanged publishes an app's stored status.
+func (s *AppService) appChanged(app domain.App) {
+	s.events.publish(Event{Type: EventAppStatus, AppID: app.ID, App: &app})
+}
+
+// eventBus fans events out to subscribers and keeps the latest for replay.
+type eventBus struct {
+	mu      sync.Mutex
+	last    uint64  // id of the newest event published
+	history []Event // the newest events, oldest first
+	subs    map[*eventSub]struct{}
+}
+
+// eventSub is one subscriber and the app it is limited to, if any.
+type eventSub struct {
+	appID string
+	ch    chan Event
+}
+
+// newEventBus returns an empty bus.
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[*eventSub]struct{})}
+}
+
+// publish numbers e, keeps it and hands it to every matching subscriber without waiting.
+// A subscriber whose buffer is full is dropped rather than holding up the writer.
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.last++
+	e.ID = b.last
+	e.At = time.Now().UTC()
+	if len(b.history) == eventHistory {
+		copy(b.history, b.history[1:])
+		b.history = b.history[:eventHistory-1]
+	}
+	b.history = append(b.history, e)
+
+	for sub := range b.subs {
+		if !sub.wants(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// subscribe registers a subscriber with the kept events after the given id already queued.
+func (b *eventBus) subscribe(appID string, after uint64) *eventSub {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &eventSub{appID: appID}
+	var replay []Event
+	switch {
+	case after == 0:
+	case after > b.last || (len(b.history) > 0 && after < b.history[0].ID-1):
+		// Ids restart with the process, and old events fall out of the history
+		replay = []Event{{ID: b.last, Type: EventReset, AppID: appID, At: time.Now().UTC()}}
+	default:
+		for _, e := range b.history {
+			if e.ID > after && sub.wants(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+	sub.ch = make(chan Event, len(replay)+eventSubscriberBuffer)
+	for _, e := range replay {
+		sub.ch <- e
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes sub and closes its channel unless publish already dropped it.
+func (b *eventBus) unsubscribe(sub *eventSub) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// wants reports whether e is for the subscriber's app.
+func (s *eventSub) wants(e Event) bool {
+	return s.appID == "" || s.appID == e.AppID
+}
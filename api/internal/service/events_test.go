@@ -0,0 +1,210 @@
+// Tests for the deployment and app event bus
+// Tests verify transitions and status changes are published in order
+// Tests verify a subscriber resumes after the last event it saw
+// Tests verify unknown or evicted event ids reset the subscriber
+// Tests verify a subscriber that falls behind is dropped
+
+package service_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// received returns the events already queued on sub and whether its channel is still open.
+func received(sub *service.EventSubscription) ([]service.Event, bool) {
+	var out []service.Event
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return out, false
+			}
+			out = append(out, e)
+		default:
+			return out, true
+		}
+	}
+}
+
+// describe summarises events as "type:status" for comparison.
+func describe(events []service.Event) []string {
+	out := make([]string, 0, len(events))
+	for _, e := range events {
+		var status string
+		switch {
+		case e.Deployment != nil:
+			status = string(e.Deployment.Status)
+		case e.App != nil:
+			status = string(e.App.Status)
+		}
+		out = append(out, string(e.Type)+":"+status)
+	}
+	return out
+}
+
+// TestSubscribeEvents verifies a deploy publishes each transition and the app's status changes.
+func TestSubscribeEvents(t *testing.T) {
+	ctx := context.Background()
+	url := "https://hello.example.com"
+	svc := service.NewAppServiceWithRuntime(store.NewMemoryStore(), &fakeRuntime{url: &url})
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+
+	sub, err := svc.SubscribeEvents(ctx, service.EventsParams{})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	_, err = svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+	require.NoError(t, svc.DeleteApp(ctx, service.DeleteAppParams{AppID: app.ID}))
+
+	events, open := received(sub)
+	assert.True(t, open)
+	assert.Equal(t, []string{
+		"deployment.status:QUEUED",
+		"app.status:BUILDING",
+		"deployment.status:BUILDING",
+		"deployment.status:DEPLOYING",
+		"deployment.status:RUNNING",
+		"app.status:RUNNING",
+		"app.status:DELETING",
+		"app.deleted:DELETING",
+	}, describe(events))
+	for i, e := range events {
+		assert.Equal(t, app.ID, e.AppID)
+		assert.False(t, e.At.IsZero())
+		if i > 0 {
+			assert.Equal(t, events[i-1].ID+1, e.ID)
+		}
+	}
+
+	sub.Close()
+	_, open = received(sub)
+	assert.False(t, open)
+	sub.Close()
+}
+
+// TestSubscribeEvents_Resume verifies Last-Event-ID replays what came after it, for one app when asked.
+func TestSubscribeEvents_Resume(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewAppServiceWithRuntime(store.NewMemoryStore(), &fakeRuntime{})
+	hello, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	other, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "other", Image: "nginx:latest"})
+	require.NoError(t, err)
+
+	all, err := svc.SubscribeEvents(ctx, service.EventsParams{})
+	require.NoError(t, err)
+	defer all.Close()
+	for _, id := range []string{hello.ID, other.ID, hello.ID} {
+		_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: id})
+		require.NoError(t, err)
+	}
+	events, _ := received(all)
+	require.Len(t, events, 5)
+
+	// Resuming after the first event replays the rest, then goes on live
+	resumed, err := svc.SubscribeEvents(ctx, service.EventsParams{LastEventID: strconv.FormatUint(events[0].ID, 10)})
+	require.NoError(t, err)
+	defer resumed.Close()
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: other.ID})
+	require.NoError(t, err)
+	replayed, _ := received(resumed)
+	if assert.Len(t, replayed, 5) {
+		assert.Equal(t, events[1].ID, replayed[0].ID)
+		assert.Equal(t, other.ID, replayed[4].AppID)
+	}
+
+	// An app's stream only carries that app's events
+	onlyHello, err := svc.SubscribeEvents(ctx, service.EventsParams{AppID: hello.ID, LastEventID: strconv.FormatUint(events[0].ID, 10)})
+	require.NoError(t, err)
+	defer onlyHello.Close()
+	replayed, _ = received(onlyHello)
+	if assert.Len(t, replayed, 2) {
+		for _, e := range replayed {
+			assert.Equal(t, hello.ID, e.AppID)
+		}
+	}
+
+	// Nothing new since the latest event replays nothing
+	latest, err := svc.SubscribeEvents(ctx, service.EventsParams{LastEventID: strconv.FormatUint(events[4].ID+1, 10)})
+	require.NoError(t, err)
+	defer latest.Close()
+	replayed, _ = received(latest)
+	assert.Empty(t, replayed)
+}
+
+// TestSubscribeEvents_Reset verifies ids the bus does not have tell the subscriber to reload.
+func TestSubscribeEvents_Reset(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewAppServiceWithRuntime(store.NewMemoryStore(), &fakeRuntime{})
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+
+	// An id from before a restart is ahead of this process's ids
+	sub, err := svc.SubscribeEvents(ctx, service.EventsParams{LastEventID: "999"})
+	require.NoError(t, err)
+	events, _ := received(sub)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, service.EventReset, events[0].Type)
+	}
+	sub.Close()
+
+	// Events older than the kept history are gone
+	for range 1100 {
+		_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+		require.NoError(t, err)
+	}
+	sub, err = svc.SubscribeEvents(ctx, service.EventsParams{LastEventID: "1"})
+	require.NoError(t, err)
+	events, _ = received(sub)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, service.EventReset, events[0].Type)
+		assert.Equal(t, uint64(1101), events[0].ID)
+	}
+	sub.Close()
+
+	_, err = svc.SubscribeEvents(ctx, service.EventsParams{LastEventID: "abc"})
+	assert.ErrorIs(t, err, service.ErrInvalidInput)
+	_, err = svc.SubscribeEvents(ctx, service.EventsParams{AppID: "missing"})
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
+
+// TestSubscribeEvents_Lagging verifies a subscriber that stops reading is dropped without blocking writers.
+func TestSubscribeEvents_Lagging(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewAppServiceWithRuntime(store.NewMemoryStore(), &fakeRuntime{})
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+
+	sub, err := svc.SubscribeEvents(ctx, service.EventsParams{})
+	require.NoError(t, err)
+	defer sub.Close()
+	for range 100 {
+		_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+		require.NoError(t, err)
+	}
+
+	events, open := received(sub)
+	assert.False(t, open)
+	require.NotEmpty(t, events)
+	assert.Less(t, len(events), 100)
+
+	// It picks up again from the last event it handled
+	resumed, err := svc.SubscribeEvents(ctx, service.EventsParams{LastEventID: strconv.FormatUint(events[len(events)-1].ID, 10)})
+	require.NoError(t, err)
+	defer resumed.Close()
+	rest, _ := received(resumed)
+	assert.Equal(t, 101, len(events)+len(rest))
+	assert.Equal(t, domain.DeploymentStatusQueued, rest[len(rest)-1].Deployment.Status)
+}
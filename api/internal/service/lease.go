@@ -0,0 +1,63 @@
+// Lease heartbeat for claimed deployments
+// A claim expires unless the holder keeps renewing it
+// The heartbeat renews at a third of the lease so one slow call is not fatal
+// Losing the claim cancels the deploy so two workers never race
+// Transient store errors are retried on the next tick
+
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+)
+
+// leaseKeeper renews one deployment claim in the background.
+type leaseKeeper struct {
+	lost atomic.Bool
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// keepLease starts renewing the claim on depID and calls onLost if it is taken away.
+func (s *AppService) keepLease(ctx context.Context, depID string, onLost func()) *leaseKeeper {
+	ctx, stop := context.WithCancel(ctx)
+	k := &leaseKeeper{stop: stop}
+
+	interval := s.lease / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	k.wg.Add(1)
+	go func() {
+		defer k.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := s.store.RenewDeploymentLease(ctx, depID, s.workerID, s.lease)
+				if errors.Is(err, contracts.ErrLeaseLost) || errors.Is(err, contracts.ErrNotFound) {
+					k.lost.Store(true)
+					onLost()
+					return
+				}
+			}
+		}
+	}()
+	return k
+}
+
+// Stop ends the heartbeat and reports whether the claim was lost.
+func (k *leaseKeeper) Stop() (lost bool) {
+	k.stop()
+	k.wg.Wait()
+	return k.lost.Load()
+}
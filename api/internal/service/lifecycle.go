@@ -0,0 +1,90 @@
+// Service logic for managing a deployed app's workload
+// Stop, start and restart act on what the last deployment left running
+// None of them redeploy, so the app keeps its image and config
+// Status asks the runtime directly and reports live state
+// An app that was never deployed has nothing to act on and is not found
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// StopApp stops an app's workload and returns its status afterwards.
+func (s *AppService) StopApp(ctx context.Context, appID string) (domain.RuntimeStatus, error) {
+	return s.runLifecycle(ctx, appID, "stop", contracts.Runtime.Stop)
+}
+
+// StartApp starts a stopped workload again and returns its status afterwards.
+func (s *AppService) StartApp(ctx context.Context, appID string) (domain.RuntimeStatus, error) {
+	return s.runLifecycle(ctx, appID, "start", contracts.Runtime.Start)
+}
+
+// RestartApp restarts an app's workload and returns its status afterwards.
+func (s *AppService) RestartApp(ctx context.Context, appID string) (domain.RuntimeStatus, error) {
+	return s.runLifecycle(ctx, appID, "restart", contracts.Runtime.Restart)
+}
+
+// AppRuntimeStatus reports what an app's workload is doing right now.
+func (s *AppService) AppRuntimeStatus(ctx context.Context, appID string) (domain.RuntimeStatus, error) {
+	app, err := s.runtimeApp(ctx, appID)
+	if err != nil {
+		return domain.RuntimeStatus{}, err
+	}
+	st, err := s.runtime.Status(ctx, app)
+	if err != nil {
+		return domain.RuntimeStatus{}, runtimeErr("status", err)
+	}
+	return st, nil
+}
+
+// runLifecycle applies one runtime operation to an app and then reads its status.
+func (s *AppService) runLifecycle(ctx context.Context, appID, op string, fn func(contracts.Runtime, context.Context, domain.App) error) (domain.RuntimeStatus, error) {
+	app, err := s.runtimeApp(ctx, appID)
+	if err != nil {
+		return domain.RuntimeStatus{}, err
+	}
+	if app.Status == domain.AppStatusPaused && op != "stop" {
+		// Starting it here would leave the app marked paused behind a maintenance page
+		return domain.RuntimeStatus{}, fmt.Errorf("%w: app is paused; resume it instead", ErrConflict)
+	}
+	if err := fn(s.runtime, ctx, app); err != nil {
+		return domain.RuntimeStatus{}, runtimeErr(op, err)
+	}
+	st, err := s.runtime.Status(ctx, app)
+	if err != nil {
+		return domain.RuntimeStatus{}, runtimeErr("status", err)
+	}
+	return st, nil
+}
+
+// runtimeApp loads an app for a runtime operation.
+func (s *AppService) runtimeApp(ctx context.Context, appID string) (domain.App, error) {
+	if appID == "" {
+		return domain.App{}, fmt.Errorf("%w: app id is required", ErrInvalidInput)
+	}
+	if s.runtime == nil {
+		return domain.App{}, ErrNoRuntime
+	}
+	app, err := s.store.GetAppByID(ctx, appID)
+	if err != nil {
+		if errors.Is(err, contracts.ErrNotFound) {
+			return domain.App{}, ErrNotFound
+		}
+		return domain.App{}, err
+	}
+	return app, nil
+}
+
+// runtimeErr maps a runtime error; a workload the runtime does not know is not found.
+func runtimeErr(op string, err error) error {
+	if errors.Is(err, contracts.ErrNotFound) {
+		return fmt.Errorf("%w: app has no workload to %s", ErrNotFound, op)
+	}
+	return fmt.Errorf("runtime %s failed: %w", op, err)
+}
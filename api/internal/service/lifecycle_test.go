@@ -0,0 +1,106 @@
+// Tests for managing a deployed app's workload
+// Tests drive stop start and restart through a fake runtime
+// Tests verify the status returned after each operation
+// Tests cover apps that were never deployed and missing runtimes
+// These tests keep lifecycle errors mapped consistently
+
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// TestAppLifecycle verifies stop, start and restart of a deployed app.
+func TestAppLifecycle(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &fakeRuntime{}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	_, err = svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+
+	got, err := svc.StopApp(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStateExited, got.State)
+
+	got, err = svc.StartApp(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStateRunning, got.State)
+
+	got, err = svc.RestartApp(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStateRunning, got.State)
+	assert.Equal(t, 1, got.RestartCount)
+
+	got, err = svc.AppRuntimeStatus(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RuntimeStatus{State: domain.RuntimeStateRunning, RestartCount: 1}, got)
+
+	// Lifecycle operations never redeploy.
+	assert.Equal(t, 1, rt.called)
+}
+
+// TestAppLifecycle_Errors verifies lifecycle input and runtime errors.
+func TestAppLifecycle_Errors(t *testing.T) {
+	tests := []struct {
+		label     string
+		appExists bool
+		appID     string
+		deployed  bool
+		noRuntime bool
+		rtErr     error
+		err       error
+		errText   string
+	}{
+		{label: "invalid input: empty app id", appID: "", err: service.ErrInvalidInput},
+		{label: "not found: app missing", appID: "missing", err: service.ErrNotFound},
+		{label: "not found: never deployed", appExists: true, err: service.ErrNotFound, errText: "no workload to stop"},
+		{label: "no runtime", appExists: true, noRuntime: true, err: service.ErrNoRuntime},
+		{label: "runtime failure", appExists: true, deployed: true, rtErr: errors.New("daemon unreachable"), errText: "runtime stop failed: daemon unreachable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			ctx := context.Background()
+			st := store.NewMemoryStore()
+			rt := &fakeRuntime{lifecycleErr: tt.rtErr}
+			if tt.deployed {
+				rt.state = domain.RuntimeStateRunning
+			}
+			svc := service.NewAppServiceWithRuntime(st, rt)
+			if tt.noRuntime {
+				svc = service.NewAppService(st)
+			}
+
+			appID := tt.appID
+			if tt.appExists {
+				app, err := domain.NewApp(domain.NewAppParams{Name: "hello", Image: "nginx:latest"})
+				require.NoError(t, err)
+				require.NoError(t, st.CreateApp(ctx, app))
+				appID = app.ID
+			}
+
+			_, err := svc.StopApp(ctx, appID)
+			require.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+			if tt.errText != "" {
+				assert.ErrorContains(t, err, tt.errText)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+// Service logic for reading an app's logs
+// Lines come straight from the runtime and are paged with an opaque cursor
+// A cursor marks the last line returned so the next page starts right after it
+// Deployment logs are the stored build log followed by the app's output since the deployment started
+// Following streams new lines until the caller goes away or the workload does
+
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// Log page size limits.
+const (
+	DefaultLogLimit = 100
+	MaxLogLimit     = 1000
+)
+
+// logPollInterval is how often a followed deployment's build log is checked for new lines.
+const logPollInterval = time.Second
+
+// LogsParams selects which log lines to read
+// Without a cursor, Since and Tail pick where the app's output starts; a cursor overrides both
+type LogsParams struct {
+	Cursor string
+	Since  *time.Time
+	Tail   int // only the last Tail lines already written; zero reads them all
+	Limit  int // lines per page; zero means DefaultLogLimit
+}
+
+// LogPage is one page of log lines, oldest first
+// Cursor continues after the last line and is empty only when nothing has been read yet
+// HasMore reports that lines past this page were already written
+type LogPage struct {
+	Entries []domain.LogEntry
+	Cursor  string
+	HasMore bool
+}
+
+// errPageFull stops a runtime read once a page has one line past its limit.
+var errPageFull = errors.New("log page full")
+
+// AppLogs returns a page of an app's log lines. An app with nothing deployed has none.
+func (s *AppService) AppLogs(ctx context.Context, appID string, p LogsParams) (LogPage, error) {
+	limit, err := logLimit(p.Limit)
+	if err != nil {
+		return LogPage{}, err
+	}
+	q, cur, err := logQuery(nil, p)
+	if err != nil {
+		return LogPage{}, err
+	}
+	app, err := s.runtimeApp(ctx, appID)
+	if err != nil {
+		return LogPage{}, err
+	}
+	page := LogPage{Cursor: p.Cursor}
+	if err := s.readOutput(ctx, app, q, &cur, limit, &page); err != nil {
+		return LogPage{}, err
+	}
+	return page, nil
+}
+
+// DeploymentLogs returns a page of a deployment's build log, then of the app's output since the deployment started.
+// The output is read once the build log is exhausted, so a deployment that has not started only has build lines.
+func (s *AppService) DeploymentLogs(ctx context.Context, deploymentID string, p LogsParams) (LogPage, error) {
+	limit, err := logLimit(p.Limit)
+	if err != nil {
+		return LogPage{}, err
+	}
+	dep, app, err := s.deploymentLogSource(ctx, deploymentID)
+	if err != nil {
+		return LogPage{}, err
+	}
+	q, cur, err := logQuery(dep.StartedAt, p)
+	if err != nil {
+		return LogPage{}, err
+	}
+
+	page := LogPage{Cursor: p.Cursor}
+	build, err := s.store.ListDeploymentLogs(ctx, dep.ID, contracts.Page{Offset: cur.build, Limit: limit + 1})
+	if err != nil {
+		return LogPage{}, err
+	}
+	if len(build) > limit {
+		build, page.HasMore = build[:limit], true
+	}
+	page.Entries = build
+	cur.build += len(build)
+	if len(build) > 0 {
+		page.Cursor = cur.String()
+	}
+	if page.HasMore || dep.StartedAt == nil {
+		return page, nil
+	}
+	if err := s.readOutput(ctx, app, q, &cur, limit-len(build), &page); err != nil {
+		return LogPage{}, err
+	}
+	return page, nil
+}
+
+// FollowAppLogs passes an app's log lines to emit as they are written, along with the cursor after each.
+// Without a cursor, Since or Tail it starts with the last DefaultLogLimit lines.
+// It returns when ctx ends, the workload goes away or emit fails.
+func (s *AppService) FollowAppLogs(ctx context.Context, appID string, p LogsParams, emit func(domain.LogEntry, string) error) error {
+	q, cur, err := logQuery(nil, p)
+	if err != nil {
+		return err
+	}
+	app, err := s.runtimeApp(ctx, appID)
+	if err != nil {
+		return err
+	}
+	err = s.followOutput(ctx, app, followQuery(q, p), cur, emit)
+	if errors.Is(err, contracts.ErrNotFound) {
+		return runtimeErr("follow", err)
+	}
+	return err
+}
+
+// FollowDeploymentLogs passes a deployment's build log lines to emit as they are written.
+// Once the deployment is RUNNING it goes on with the app's output since the deployment started,
+// as FollowAppLogs does; a deployment that ends any other way ends the stream.
+func (s *AppService) FollowDeploymentLogs(ctx context.Context, deploymentID string, p LogsParams, emit func(domain.LogEntry, string) error) error {
+	dep, app, err := s.deploymentLogSource(ctx, deploymentID)
+	if err != nil {
+		return err
+	}
+	if _, _, err := logQuery(nil, p); err != nil {
+		return err
+	}
+
+	// The build log is stored as the worker writes it, so poll it until the deployment is done
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+	var cur logCursor
+	for {
+		_, cur, _ = logQuery(dep.StartedAt, p)
+		build, err := s.store.ListDeploymentLogs(ctx, dep.ID, contracts.Page{Offset: cur.build})
+		if err != nil {
+			return err
+		}
+		for _, line := range build {
+			cur.build++
+			if err := emit(line, cur.String()); err != nil {
+				return err
+			}
+		}
+		p.Cursor = cur.String()
+		if !dep.Status.InProgress() {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if dep, err = s.getDeployment(ctx, dep.ID); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				// Deleted along with its app; there is nothing left to follow
+				return nil
+			}
+			return err
+		}
+	}
+	if dep.Status != domain.DeploymentStatusRunning {
+		return nil
+	}
+
+	q, cur, _ := logQuery(dep.StartedAt, p)
+	if cur.at.IsZero() {
+		// Nothing of the output was read yet, so Since and Tail still pick where it starts
+		q.Since, q.Tail = dep.StartedAt, p.Tail
+		if p.Since != nil && p.Since.After(*dep.StartedAt) {
+			q.Since = p.Since
+		}
+	}
+	err = s.followOutput(ctx, app, followQuery(q, p), cur, emit)
+	if errors.Is(err, contracts.ErrNotFound) {
+		// Replaced or removed since it went RUNNING
+		return nil
+	}
+	return err
+}
+
+// deploymentLogSource loads a deployment and its app.
+func (s *AppService) deploymentLogSource(ctx context.Context, deploymentID string) (domain.Deployment, domain.App, error) {
+	dep, err := s.getDeployment(ctx, deploymentID)
+	if err != nil {
+		return domain.Deployment{}, domain.App{}, err
+	}
+	app, err := s.runtimeApp(ctx, dep.AppID)
+	if err != nil {
+		return domain.Deployment{}, domain.App{}, err
+	}
+	return dep, app, nil
+}
+
+// readOutput adds up to limit lines of app's output selected by q to page and moves cur past them.
+// An app with nothing deployed has no output.
+func (s *AppService) readOutput(ctx context.Context, app domain.App, q contracts.LogQuery, cur *logCursor, limit int, page *LogPage) error {
+	var lines []domain.LogEntry
+	skip := cur.skipper()
+	err := s.runtime.Logs(ctx, app, q, func(e domain.LogEntry) error {
+		if skip(e) {
+			return nil
+		}
+		if len(lines) == limit {
+			page.HasMore = true
+			return errPageFull
+		}
+		lines = append(lines, e)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPageFull) && !errors.Is(err, contracts.ErrNotFound) {
+		return runtimeErr("logs", err)
+	}
+	if len(lines) > 0 {
+		*cur = cur.after(lines...)
+		page.Entries = append(page.Entries, lines...)
+		page.Cursor = cur.String()
+	}
+	return nil
+}
+
+// followOutput streams app's output selected by q to emit with the cursor after each line.
+// The runtime's ErrNotFound is returned as is for callers to map.
+func (s *AppService) followOutput(ctx context.Context, app domain.App, q contracts.LogQuery, cur logCursor, emit func(domain.LogEntry, string) error) error {
+	skip := cur.skipper()
+	err := s.runtime.Logs(ctx, app, q, func(e domain.LogEntry) error {
+		if skip(e) {
+			return nil
+		}
+		cur = cur.after(e)
+		return emit(e, cur.String())
+	})
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// followQuery turns q into a follow query, starting from recent lines when p did not say where to start.
+func followQuery(q contracts.LogQuery, p LogsParams) contracts.LogQuery {
+	if p.Cursor == "" && p.Since == nil && q.Tail == 0 {
+		q.Tail = DefaultLogLimit
+	}
+	q.Follow = true
+	return q
+}
+
+// logLimit validates a page size and applies the default.
+func logLimit(limit int) (int, error) {
+	if limit < 0 || limit > MaxLogLimit {
+		return 0, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidInput, MaxLogLimit)
+	}
+	if limit == 0 {
+		return DefaultLogLimit, nil
+	}
+	return limit, nil
+}
+
+// logQuery builds the runtime query for p, reading nothing older than floor when it is set.
+func logQuery(floor *time.Time, p LogsParams) (contracts.LogQuery, logCursor, error) {
+	if p.Tail < 0 {
+		return contracts.LogQuery{}, logCursor{}, fmt.Errorf("%w: tail must not be negative", ErrInvalidInput)
+	}
+	var q contracts.LogQuery
+	var cur logCursor
+	if p.Cursor != "" {
+		var err error
+		if cur, err = parseLogCursor(p.Cursor); err != nil {
+			return contracts.LogQuery{}, logCursor{}, err
+		}
+		if !cur.at.IsZero() {
+			q.Since = &cur.at
+		}
+	} else {
+		q.Since = p.Since
+		q.Tail = p.Tail
+	}
+	if floor != nil && (q.Since == nil || q.Since.Before(*floor)) {
+		q.Since = floor
+	}
+	return q, cur, nil
+}
+
+// logCursor is a position in a log: past the first build lines of a deployment's build log,
+// then just after the seen-th line of the app's output stamped at. A zero at means no output was read.
+// Lines can share a timestamp, so the time alone cannot say where a page ended.
+type logCursor struct {
+	build int
+	at    time.Time
+	seen  int
+}
+
+// parseLogCursor decodes a cursor from String.
+func parseLogCursor(s string) (logCursor, error) {
+	invalid := fmt.Errorf("%w: invalid cursor", ErrInvalidInput)
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return logCursor{}, invalid
+	}
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 3 {
+		return logCursor{}, invalid
+	}
+	var nums [3]int64
+	for i, part := range parts {
+		if nums[i], err = strconv.ParseInt(part, 10, 64); err != nil || nums[i] < 0 {
+			return logCursor{}, invalid
+		}
+	}
+	c := logCursor{build: int(nums[0]), seen: int(nums[2])}
+	if nums[1] != 0 {
+		c.at = time.Unix(0, nums[1]).UTC()
+	}
+	return c, nil
+}
+
+// String encodes the cursor for clients, which treat it as opaque.
+func (c logCursor) String() string {
+	var at int64
+	if !c.at.IsZero() {
+		at = c.at.UnixNano()
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d.%d", c.build, at, c.seen)))
+}
+
+// after returns the cursor just past entries of the app's output, which follow c in order.
+func (c logCursor) after(entries ...domain.LogEntry) logCursor {
+	last := entries[len(entries)-1].Timestamp
+	next := logCursor{build: c.build, at: last}
+	if last.Equal(c.at) {
+		next.seen = c.seen
+	}
+	for _, e := range entries {
+		if e.Timestamp.Equal(last) {
+			next.seen++
+		}
+	}
+	return next
+}
+
+// skipper returns a filter for the lines at the cursor's time that an earlier page already returned.
+func (c logCursor) skipper() func(domain.LogEntry) bool {
+	left := c.seen
+	return func(e domain.LogEntry) bool {
+		if left > 0 && e.Timestamp.Equal(c.at) {
+			left--
+			return true
+		}
+		left = 0
+		return false
+	}
+}
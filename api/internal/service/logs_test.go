@@ -0,0 +1,278 @@
+// Tests for reading an app's logs
+// Tests page through lines with the cursor, including lines sharing a timestamp
+// Tests verify tail, since and limit reach the runtime as asked
+// Tests verify deployment logs are the build log, then the output since the deployment started
+// Tests verify following emits each line with the cursor after it
+
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// logLines returns n lines one second apart starting at base, except the third and fourth share a time.
+func logLines(base time.Time, n int) []domain.LogEntry {
+	out := make([]domain.LogEntry, 0, n)
+	for i := range n {
+		ts := base.Add(time.Duration(i) * time.Second)
+		if i == 3 {
+			ts = out[2].Timestamp
+		}
+		out = append(out, domain.LogEntry{
+			Timestamp: ts,
+			Level:     domain.LogLevelInfo,
+			Message:   string(rune('a' + i)),
+			Source:    domain.LogSourceStdout,
+		})
+	}
+	return out
+}
+
+// messages returns the message of every line in order.
+func messages(entries []domain.LogEntry) string {
+	var out string
+	for _, e := range entries {
+		out += e.Message
+	}
+	return out
+}
+
+// deployedLogApp creates and deploys an app whose workload wrote lines.
+func deployedLogApp(t *testing.T, lines []domain.LogEntry) (*service.AppService, *fakeRuntime, domain.App, domain.Deployment) {
+	t.Helper()
+	ctx := context.Background()
+	rt := &fakeRuntime{logs: lines}
+	svc := service.NewAppServiceWithRuntime(store.NewMemoryStore(), rt)
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	dep, err := svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+	return svc, rt, app, dep
+}
+
+// TestAppLogs_Paging verifies the cursor walks every line exactly once.
+func TestAppLogs_Paging(t *testing.T) {
+	ctx := context.Background()
+	svc, _, app, _ := deployedLogApp(t, logLines(time.Now().UTC().Add(-time.Hour), 7))
+
+	first, err := svc.AppLogs(ctx, app.ID, service.LogsParams{Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "abc", messages(first.Entries))
+	assert.True(t, first.HasMore)
+	require.NotEmpty(t, first.Cursor)
+
+	// The page ended between two lines with the same time; the next starts after the one already read
+	second, err := svc.AppLogs(ctx, app.ID, service.LogsParams{Cursor: first.Cursor, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "def", messages(second.Entries))
+	assert.True(t, second.HasMore)
+
+	last, err := svc.AppLogs(ctx, app.ID, service.LogsParams{Cursor: second.Cursor, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "g", messages(last.Entries))
+	assert.False(t, last.HasMore)
+
+	// Nothing new keeps the cursor where it was
+	empty, err := svc.AppLogs(ctx, app.ID, service.LogsParams{Cursor: last.Cursor})
+	require.NoError(t, err)
+	assert.Empty(t, empty.Entries)
+	assert.Equal(t, last.Cursor, empty.Cursor)
+	assert.False(t, empty.HasMore)
+}
+
+// TestAppLogs_Query verifies since, tail and limit handling.
+func TestAppLogs_Query(t *testing.T) {
+	ctx := context.Background()
+	base := time.Now().UTC().Add(-time.Hour)
+	svc, rt, app, _ := deployedLogApp(t, logLines(base, 7))
+
+	page, err := svc.AppLogs(ctx, app.ID, service.LogsParams{Tail: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "fg", messages(page.Entries))
+	assert.Equal(t, 2, rt.logQuery.Tail)
+	assert.False(t, rt.logQuery.Follow)
+
+	since := base.Add(5 * time.Second)
+	page, err = svc.AppLogs(ctx, app.ID, service.LogsParams{Since: &since})
+	require.NoError(t, err)
+	assert.Equal(t, "fg", messages(page.Entries))
+
+	// A cursor decides where reading starts on its own
+	page, err = svc.AppLogs(ctx, app.ID, service.LogsParams{Limit: 1})
+	require.NoError(t, err)
+	page, err = svc.AppLogs(ctx, app.ID, service.LogsParams{Cursor: page.Cursor, Tail: 1, Since: &since})
+	require.NoError(t, err)
+	assert.Equal(t, "bcdefg", messages(page.Entries))
+	assert.Zero(t, rt.logQuery.Tail)
+
+	for _, p := range []service.LogsParams{
+		{Limit: -1},
+		{Limit: service.MaxLogLimit + 1},
+		{Tail: -1},
+		{Cursor: "not a cursor"},
+	} {
+		_, err = svc.AppLogs(ctx, app.ID, p)
+		assert.ErrorIs(t, err, service.ErrInvalidInput, "%+v", p)
+	}
+
+	_, err = svc.AppLogs(ctx, "missing", service.LogsParams{})
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
+
+// TestAppLogs_NotDeployed verifies an app that never ran has no lines.
+func TestAppLogs_NotDeployed(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewAppServiceWithRuntime(store.NewMemoryStore(), &fakeRuntime{})
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+
+	page, err := svc.AppLogs(ctx, app.ID, service.LogsParams{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Entries)
+	assert.Empty(t, page.Cursor)
+
+	err = svc.FollowAppLogs(ctx, app.ID, service.LogsParams{}, func(domain.LogEntry, string) error { return nil })
+	assert.ErrorIs(t, err, service.ErrNotFound)
+
+	_, err = service.NewAppService(store.NewMemoryStore()).AppLogs(ctx, app.ID, service.LogsParams{})
+	assert.ErrorIs(t, err, service.ErrNoRuntime)
+}
+
+// TestDeploymentLogs verifies deployment logs start when the deployment started.
+func TestDeploymentLogs(t *testing.T) {
+	ctx := context.Background()
+	old := logLines(time.Now().UTC().Add(-time.Hour), 3)
+	svc, rt, app, dep := deployedLogApp(t, old)
+	fresh := domain.LogEntry{Timestamp: dep.StartedAt.Add(time.Millisecond), Message: "z", Source: domain.LogSourceStdout}
+	rt.logs = append(rt.logs, fresh)
+
+	page, err := svc.DeploymentLogs(ctx, dep.ID, service.LogsParams{})
+	require.NoError(t, err)
+	assert.Equal(t, "z", messages(page.Entries))
+	assert.Equal(t, *dep.StartedAt, *rt.logQuery.Since)
+
+	// An earlier since does not reach past the deployment's start
+	page, err = svc.DeploymentLogs(ctx, dep.ID, service.LogsParams{Since: &old[0].Timestamp})
+	require.NoError(t, err)
+	assert.Equal(t, "z", messages(page.Entries))
+
+	// A deployment still waiting in the queue has not logged anything; following it waits for the build
+	queued, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	page, err = svc.DeploymentLogs(ctx, queued.ID, service.LogsParams{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Entries)
+	_, err = svc.DeploymentLogs(ctx, queued.ID, service.LogsParams{Tail: -1})
+	assert.ErrorIs(t, err, service.ErrInvalidInput)
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	var emitted int
+	err = svc.FollowDeploymentLogs(waitCtx, queued.ID, service.LogsParams{}, func(domain.LogEntry, string) error {
+		emitted++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Zero(t, emitted)
+
+	_, err = svc.DeploymentLogs(ctx, "missing", service.LogsParams{})
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
+
+// TestDeploymentLogs_Build verifies the build log comes first and pages run across into the output.
+func TestDeploymentLogs_Build(t *testing.T) {
+	ctx := context.Background()
+	build := []domain.LogEntry{
+		{Timestamp: time.Now().UTC(), Level: domain.LogLevelInfo, Message: "1", Source: string(domain.StepPullImage)},
+		{Timestamp: time.Now().UTC(), Level: domain.LogLevelInfo, Message: "2", Source: string(domain.StepPullImage)},
+		{Timestamp: time.Now().UTC(), Level: domain.LogLevelInfo, Message: "3", Source: string(domain.StepPullImage)},
+	}
+	rt := &fakeRuntime{logs: logLines(time.Now().UTC().Add(time.Hour), 3), build: build}
+	svc := service.NewAppServiceWithRuntime(store.NewMemoryStore(), rt)
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	dep, err := svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+
+	var got string
+	var cursor string
+	for _, want := range []string{"12", "3a", "bc"} {
+		page, err := svc.DeploymentLogs(ctx, dep.ID, service.LogsParams{Cursor: cursor, Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, want, messages(page.Entries))
+		assert.Equal(t, want != "bc", page.HasMore, want)
+		got += messages(page.Entries)
+		cursor = page.Cursor
+	}
+	assert.Equal(t, "123abc", got)
+
+	// Nothing new is written, so the last cursor stays put
+	page, err := svc.DeploymentLogs(ctx, dep.ID, service.LogsParams{Cursor: cursor})
+	require.NoError(t, err)
+	assert.Empty(t, page.Entries)
+	assert.Equal(t, cursor, page.Cursor)
+
+	// Following a RUNNING deployment replays the build log, then streams the output
+	var cursors []string
+	got = ""
+	err = svc.FollowDeploymentLogs(ctx, dep.ID, service.LogsParams{}, func(e domain.LogEntry, c string) error {
+		got += e.Message
+		cursors = append(cursors, c)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "123abc", got)
+	assert.True(t, rt.logQuery.Follow)
+	assert.Equal(t, *dep.StartedAt, *rt.logQuery.Since)
+
+	// A cursor from the stream resumes after its line in either part
+	got = ""
+	err = svc.FollowDeploymentLogs(ctx, dep.ID, service.LogsParams{Cursor: cursors[1]}, func(e domain.LogEntry, _ string) error {
+		got += e.Message
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "3abc", got)
+	page, err = svc.DeploymentLogs(ctx, dep.ID, service.LogsParams{Cursor: cursors[3]})
+	require.NoError(t, err)
+	assert.Equal(t, "bc", messages(page.Entries))
+}
+
+// TestFollowAppLogs verifies following emits lines with cursors that resume after them.
+func TestFollowAppLogs(t *testing.T) {
+	ctx := context.Background()
+	svc, rt, app, _ := deployedLogApp(t, logLines(time.Now().UTC().Add(-time.Hour), 5))
+
+	var got []domain.LogEntry
+	var cursors []string
+	err := svc.FollowAppLogs(ctx, app.ID, service.LogsParams{}, func(e domain.LogEntry, cursor string) error {
+		got = append(got, e)
+		cursors = append(cursors, cursor)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "abcde", messages(got))
+	assert.True(t, rt.logQuery.Follow)
+	assert.Equal(t, service.DefaultLogLimit, rt.logQuery.Tail)
+
+	// Resuming from the cursor of the third line skips it even though the fourth shares its time
+	got = nil
+	err = svc.FollowAppLogs(ctx, app.ID, service.LogsParams{Cursor: cursors[2]}, func(e domain.LogEntry, _ string) error {
+		got = append(got, e)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "de", messages(got))
+	assert.Zero(t, rt.logQuery.Tail)
+}
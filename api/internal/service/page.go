@@ -0,0 +1,35 @@
+// Page number handling shared by list operations
+// Pages are 1-based and sized by the caller within a fixed maximum
+// Zero values fall back to the first page and the default size
+// Results report the page that was actually served
+
+package service
+
+import (
+	"fmt"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+)
+
+// Page size limits for list operations.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// resolvePage validates a 1-based page request and converts it to a store window.
+func resolvePage(page, pageSize int) (contracts.Page, int, int, error) {
+	if page < 0 || pageSize < 0 {
+		return contracts.Page{}, 0, 0, fmt.Errorf("%w: page and pageSize must be positive", ErrInvalidInput)
+	}
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		return contracts.Page{}, 0, 0, fmt.Errorf("%w: pageSize must be at most %d", ErrInvalidInput, MaxPageSize)
+	}
+	return contracts.Page{Limit: pageSize, Offset: (page - 1) * pageSize}, page, pageSize, nil
+}
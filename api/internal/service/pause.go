@@ -0,0 +1,98 @@
+// Service logic for pausing and resuming apps
+// Pausing stops the running workload and keeps its container and config
+// Resuming starts that same workload again without a new deployment
+// A paused app refuses new deployments unless the caller forces one
+// Pause and resume are idempotent so retries are safe
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// PauseAppParams identifies the app to pause
+// IfVersion, when set, refuses the pause unless the app is still at that version
+type PauseAppParams struct {
+	AppID     string
+	IfVersion int64
+}
+
+// ResumeAppParams identifies the app to resume
+// IfVersion, when set, refuses the resume unless the app is still at that version
+type ResumeAppParams struct {
+	AppID     string
+	IfVersion int64
+}
+
+// PauseApp stops a running app and marks it PAUSED.
+// Only an app whose latest deployment is RUNNING can be paused.
+func (s *AppService) PauseApp(ctx context.Context, p PauseAppParams) (domain.App, error) {
+	app, latest, err := s.pausableApp(ctx, p.AppID, p.IfVersion)
+	if err != nil {
+		return domain.App{}, err
+	}
+	if app.Status == domain.AppStatusPaused {
+		return app, nil
+	}
+	if latest == nil || latest.Status != domain.DeploymentStatusRunning {
+		return domain.App{}, fmt.Errorf("%w: only a running app can be paused", ErrConflict)
+	}
+
+	if err := s.runtime.Pause(ctx, latest.Spec.Apply(app)); err != nil {
+		return domain.App{}, runtimeErr("pause", err)
+	}
+	return s.setAppStatus(ctx, app.ID, domain.AppStatusPaused)
+}
+
+// ResumeApp starts a paused app again and gives it back the status of its latest deployment.
+func (s *AppService) ResumeApp(ctx context.Context, p ResumeAppParams) (domain.App, error) {
+	app, latest, err := s.pausableApp(ctx, p.AppID, p.IfVersion)
+	if err != nil {
+		return domain.App{}, err
+	}
+	if app.Status != domain.AppStatusPaused {
+		return app, nil
+	}
+	if latest == nil {
+		return domain.App{}, fmt.Errorf("%w: app has no deployment to resume", ErrConflict)
+	}
+
+	if err := s.runtime.Resume(ctx, latest.Spec.Apply(app)); err != nil {
+		return domain.App{}, runtimeErr("resume", err)
+	}
+	return s.setAppStatus(ctx, app.ID, latest.Status.AppStatus())
+}
+
+// pausableApp loads an app and its latest deployment for pause or resume.
+func (s *AppService) pausableApp(ctx context.Context, appID string, ifVersion int64) (domain.App, *domain.Deployment, error) {
+	app, err := s.runtimeApp(ctx, appID)
+	if err != nil {
+		return domain.App{}, nil, err
+	}
+	if ifVersion != 0 && app.Version != ifVersion {
+		return domain.App{}, nil, fmt.Errorf("%w: app is at version %d", ErrVersionMismatch, app.Version)
+	}
+	latest, err := s.LatestDeployment(ctx, app.ID)
+	if err != nil {
+		return domain.App{}, nil, err
+	}
+	return app, latest, nil
+}
+
+// setAppStatus stores an app status chosen by the caller rather than derived from a deployment.
+func (s *AppService) setAppStatus(ctx context.Context, appID string, status domain.AppStatus) (domain.App, error) {
+	app, err := s.store.UpdateAppStatus(ctx, appID, status)
+	if errors.Is(err, contracts.ErrNotFound) {
+		return domain.App{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.App{}, err
+	}
+	s.appChanged(app)
+	return app, nil
+}
@@ -0,0 +1,135 @@
+// Tests for pausing and resuming apps
+// Tests drive a running app through pause and resume
+// Tests verify paused apps refuse deploys unless forced
+// Tests cover pausing apps that are not running
+// These tests keep pause and resume idempotent
+
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// runningApp creates an app and deploys it through the fake runtime.
+func runningApp(t *testing.T, svc *service.AppService) domain.App {
+	t.Helper()
+	ctx := context.Background()
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:1.26"})
+	require.NoError(t, err)
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	_, err = svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+	return app
+}
+
+// TestPauseAndResume verifies a running app pauses, refuses deploys and comes back as it was.
+func TestPauseAndResume(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &fakeRuntime{}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+	app := runningApp(t, svc)
+
+	// Edits after the deploy must not leak into what is paused.
+	_, err := svc.UpdateApp(ctx, service.UpdateAppParams{AppID: app.ID, Image: ptrString("nginx:1.27")})
+	require.NoError(t, err)
+
+	paused, err := svc.PauseApp(ctx, service.PauseAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	assert.Equal(t, domain.AppStatusPaused, paused.Status)
+	assert.Equal(t, domain.RuntimeStateExited, rt.state)
+	require.Len(t, rt.paused, 1)
+	assert.Equal(t, "nginx:1.26", rt.paused[0].Image)
+
+	// Pausing again is a no-op.
+	again, err := svc.PauseApp(ctx, service.PauseAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	assert.Equal(t, paused.Version, again.Version)
+	assert.Len(t, rt.paused, 1)
+
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	assert.ErrorIs(t, err, service.ErrConflict)
+	_, err = svc.StartApp(ctx, app.ID)
+	assert.ErrorIs(t, err, service.ErrConflict)
+
+	resumed, err := svc.ResumeApp(ctx, service.ResumeAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	assert.Equal(t, domain.AppStatusRunning, resumed.Status)
+	assert.Equal(t, domain.RuntimeStateRunning, rt.state)
+	assert.Equal(t, 1, rt.called, "resume must not redeploy")
+
+	// Resuming an app that is not paused is a no-op.
+	again, err = svc.ResumeApp(ctx, service.ResumeAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	assert.Equal(t, resumed.Version, again.Version)
+}
+
+// TestDeployApp_ForcePaused verifies a forced deploy of a paused app ends the pause.
+func TestDeployApp_ForcePaused(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	svc := service.NewAppServiceWithRuntime(st, &fakeRuntime{})
+	app := runningApp(t, svc)
+	_, err := svc.PauseApp(ctx, service.PauseAppParams{AppID: app.ID})
+	require.NoError(t, err)
+
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID, Force: true})
+	require.NoError(t, err)
+	got, err := svc.GetAppByID(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AppStatusBuilding, got.Status)
+
+	_, err = svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+	got, err = svc.GetAppByID(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AppStatusRunning, got.Status)
+}
+
+// TestPauseApp_Errors verifies pause input handling and refusal of apps that are not running.
+func TestPauseApp_Errors(t *testing.T) {
+	tests := []struct {
+		label     string
+		appExists bool
+		appID     string
+		deployed  bool
+		ifVersion int64
+		err       error
+	}{
+		{label: "invalid input: empty app id", appID: "", err: service.ErrInvalidInput},
+		{label: "not found: app missing", appID: "missing", err: service.ErrNotFound},
+		{label: "conflict: never deployed", appExists: true, err: service.ErrConflict},
+		{label: "conflict: deployment still queued", appExists: true, deployed: true, err: service.ErrConflict},
+		{label: "version mismatch: stale if version", appExists: true, ifVersion: 7, err: service.ErrVersionMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			ctx := context.Background()
+			st := store.NewMemoryStore()
+			svc := service.NewAppServiceWithRuntime(st, &fakeRuntime{})
+
+			appID := tt.appID
+			if tt.appExists {
+				app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest"})
+				require.NoError(t, err)
+				appID = app.ID
+			}
+			if tt.deployed {
+				_, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: appID})
+				require.NoError(t, err)
+			}
+
+			_, err := svc.PauseApp(ctx, service.PauseAppParams{AppID: appID, IfVersion: tt.ifVersion})
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Recovery of deployments left behind by a crashed worker
+// A deployment is stuck when it is BUILDING or DEPLOYING past the deadline with no live lease
+// Stuck deployments are requeued until they run out of attempts
+// After that they are marked FAILED with a message saying why
+// The reaper runs once on start and then on a fixed interval
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// ReaperConfig controls how stuck deployments are found and how often.
+type ReaperConfig struct {
+	// Deadline is how long a started deployment may go without an update.
+	Deadline time.Duration
+	// Interval is the pause between scans in RunReaper.
+	Interval time.Duration
+}
+
+// Reaper and retry defaults.
+const (
+	defaultReaperDeadline = 15 * time.Minute
+	defaultReaperInterval = time.Minute
+	defaultMaxAttempts    = 3
+)
+
+// WithReaper overrides the reaper deadline and interval; zero fields keep their defaults.
+func WithReaper(cfg ReaperConfig) Option {
+	return func(s *AppService) {
+		if cfg.Deadline > 0 {
+			s.reaper.Deadline = cfg.Deadline
+		}
+		if cfg.Interval > 0 {
+			s.reaper.Interval = cfg.Interval
+		}
+	}
+}
+
+// WithMaxAttempts bounds how many times one deployment may be claimed before it is failed.
+func WithMaxAttempts(n int) Option { return func(s *AppService) { s.maxAttempts = n } }
+
+// ReapResult counts what one reaper pass did.
+type ReapResult struct {
+	Requeued int
+	Failed   int
+}
+
+// ReapStuckDeployments requeues or fails deployments that stopped making progress.
+func (s *AppService) ReapStuckDeployments(ctx context.Context) (ReapResult, error) {
+	var res ReapResult
+	cutoff := time.Now().UTC().Add(-s.reaper.Deadline)
+	stuck, err := s.store.ListStuckDeployments(ctx, cutoff)
+	if err != nil {
+		return res, err
+	}
+
+	for _, dep := range stuck {
+		if dep.Attempts < s.maxAttempts {
+			if err := dep.Requeue(); err != nil {
+				// Only in-progress deployments are listed, so there is nothing to requeue.
+				continue
+			}
+			requeued, err := s.store.RequeueDeployment(ctx, dep)
+			switch {
+			case errors.Is(err, contracts.ErrVersionMismatch):
+				// A worker picked it back up since we listed it; the claim is theirs now.
+				continue
+			case errors.Is(err, contracts.ErrConflict), errors.Is(err, contracts.ErrNotFound):
+				// Finished or removed since we listed it.
+				continue
+			case err != nil:
+				return res, err
+			}
+			s.deploymentChanged(ctx, requeued)
+			res.Requeued++
+			continue
+		}
+
+		msg := fmt.Sprintf("deployment stuck in %s for over %s; gave up after %d attempts",
+			dep.Status, s.reaper.Deadline, dep.Attempts)
+		_, err := s.failDeployment(ctx, dep, msg)
+		if errors.Is(err, contracts.ErrVersionMismatch) {
+			// A worker picked it back up since we listed it.
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		res.Failed++
+	}
+	return res, nil
+}
+
+// RunReaper reaps once immediately and then every interval until ctx is done.
+func (s *AppService) RunReaper(ctx context.Context) {
+	ticker := time.NewTicker(s.reaper.Interval)
+	defer ticker.Stop()
+	for {
+		res, err := s.ReapStuckDeployments(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Printf("reaper: %v", err)
+		case res.Requeued > 0 || res.Failed > 0:
+			log.Printf("reaper: requeued %d, failed %d stuck deployments", res.Requeued, res.Failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// failDeployment marks a deployment FAILED with msg and returns the stored record.
+func (s *AppService) failDeployment(ctx context.Context, dep domain.Deployment, msg string) (domain.Deployment, error) {
+	if err := dep.Fail(msg); err != nil {
+		return domain.Deployment{}, fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	failed, err := s.store.UpdateDeployment(ctx, dep)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	s.deploymentChanged(ctx, failed)
+	return failed, nil
+}
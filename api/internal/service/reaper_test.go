@@ -0,0 +1,119 @@
+// Tests for stuck deployment recovery
+// Tests cover requeue while attempts remain
+// Tests cover failing once attempts run out
+// Tests verify live leases are left alone
+// These tests keep crash recovery bounded
+
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// seedStartedDeployment claims a deployment and leaves it BUILDING as a crashed worker would.
+func seedStartedDeployment(t *testing.T, st *store.MemoryStore, lease time.Duration) domain.Deployment {
+	t.Helper()
+	ctx := context.Background()
+	app, err := domain.NewApp(domain.NewAppParams{Name: "hello", Image: "nginx:latest"})
+	require.NoError(t, err)
+	require.NoError(t, st.CreateApp(ctx, app))
+	require.NoError(t, st.CreateDeployment(ctx, domain.NewDeployment(app.ID)))
+
+	dep, err := st.TakeNextQueuedDeployment(ctx, "crashed-worker", lease)
+	require.NoError(t, err)
+	dep.Status = domain.DeploymentStatusBuilding
+	dep.UpdatedAt = time.Now().UTC().Add(-time.Hour)
+	dep, err = st.UpdateDeployment(ctx, dep)
+	require.NoError(t, err)
+	return dep
+}
+
+// TestReapStuckDeployments verifies requeue and fail decisions.
+func TestReapStuckDeployments(t *testing.T) {
+	t.Run("requeues while attempts remain", func(t *testing.T) {
+		ctx := context.Background()
+		st := store.NewMemoryStore()
+		dep := seedStartedDeployment(t, st, time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+
+		svc := service.NewAppService(st, service.WithReaper(service.ReaperConfig{Deadline: time.Minute}))
+		res, err := svc.ReapStuckDeployments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, service.ReapResult{Requeued: 1}, res)
+
+		got, err := st.GetDeploymentByID(ctx, dep.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DeploymentStatusQueued, got.Status)
+		assert.Nil(t, got.ClaimedBy)
+
+		next, err := st.TakeNextQueuedDeployment(ctx, "worker-2", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, dep.ID, next.ID)
+		assert.Equal(t, 2, next.Attempts)
+	})
+
+	t.Run("fails when attempts are exhausted", func(t *testing.T) {
+		ctx := context.Background()
+		st := store.NewMemoryStore()
+		dep := seedStartedDeployment(t, st, time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+
+		svc := service.NewAppService(st,
+			service.WithReaper(service.ReaperConfig{Deadline: time.Minute}),
+			service.WithMaxAttempts(1),
+		)
+		res, err := svc.ReapStuckDeployments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, service.ReapResult{Failed: 1}, res)
+
+		got, err := st.GetDeploymentByID(ctx, dep.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DeploymentStatusFailed, got.Status)
+		require.NotNil(t, got.Error)
+		assert.Contains(t, *got.Error, "stuck in BUILDING")
+		assert.Contains(t, *got.Error, "1 attempts")
+	})
+
+	t.Run("skips deployments with a live lease", func(t *testing.T) {
+		ctx := context.Background()
+		st := store.NewMemoryStore()
+		dep := seedStartedDeployment(t, st, time.Hour)
+
+		svc := service.NewAppService(st, service.WithReaper(service.ReaperConfig{Deadline: time.Minute}))
+		res, err := svc.ReapStuckDeployments(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, service.ReapResult{}, res)
+
+		got, err := st.GetDeploymentByID(ctx, dep.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DeploymentStatusBuilding, got.Status)
+	})
+}
+
+// TestProcessNextDeployment_AttemptsExhausted verifies reclaimed work is failed once over the limit.
+func TestProcessNextDeployment_AttemptsExhausted(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	dep := seedStartedDeployment(t, st, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	rt := &fakeRuntime{url: ptrString("https://hello.example.com")}
+	svc := service.NewAppServiceWithRuntime(st, rt, service.WithMaxAttempts(1))
+
+	got, err := svc.ProcessNextDeployment(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, dep.ID, got.ID)
+	assert.Equal(t, domain.DeploymentStatusFailed, got.Status)
+	if assert.NotNil(t, got.Error) {
+		assert.Equal(t, "deployment gave up after 1 attempts", *got.Error)
+	}
+	assert.Equal(t, 0, rt.called)
+}
@@ -0,0 +1,208 @@
+// Service logic for workers that run deploys in another process
+// A worker claims a deployment, heartbeats while its runtime works and reports the result
+// Heartbeats keep the claim alive and carry the steps and build log reported since the last one
+// A heartbeat or report for a claim the worker no longer holds is refused with ErrLeaseLost
+// Reporting on a deployment canceled meanwhile returns the canceled record instead
+// A worker that stops mid-deploy reports it interrupted so the deployment goes back in the queue
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// DeploymentClaim is a deployment a worker claimed and the app to deploy for it
+// App already has the deployment's spec applied; Lease is how long the claim lasts without a heartbeat
+type DeploymentClaim struct {
+	Deployment domain.Deployment
+	App        domain.App
+	Lease      time.Duration
+}
+
+// StepEvent is one step report from a worker's runtime, in the order it happened
+// A finished step with an empty Error completed
+type StepEvent struct {
+	Step     domain.StepID
+	Finished bool
+	Error    string
+}
+
+// WorkerProgress is what a worker's runtime reported since the worker's last call
+// Port is set once the runtime has resolved the port the app listens on
+type WorkerProgress struct {
+	Steps []StepEvent
+	Logs  []domain.LogEntry
+	Port  *int
+}
+
+// HeartbeatParams renews a worker's claim and records its progress
+type HeartbeatParams struct {
+	DeploymentID string
+	WorkerID     string
+	Progress     WorkerProgress
+}
+
+// ReportParams records how a worker's deploy ended
+// An empty Error means it succeeded and URL is where the app is served, if exposed
+// Interrupted means the worker stopped before the deploy finished, which puts the deployment back in the queue
+type ReportParams struct {
+	DeploymentID string
+	WorkerID     string
+	Progress     WorkerProgress
+	URL          *string
+	Error        string
+	Interrupted  bool
+}
+
+// ClaimDeployment claims the next queued deployment for workerID and moves it to DEPLOYING.
+// Deployments that end before reaching the worker are recorded and skipped; an empty queue is ErrNoWork.
+func (s *AppService) ClaimDeployment(ctx context.Context, workerID string) (DeploymentClaim, error) {
+	if workerID == "" {
+		return DeploymentClaim{}, fmt.Errorf("%w: worker id is required", ErrInvalidInput)
+	}
+	for {
+		dep, app, err := s.claimNext(ctx, workerID)
+		if err != nil && dep.Status == domain.DeploymentStatusFailed {
+			log.Printf("claim for %s: deployment %s: %v", workerID, dep.ID, err)
+			continue
+		}
+		if err == nil && !dep.Status.InProgress() {
+			log.Printf("claim for %s: deployment %s ended as %s before it started", workerID, dep.ID, dep.Status)
+			continue
+		}
+		if err != nil {
+			return DeploymentClaim{}, err
+		}
+		return DeploymentClaim{Deployment: dep, App: app, Lease: s.lease}, nil
+	}
+}
+
+// HeartbeatDeployment extends a worker's claim and stores the progress it reported.
+func (s *AppService) HeartbeatDeployment(ctx context.Context, p HeartbeatParams) error {
+	if err := validateWorkerCall(p.DeploymentID, p.WorkerID); err != nil {
+		return err
+	}
+	err := s.store.RenewDeploymentLease(ctx, p.DeploymentID, p.WorkerID, s.lease)
+	switch {
+	case errors.Is(err, contracts.ErrLeaseLost):
+		return ErrLeaseLost
+	case errors.Is(err, contracts.ErrNotFound):
+		return ErrNotFound
+	case err != nil:
+		return err
+	}
+
+	if err := s.appendWorkerLogs(ctx, p.DeploymentID, p.Progress.Logs); err != nil {
+		return err
+	}
+	if len(p.Progress.Steps) == 0 && p.Progress.Port == nil {
+		return nil
+	}
+	dep, err := s.claimedBy(ctx, p.DeploymentID, p.WorkerID)
+	if err != nil {
+		return err
+	}
+	applyProgress(&dep, p.Progress)
+	_, err = s.updateClaimed(ctx, dep)
+	return err
+}
+
+// ReportDeployment stores the outcome of a worker's deploy along with its last progress.
+func (s *AppService) ReportDeployment(ctx context.Context, p ReportParams) (domain.Deployment, error) {
+	if err := validateWorkerCall(p.DeploymentID, p.WorkerID); err != nil {
+		return domain.Deployment{}, err
+	}
+	dep, err := s.claimedBy(ctx, p.DeploymentID, p.WorkerID)
+	if errors.Is(err, ErrLeaseLost) {
+		return s.claimGone(ctx, domain.Deployment{ID: p.DeploymentID})
+	}
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	if err := s.appendWorkerLogs(ctx, dep.ID, p.Progress.Logs); err != nil {
+		return domain.Deployment{}, err
+	}
+
+	if p.Interrupted {
+		requeued, err := s.requeueClaimed(ctx, dep)
+		if errors.Is(err, contracts.ErrVersionMismatch) || errors.Is(err, contracts.ErrConflict) || errors.Is(err, contracts.ErrNotFound) {
+			return s.claimGone(ctx, dep)
+		}
+		return requeued, err
+	}
+
+	applyProgress(&dep, p.Progress)
+	if p.Error != "" {
+		err = dep.Fail(p.Error)
+	} else {
+		err = dep.Succeed(p.URL)
+	}
+	if err != nil {
+		return domain.Deployment{}, fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	done, err := s.updateClaimed(ctx, dep)
+	if errors.Is(err, ErrLeaseLost) {
+		return s.claimGone(ctx, dep)
+	}
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	s.deploymentChanged(ctx, done)
+	return done, nil
+}
+
+// validateWorkerCall checks the ids every heartbeat and report carries.
+func validateWorkerCall(deploymentID, workerID string) error {
+	if deploymentID == "" || workerID == "" {
+		return fmt.Errorf("%w: deployment id and worker id are required", ErrInvalidInput)
+	}
+	return nil
+}
+
+// claimedBy loads a deployment that workerID still holds a claim on.
+func (s *AppService) claimedBy(ctx context.Context, deploymentID, workerID string) (domain.Deployment, error) {
+	dep, err := s.getDeployment(ctx, deploymentID)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	if !dep.Status.InProgress() || dep.ClaimedBy == nil || *dep.ClaimedBy != workerID {
+		return domain.Deployment{}, ErrLeaseLost
+	}
+	return dep, nil
+}
+
+// appendWorkerLogs adds a worker's build log lines to its deployment.
+func (s *AppService) appendWorkerLogs(ctx context.Context, deploymentID string, lines []domain.LogEntry) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	err := s.store.AppendDeploymentLogs(ctx, deploymentID, lines)
+	if errors.Is(err, contracts.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// applyProgress replays a worker's step reports and resolved port onto dep.
+func applyProgress(dep *domain.Deployment, p WorkerProgress) {
+	if p.Port != nil {
+		dep.RuntimePort = p.Port
+	}
+	for _, e := range p.Steps {
+		switch {
+		case !e.Finished:
+			dep.StartStep(e.Step)
+		case e.Error != "":
+			dep.FinishStep(e.Step, errors.New(e.Error))
+		default:
+			dep.FinishStep(e.Step, nil)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Tests for workers that run deploys in another process
+// Tests verify a claim, heartbeats and a report take a deployment to RUNNING with its steps and logs
+// Tests verify only the worker holding a claim may heartbeat or report on it
+// Tests verify a canceled deployment refuses heartbeats and reports return the canceled record
+// Tests verify an interrupted report puts the deployment back in the queue
+
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// claimOne queues a deploy of a new app and claims it as worker-1.
+func claimOne(t *testing.T, svc *service.AppService) service.DeploymentClaim {
+	t.Helper()
+	ctx := context.Background()
+	app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest", Env: map[string]string{"A": "1"}})
+	require.NoError(t, err)
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	claim, err := svc.ClaimDeployment(ctx, "worker-1")
+	require.NoError(t, err)
+	return claim
+}
+
+// TestRemoteWorker verifies a claimed deploy is tracked through heartbeats and finished by its report.
+func TestRemoteWorker(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	svc := service.NewAppService(st, service.WithLease(time.Minute))
+
+	_, err := svc.ClaimDeployment(ctx, "worker-1")
+	assert.ErrorIs(t, err, service.ErrNoWork)
+	_, err = svc.ClaimDeployment(ctx, "")
+	assert.ErrorIs(t, err, service.ErrInvalidInput)
+
+	claim := claimOne(t, svc)
+	assert.Equal(t, domain.DeploymentStatusDeploying, claim.Deployment.Status)
+	assert.Equal(t, "worker-1", *claim.Deployment.ClaimedBy)
+	assert.Equal(t, map[string]string{"A": "1"}, claim.App.Env)
+	assert.Equal(t, time.Minute, claim.Lease)
+
+	line := domain.LogEntry{Timestamp: time.Now().UTC(), Level: domain.LogLevelInfo, Message: "pulling", Source: "pull"}
+	err = svc.HeartbeatDeployment(ctx, service.HeartbeatParams{
+		DeploymentID: claim.Deployment.ID,
+		WorkerID:     "worker-1",
+		Progress: service.WorkerProgress{
+			Steps: []service.StepEvent{{Step: domain.StepPullImage}},
+			Logs:  []domain.LogEntry{line},
+		},
+	})
+	require.NoError(t, err)
+	dep, err := st.GetDeploymentByID(ctx, claim.Deployment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StepStatusRunning, dep.Steps[0].Status)
+	assert.False(t, dep.LeaseExpiresAt.Before(*claim.Deployment.LeaseExpiresAt))
+
+	url := "https://hello.example.com"
+	done, err := svc.ReportDeployment(ctx, service.ReportParams{
+		DeploymentID: claim.Deployment.ID,
+		WorkerID:     "worker-1",
+		Progress: service.WorkerProgress{Steps: []service.StepEvent{
+			{Step: domain.StepPullImage, Finished: true},
+			{Step: domain.StepResolvePort},
+			{Step: domain.StepResolvePort, Finished: true},
+		}},
+		URL: &url,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusRunning, done.Status)
+	assert.Equal(t, url, *done.URL)
+	assert.Equal(t, domain.StepStatusCompleted, done.Steps[0].Status)
+	assert.Equal(t, domain.StepStatusCompleted, done.Steps[1].Status)
+
+	logs, err := st.ListDeploymentLogs(ctx, claim.Deployment.ID, contracts.Page{Limit: 10})
+	require.NoError(t, err)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, "pulling", logs[0].Message)
+	}
+
+	// The claim ended with the report
+	_, err = svc.ReportDeployment(ctx, service.ReportParams{DeploymentID: claim.Deployment.ID, WorkerID: "worker-1"})
+	assert.ErrorIs(t, err, service.ErrLeaseLost)
+}
+
+// TestRemoteWorker_Failure verifies a reported error fails the deployment and the failed step.
+func TestRemoteWorker_Failure(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewAppService(store.NewMemoryStore())
+	claim := claimOne(t, svc)
+
+	done, err := svc.ReportDeployment(ctx, service.ReportParams{
+		DeploymentID: claim.Deployment.ID,
+		WorkerID:     "worker-1",
+		Progress: service.WorkerProgress{Steps: []service.StepEvent{
+			{Step: domain.StepPullImage},
+			{Step: domain.StepPullImage, Finished: true, Error: "manifest unknown"},
+		}},
+		Error: "pull image: manifest unknown",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusFailed, done.Status)
+	assert.Equal(t, "pull image: manifest unknown", *done.Error)
+	assert.Equal(t, domain.StepStatusFailed, done.Steps[0].Status)
+}
+
+// TestRemoteWorker_WrongWorker verifies another worker cannot renew or finish a claim.
+func TestRemoteWorker_WrongWorker(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewAppService(store.NewMemoryStore())
+	claim := claimOne(t, svc)
+
+	err := svc.HeartbeatDeployment(ctx, service.HeartbeatParams{DeploymentID: claim.Deployment.ID, WorkerID: "worker-2"})
+	assert.ErrorIs(t, err, service.ErrLeaseLost)
+	_, err = svc.ReportDeployment(ctx, service.ReportParams{DeploymentID: claim.Deployment.ID, WorkerID: "worker-2"})
+	assert.ErrorIs(t, err, service.ErrLeaseLost)
+
+	err = svc.HeartbeatDeployment(ctx, service.HeartbeatParams{DeploymentID: "missing", WorkerID: "worker-1"})
+	assert.ErrorIs(t, err, service.ErrNotFound)
+	err = svc.HeartbeatDeployment(ctx, service.HeartbeatParams{DeploymentID: claim.Deployment.ID})
+	assert.ErrorIs(t, err, service.ErrInvalidInput)
+}
+
+// TestRemoteWorker_Canceled verifies a cancel stops heartbeats and the late report gets the canceled record.
+func TestRemoteWorker_Canceled(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewAppService(store.NewMemoryStore())
+	claim := claimOne(t, svc)
+
+	_, err := svc.CancelDeployment(ctx, service.CancelDeploymentParams{DeploymentID: claim.Deployment.ID})
+	require.NoError(t, err)
+
+	err = svc.HeartbeatDeployment(ctx, service.HeartbeatParams{DeploymentID: claim.Deployment.ID, WorkerID: "worker-1"})
+	assert.ErrorIs(t, err, service.ErrLeaseLost)
+	dep, err := svc.ReportDeployment(ctx, service.ReportParams{DeploymentID: claim.Deployment.ID, WorkerID: "worker-1"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusCanceled, dep.Status)
+}
+
+// TestRemoteWorker_Interrupted verifies an interrupted report hands the deployment to the next claim.
+func TestRemoteWorker_Interrupted(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewAppService(store.NewMemoryStore())
+	claim := claimOne(t, svc)
+
+	dep, err := svc.ReportDeployment(ctx, service.ReportParams{
+		DeploymentID: claim.Deployment.ID,
+		WorkerID:     "worker-1",
+		Interrupted:  true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusQueued, dep.Status)
+	assert.Nil(t, dep.ClaimedBy)
+
+	again, err := svc.ClaimDeployment(ctx, "worker-2")
+	require.NoError(t, err)
+	assert.Equal(t, claim.Deployment.ID, again.Deployment.ID)
+	assert.Equal(t, "worker-2", *again.Deployment.ClaimedBy)
+}
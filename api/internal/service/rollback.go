@@ -0,0 +1,91 @@
+// Service logic for rolling an app back to an earlier deployment
+// A rollback queues a new deployment that reuses an earlier deployment's frozen spec
+// The target must have reached RUNNING; by default it is the newest one that did
+// The new deployment records its target and runs through the normal queue
+// The app's own config is left alone, so the next plain deploy rolls forward again
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// RollbackAppParams identifies the app to roll back and, optionally, the deployment to return to
+// An empty DeploymentID picks the newest successful deployment before the app's latest one
+// IfVersion and Force behave as they do for DeployApp
+type RollbackAppParams struct {
+	AppID        string
+	DeploymentID string
+	IfVersion    int64
+	Force        bool
+}
+
+// RollbackApp queues a deployment that redeploys an earlier successful deployment's spec.
+func (s *AppService) RollbackApp(ctx context.Context, p RollbackAppParams) (domain.Deployment, error) {
+	app, err := s.deployableApp(ctx, p.AppID, p.IfVersion, p.Force)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+
+	var target domain.Deployment
+	if p.DeploymentID != "" {
+		target, err = s.rollbackTarget(ctx, app.ID, p.DeploymentID)
+	} else {
+		target, err = s.lastGoodDeployment(ctx, app.ID)
+	}
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+
+	dep := domain.NewDeployment(app.ID)
+	dep.Spec = target.Spec
+	dep.RollbackOf = &target.ID
+	return s.enqueue(ctx, dep)
+}
+
+// rollbackTarget loads a deployment of the app that can be rolled back to.
+func (s *AppService) rollbackTarget(ctx context.Context, appID, depID string) (domain.Deployment, error) {
+	dep, err := s.store.GetDeploymentByID(ctx, depID)
+	if errors.Is(err, contracts.ErrNotFound) || err == nil && dep.AppID != appID {
+		return domain.Deployment{}, fmt.Errorf("%w: app has no deployment %s", ErrNotFound, depID)
+	}
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	if dep.Status != domain.DeploymentStatusRunning {
+		return domain.Deployment{}, fmt.Errorf("%w: deployment %s is %s; only deployments that ran can be rolled back to",
+			ErrConflict, depID, dep.Status)
+	}
+	return dep, nil
+}
+
+// lastGoodDeployment returns the newest RUNNING deployment of the app that is not its latest deployment.
+// Skipping the latest means a bad deploy that still came up is rolled back past, not redeployed.
+func (s *AppService) lastGoodDeployment(ctx context.Context, appID string) (domain.Deployment, error) {
+	latest, err := s.LatestDeployment(ctx, appID)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	filter := contracts.DeploymentFilter{Status: domain.DeploymentStatusRunning, Page: contracts.Page{Limit: 1}}
+	_, total, err := s.store.ListDeploymentsByAppID(ctx, appID, filter)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	// Only the newest two can be the answer; the list is in create order
+	filter.Page = contracts.Page{Limit: 2, Offset: max(total-2, 0)}
+	good, _, err := s.store.ListDeploymentsByAppID(ctx, appID, filter)
+	if err != nil {
+		return domain.Deployment{}, err
+	}
+	for i := len(good) - 1; i >= 0; i-- {
+		if latest == nil || good[i].ID != latest.ID {
+			return good[i], nil
+		}
+	}
+	return domain.Deployment{}, fmt.Errorf("%w: app has no earlier successful deployment to roll back to", ErrConflict)
+}
@@ -0,0 +1,120 @@
+// Tests for rolling apps back
+// Tests verify the default target skips the latest deployment and failed ones
+// Tests verify a rollback redeploys the target's spec and links back to it
+// Tests cover explicit targets that never ran or belong to another app
+// These tests keep rollbacks on the normal deploy queue
+
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// deployImage points the app at image and runs one deployment of it.
+func deployImage(t *testing.T, svc *service.AppService, rt *fakeRuntime, appID, image string, fail bool) domain.Deployment {
+	t.Helper()
+	ctx := context.Background()
+	_, err := svc.UpdateApp(ctx, service.UpdateAppParams{AppID: appID, Image: &image})
+	require.NoError(t, err)
+	_, err = svc.DeployApp(ctx, service.DeployAppParams{AppID: appID})
+	require.NoError(t, err)
+	rt.err = nil
+	if fail {
+		rt.err = errors.New("image crashed")
+	}
+	dep, _ := svc.ProcessNextDeployment(ctx)
+	rt.err = nil
+	return dep
+}
+
+// TestRollbackApp verifies the default and explicit targets and what the rollback deploys.
+func TestRollbackApp(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &fakeRuntime{}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+
+	app := runningApp(t, svc)
+	first, err := svc.LatestDeployment(ctx, app.ID)
+	require.NoError(t, err)
+	second := deployImage(t, svc, rt, app.ID, "nginx:1.27", false)
+	broken := deployImage(t, svc, rt, app.ID, "nginx:broken", true)
+	require.Equal(t, domain.DeploymentStatusFailed, broken.Status)
+
+	// A failed deploy rolls back to the newest deployment that ran.
+	dep, err := svc.RollbackApp(ctx, service.RollbackAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusQueued, dep.Status)
+	assert.Equal(t, second.ID, *dep.RollbackOf)
+	assert.Equal(t, "nginx:1.27", dep.Spec.Image)
+	done, err := svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusRunning, done.Status)
+	assert.Equal(t, dep.ID, done.ID)
+
+	// The app keeps its own config.
+	got, err := svc.GetAppByID(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "nginx:broken", got.Image)
+
+	// A running latest deployment is skipped, so rolling back again goes further back.
+	dep, err = svc.RollbackApp(ctx, service.RollbackAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, *dep.RollbackOf)
+	_, err = svc.ProcessNextDeployment(ctx)
+	require.NoError(t, err)
+	dep, err = svc.RollbackApp(ctx, service.RollbackAppParams{AppID: app.ID, DeploymentID: first.ID})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, *dep.RollbackOf)
+	assert.Equal(t, "nginx:1.26", dep.Spec.Image)
+}
+
+// TestRollbackApp_Errors verifies rollbacks that have nothing valid to go back to.
+func TestRollbackApp_Errors(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	rt := &fakeRuntime{}
+	svc := service.NewAppServiceWithRuntime(st, rt)
+
+	app := runningApp(t, svc)
+	broken := deployImage(t, svc, rt, app.ID, "nginx:broken", true)
+	other, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "other", Image: "nginx:latest"})
+	require.NoError(t, err)
+	otherDep, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: other.ID})
+	require.NoError(t, err)
+
+	tests := []struct {
+		label string
+		p     service.RollbackAppParams
+		err   error
+	}{
+		{"empty app id", service.RollbackAppParams{}, service.ErrInvalidInput},
+		{"missing app", service.RollbackAppParams{AppID: "missing"}, service.ErrNotFound},
+		{"stale version", service.RollbackAppParams{AppID: app.ID, IfVersion: 99}, service.ErrVersionMismatch},
+		{"target never ran", service.RollbackAppParams{AppID: app.ID, DeploymentID: broken.ID}, service.ErrConflict},
+		{"target of another app", service.RollbackAppParams{AppID: app.ID, DeploymentID: otherDep.ID}, service.ErrNotFound},
+		{"missing target", service.RollbackAppParams{AppID: app.ID, DeploymentID: "missing"}, service.ErrNotFound},
+		{"nothing earlier", service.RollbackAppParams{AppID: other.ID}, service.ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			_, err := svc.RollbackApp(ctx, tt.p)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	// An app whose only good deployment is its latest has nothing to roll back to.
+	fresh := store.NewMemoryStore()
+	svc = service.NewAppServiceWithRuntime(fresh, &fakeRuntime{})
+	app = runningApp(t, svc)
+	_, err = svc.RollbackApp(ctx, service.RollbackAppParams{AppID: app.ID})
+	assert.ErrorIs(t, err, service.ErrConflict)
+}
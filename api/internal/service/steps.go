@@ -0,0 +1,95 @@
+// Deploy step progress and build logs recorded on the deployment
+// The runtime reports each step as it starts and finishes
+// Every report is written so readers see which step is running
+// Build log lines are appended in batches, and at least once a second while they come in
+// Writes are best effort, except the start of the switch: a deploy whose switch is not recorded is stopped
+
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// Build log batching limits.
+const (
+	logBatchLines = 50
+	logBatchWait  = time.Second
+)
+
+// stepRecorder stores the runtime's step reports and build log on the claimed deployment it points at.
+type stepRecorder struct {
+	s   *AppService
+	ctx context.Context
+	dep *domain.Deployment
+	// abort stops the deploy
+	abort context.CancelFunc
+
+	// build log lines not written yet and when lines were last written
+	lines   []domain.LogEntry
+	flushed time.Time
+}
+
+// newStepRecorder returns a recorder writing to dep, which must be claimed by this worker.
+// abort is called when the switch to the new container cannot be recorded.
+func (s *AppService) newStepRecorder(ctx context.Context, dep *domain.Deployment, abort context.CancelFunc) *stepRecorder {
+	return &stepRecorder{s: s, ctx: ctx, dep: dep, abort: abort, flushed: time.Now()}
+}
+
+// StepStarted records that the runtime began a step.
+// Once the switch is recorded a cancel is refused; if a cancel got in first the deploy is stopped
+// before the runtime retires the old container.
+func (r *stepRecorder) StepStarted(step domain.StepID) {
+	r.flush()
+	r.dep.StartStep(step)
+	if err := r.save(); err != nil && step == domain.StepReplaceContainer {
+		r.abort()
+	}
+}
+
+// StepFinished records how a step ended.
+func (r *stepRecorder) StepFinished(step domain.StepID, err error) {
+	r.flush()
+	r.dep.FinishStep(step, err)
+	r.save()
+}
+
+// Log queues a build log line and writes the queue once it is big or old enough.
+func (r *stepRecorder) Log(line domain.LogEntry) {
+	r.lines = append(r.lines, line)
+	if len(r.lines) >= logBatchLines || time.Since(r.flushed) >= logBatchWait {
+		r.flush()
+	}
+}
+
+// PortResolved keeps the port on the deployment; it is written along with the step that resolved it.
+func (r *stepRecorder) PortResolved(port int) {
+	r.dep.RuntimePort = &port
+}
+
+// flush writes the queued build log lines. Lines that fail to write are dropped.
+func (r *stepRecorder) flush() {
+	r.flushed = time.Now()
+	if len(r.lines) == 0 {
+		return
+	}
+	if err := r.s.store.AppendDeploymentLogs(r.ctx, r.dep.ID, r.lines); err != nil {
+		log.Printf("record deployment %s build log: %v", r.dep.ID, err)
+	}
+	r.lines = nil
+}
+
+// save writes the deployment and keeps the stored version so the next write applies.
+// On failure the local steps are kept and go out with the next write.
+func (r *stepRecorder) save() error {
+	updated, err := r.s.updateClaimed(r.ctx, *r.dep)
+	if err != nil {
+		log.Printf("record deployment %s steps: %v", r.dep.ID, err)
+		return err
+	}
+	*r.dep = updated
+	return nil
+}
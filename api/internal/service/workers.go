@@ -0,0 +1,120 @@
+// In-process deployment workers
+// A pool of workers claims queued deployments and runs them without an HTTP call
+// An empty queue or a failing store backs workers off, up to a limit
+// Stopping the pool stops new claims at once and gives in-flight deploys time to finish
+// A deploy still running when that time is up is interrupted and requeued for another worker
+
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// WorkerPoolConfig tunes RunWorkers
+// Zero values take the defaults below
+type WorkerPoolConfig struct {
+	// Concurrency is how many deployments run at once
+	Concurrency int
+	// IdleBackoff is the first wait after the queue is found empty; it doubles up to MaxBackoff
+	IdleBackoff time.Duration
+	MaxBackoff  time.Duration
+	// DrainTimeout is how long in-flight deploys may finish once the pool is stopped
+	DrainTimeout time.Duration
+}
+
+// Worker pool defaults.
+const (
+	defaultWorkerConcurrency  = 1
+	defaultWorkerIdleBackoff  = 500 * time.Millisecond
+	defaultWorkerMaxBackoff   = 10 * time.Second
+	defaultWorkerDrainTimeout = 8 * time.Second
+)
+
+// requeueTimeout bounds handing an interrupted deployment back to the queue during shutdown.
+const requeueTimeout = 5 * time.Second
+
+// RunWorkers processes queued deployments until ctx is done and returns once every worker has stopped.
+func (s *AppService) RunWorkers(ctx context.Context, cfg WorkerPoolConfig) {
+	cfg = cfg.withDefaults()
+
+	// Deploys outlive ctx by up to the drain timeout so a shutdown does not cut them short
+	deployCtx, interrupt := context.WithCancel(context.WithoutCancel(ctx))
+	defer interrupt()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-deployCtx.Done():
+			return
+		}
+		timer := time.NewTimer(cfg.DrainTimeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			interrupt()
+		case <-deployCtx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runWorker(ctx, deployCtx, i+1, cfg)
+		}()
+	}
+	wg.Wait()
+}
+
+// runWorker claims and runs deployments one at a time until ctx is done.
+// deployCtx is what the deploys run under; it ends after ctx does.
+func (s *AppService) runWorker(ctx, deployCtx context.Context, n int, cfg WorkerPoolConfig) {
+	var backoff time.Duration
+	for ctx.Err() == nil {
+		dep, err := s.ProcessNextDeployment(deployCtx)
+		switch {
+		case err == nil, dep.ID != "":
+			// A deployment was run; a failed one already has its outcome recorded
+			if err != nil {
+				log.Printf("worker %d: deployment %s: %v", n, dep.ID, err)
+			}
+			backoff = 0
+			continue
+		case errors.Is(err, ErrNoWork):
+		default:
+			log.Printf("worker %d: %v", n, err)
+		}
+
+		backoff = min(max(backoff*2, cfg.IdleBackoff), cfg.MaxBackoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// withDefaults fills unset fields.
+func (c WorkerPoolConfig) withDefaults() WorkerPoolConfig {
+	if c.Concurrency <= 0 {
+		c.Concurrency = defaultWorkerConcurrency
+	}
+	if c.IdleBackoff <= 0 {
+		c.IdleBackoff = defaultWorkerIdleBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultWorkerMaxBackoff
+	}
+	if c.MaxBackoff < c.IdleBackoff {
+		c.MaxBackoff = c.IdleBackoff
+	}
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = defaultWorkerDrainTimeout
+	}
+	return c
+}
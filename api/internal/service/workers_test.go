@@ -0,0 +1,189 @@
+// Tests for the in-process deployment worker pool
+// Tests verify workers run queued deployments up to the configured concurrency
+// Tests verify an empty queue backs workers off instead of spinning
+// Tests verify stopping the pool lets in-flight deploys finish within the drain timeout
+// Tests verify deploys still running after it are requeued rather than failed
+
+package service_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/service"
+)
+
+// gatedRuntime holds every deploy until it is released or its context ends.
+type gatedRuntime struct {
+	noopLifecycle
+	started chan string
+	release chan struct{}
+}
+
+// newGatedRuntime returns a runtime whose deploys wait for release to be closed.
+func newGatedRuntime() *gatedRuntime {
+	return &gatedRuntime{started: make(chan string, 16), release: make(chan struct{})}
+}
+
+// Deploy reports the app as started and waits to be released.
+func (r *gatedRuntime) Deploy(ctx context.Context, app domain.App, _ contracts.DeployProgress) (*string, error) {
+	r.started <- app.ID
+	select {
+	case <-r.release:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// countingStore counts queue claims.
+type countingStore struct {
+	contracts.Store
+	claims atomic.Int64
+}
+
+// TakeNextQueuedDeployment counts the call and claims from the wrapped store.
+func (s *countingStore) TakeNextQueuedDeployment(ctx context.Context, workerID string, lease time.Duration) (domain.Deployment, error) {
+	s.claims.Add(1)
+	return s.Store.TakeNextQueuedDeployment(ctx, workerID, lease)
+}
+
+// queueApps creates n apps with one queued deployment each.
+func queueApps(t *testing.T, svc *service.AppService, n int) []domain.Deployment {
+	t.Helper()
+	ctx := context.Background()
+	deps := make([]domain.Deployment, 0, n)
+	for i := range n {
+		app, err := svc.CreateApp(ctx, service.CreateAppParams{Name: "app-" + string(rune('a'+i)), Image: "nginx:latest"})
+		require.NoError(t, err)
+		dep, err := svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+		require.NoError(t, err)
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+// startWorkers runs the pool in the background and returns a channel closed when it stops.
+func startWorkers(ctx context.Context, svc *service.AppService, cfg service.WorkerPoolConfig) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.RunWorkers(ctx, cfg)
+	}()
+	return done
+}
+
+// waitStatus waits for a deployment to reach want.
+func waitStatus(t *testing.T, st contracts.Store, id string, want domain.DeploymentStatus) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		dep, err := st.GetDeploymentByID(context.Background(), id)
+		return err == nil && dep.Status == want
+	}, 2*time.Second, 5*time.Millisecond, "deployment %s never became %s", id, want)
+}
+
+// TestRunWorkers verifies the pool runs no more deploys at once than its concurrency.
+func TestRunWorkers(t *testing.T) {
+	st := store.NewMemoryStore()
+	rt := newGatedRuntime()
+	svc := service.NewAppServiceWithRuntime(st, rt)
+	deps := queueApps(t, svc, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startWorkers(ctx, svc, service.WorkerPoolConfig{Concurrency: 2, IdleBackoff: time.Millisecond})
+
+	<-rt.started
+	<-rt.started
+	select {
+	case id := <-rt.started:
+		t.Fatalf("third deploy %s started while two were running", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(rt.release)
+	for _, dep := range deps {
+		waitStatus(t, st, dep.ID, domain.DeploymentStatusRunning)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("workers did not stop")
+	}
+}
+
+// TestRunWorkers_Backoff verifies idle workers poll an empty queue less and less often.
+func TestRunWorkers_Backoff(t *testing.T) {
+	st := &countingStore{Store: store.NewMemoryStore()}
+	svc := service.NewAppServiceWithRuntime(st, newGatedRuntime())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	<-startWorkers(ctx, svc, service.WorkerPoolConfig{
+		Concurrency: 2,
+		IdleBackoff: 5 * time.Millisecond,
+		MaxBackoff:  40 * time.Millisecond,
+	})
+
+	// Polling every IdleBackoff would be about 80 claims; backing off to 40ms keeps it near 20
+	claims := st.claims.Load()
+	assert.GreaterOrEqual(t, claims, int64(4))
+	assert.Less(t, claims, int64(40))
+}
+
+// TestRunWorkers_Drain verifies a deploy in flight when the pool stops still finishes.
+func TestRunWorkers_Drain(t *testing.T) {
+	st := store.NewMemoryStore()
+	rt := newGatedRuntime()
+	svc := service.NewAppServiceWithRuntime(st, rt)
+	deps := queueApps(t, svc, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startWorkers(ctx, svc, service.WorkerPoolConfig{IdleBackoff: time.Millisecond, DrainTimeout: time.Second})
+	<-rt.started
+	cancel()
+	close(rt.release)
+	<-done
+
+	// The first deploy finished and nothing new was claimed after the stop
+	waitStatus(t, st, deps[0].ID, domain.DeploymentStatusRunning)
+	second, err := st.GetDeploymentByID(context.Background(), deps[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusQueued, second.Status)
+}
+
+// TestRunWorkers_Interrupt verifies a deploy still running after the drain timeout is requeued.
+func TestRunWorkers_Interrupt(t *testing.T) {
+	st := store.NewMemoryStore()
+	rt := newGatedRuntime()
+	svc := service.NewAppServiceWithRuntime(st, rt)
+	deps := queueApps(t, svc, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startWorkers(ctx, svc, service.WorkerPoolConfig{DrainTimeout: 20 * time.Millisecond})
+	<-rt.started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("workers did not stop after the drain timeout")
+	}
+
+	got, err := st.GetDeploymentByID(context.Background(), deps[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeploymentStatusQueued, got.Status)
+	assert.Nil(t, got.ClaimedBy)
+	assert.Nil(t, got.Error)
+
+	// Another worker picks it up again
+	next, err := st.TakeNextQueuedDeployment(context.Background(), "worker-2", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, deps[0].ID, next.ID)
+}
@@ -0,0 +1,216 @@
+// Package worker runs deploys on a host away from the API.
+// Client speaks the API's worker protocol: claim, heartbeat and report.
+// Every call carries the shared worker token in X-Worker-Token.
+// A 409 from a heartbeat or report means the claim is gone.
+// Other non-2xx responses come back as errors with the API's message.
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// ErrClaimLost means the API no longer holds the deployment for this worker, usually because it was canceled.
+var ErrClaimLost = errors.New("worker: claim lost")
+
+// clientTimeout bounds every API call.
+const clientTimeout = 30 * time.Second
+
+// Client calls the API's worker endpoints.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a client for the API at baseURL, such as "http://api:8080".
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: clientTimeout},
+	}
+}
+
+// Claim is a deployment claimed for this worker and the app to deploy for it.
+type Claim struct {
+	DeploymentID string
+	App          domain.App
+	Lease        time.Duration
+}
+
+// StepEvent is one step report, in the order the runtime made them.
+type StepEvent struct {
+	Step     domain.StepID `json:"step"`
+	Finished bool          `json:"finished"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Progress is what the runtime reported since the last call.
+// Port is set once the runtime has resolved the port the app listens on.
+type Progress struct {
+	Steps []StepEvent
+	Logs  []domain.LogEntry
+	Port  *int
+}
+
+// Result is how a deploy ended. Interrupted hands the deployment back to the queue.
+type Result struct {
+	URL         *string
+	Error       string
+	Interrupted bool
+}
+
+// claimResp is the body of a successful claim.
+type claimResp struct {
+	Deployment struct {
+		ID string `json:"id"`
+	} `json:"deployment"`
+	App struct {
+		ID          string            `json:"id"`
+		Name        string            `json:"name"`
+		Image       string            `json:"image"`
+		Port        *int              `json:"port"`
+		Expose      bool              `json:"expose"`
+		Env         map[string]string `json:"env"`
+		HealthCheck *struct {
+			Type            domain.HealthCheckType `json:"type"`
+			Path            string                 `json:"path"`
+			Command         []string               `json:"command"`
+			IntervalSeconds int                    `json:"intervalSeconds"`
+			TimeoutSeconds  int                    `json:"timeoutSeconds"`
+			Retries         int                    `json:"retries"`
+		} `json:"healthCheck"`
+	} `json:"app"`
+	LeaseSeconds int `json:"leaseSeconds"`
+}
+
+// logLine is a build log line as the API takes it.
+type logLine struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Level     domain.LogLevel `json:"level"`
+	Message   string          `json:"message"`
+	Source    string          `json:"source,omitempty"`
+}
+
+// heartbeatReq is the body of a heartbeat.
+type heartbeatReq struct {
+	WorkerID string      `json:"workerId"`
+	Steps    []StepEvent `json:"steps,omitempty"`
+	Logs     []logLine   `json:"logs,omitempty"`
+	Port     *int        `json:"port,omitempty"`
+}
+
+// reportReq is the body of a report.
+type reportReq struct {
+	heartbeatReq
+	URL         *string `json:"url,omitempty"`
+	Error       string  `json:"error,omitempty"`
+	Interrupted bool    `json:"interrupted,omitempty"`
+}
+
+// Claim claims the next queued deployment, or returns nil when the queue is empty.
+func (c *Client) Claim(ctx context.Context, workerID string) (*Claim, error) {
+	var resp claimResp
+	status, err := c.post(ctx, "/v0/deployments/next:claim", map[string]string{"workerId": workerID}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNoContent {
+		return nil, nil
+	}
+
+	a := resp.App
+	app := domain.App{ID: a.ID, Name: a.Name, Image: a.Image, Port: a.Port, Expose: a.Expose, Env: a.Env}
+	if hc := a.HealthCheck; hc != nil {
+		app.HealthCheck = &domain.HealthCheck{
+			Type:     hc.Type,
+			Path:     hc.Path,
+			Command:  hc.Command,
+			Interval: time.Duration(hc.IntervalSeconds) * time.Second,
+			Timeout:  time.Duration(hc.TimeoutSeconds) * time.Second,
+			Retries:  hc.Retries,
+		}
+	}
+	return &Claim{
+		DeploymentID: resp.Deployment.ID,
+		App:          app,
+		Lease:        time.Duration(resp.LeaseSeconds) * time.Second,
+	}, nil
+}
+
+// Heartbeat renews the claim on a deployment and sends the progress made since the last call.
+func (c *Client) Heartbeat(ctx context.Context, deploymentID, workerID string, p Progress) error {
+	_, err := c.post(ctx, "/v0/deployments/"+url.PathEscape(deploymentID)+"/heartbeat", newHeartbeatReq(workerID, p), nil)
+	return err
+}
+
+// Report sends how a deploy ended along with the last of its progress.
+func (c *Client) Report(ctx context.Context, deploymentID, workerID string, p Progress, res Result) error {
+	body := reportReq{
+		heartbeatReq: newHeartbeatReq(workerID, p),
+		URL:          res.URL,
+		Error:        res.Error,
+		Interrupted:  res.Interrupted,
+	}
+	_, err := c.post(ctx, "/v0/deployments/"+url.PathEscape(deploymentID)+"/report", body, nil)
+	return err
+}
+
+// newHeartbeatReq maps progress to the request body.
+func newHeartbeatReq(workerID string, p Progress) heartbeatReq {
+	req := heartbeatReq{WorkerID: workerID, Steps: p.Steps, Port: p.Port}
+	for _, l := range p.Logs {
+		req.Logs = append(req.Logs, logLine(l))
+	}
+	return req
+}
+
+// post sends body as JSON and decodes a 200 response into out when it is set.
+// It returns the response status, ErrClaimLost for a 409 and an error for anything else outside 2xx.
+func (c *Client) post(ctx context.Context, path string, body, out any) (int, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("X-Worker-Token", c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("worker: %s: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusConflict:
+		return res.StatusCode, ErrClaimLost
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(res.Body, 4096)).Decode(&e)
+		return res.StatusCode, fmt.Errorf("worker: %s: %s: %s", path, res.Status, e.Error)
+	case res.StatusCode == http.StatusOK && out != nil:
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return res.StatusCode, fmt.Errorf("worker: %s: decode: %w", path, err)
+		}
+	}
+	return res.StatusCode, nil
+}
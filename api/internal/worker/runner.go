@@ -0,0 +1,371 @@
+// Worker loop for deploys claimed from the API
+// Each slot claims a deployment, runs it on the local runtime and reports how it ended
+// Heartbeats keep the claim alive and carry the steps and build log reported so far
+// A heartbeat refused because the claim is gone, usually a cancel, stops the deploy
+// The switch to the new container waits for the API to record it, after which a cancel is refused
+// Stopping the runner gives in-flight deploys time to finish, then hands them back to the queue
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+)
+
+// Config tunes a Runner
+// Zero values take the defaults below
+type Config struct {
+	// Concurrency is how many deployments run at once
+	Concurrency int
+	// IdleBackoff is the first wait after the queue is found empty; it doubles up to MaxBackoff
+	IdleBackoff time.Duration
+	MaxBackoff  time.Duration
+	// DrainTimeout is how long in-flight deploys may finish once the runner is stopped
+	DrainTimeout time.Duration
+	// HeartbeatInterval is how often a claim is renewed; zero uses a third of the claim's lease
+	HeartbeatInterval time.Duration
+}
+
+// Runner defaults.
+const (
+	defaultConcurrency  = 1
+	defaultIdleBackoff  = 500 * time.Millisecond
+	defaultMaxBackoff   = 10 * time.Second
+	defaultDrainTimeout = 8 * time.Second
+)
+
+// Reporting limits.
+const (
+	// logBatchLines is how many queued build log lines send a heartbeat early
+	logBatchLines = 50
+	// reportAttempts is how many times a result is sent before the lease is left to expire
+	reportAttempts  = 3
+	reportRetryWait = time.Second
+	// reportTimeout bounds sending a result once the deploy is over, including during shutdown
+	reportTimeout = 15 * time.Second
+	// minHeartbeatInterval keeps a missing or tiny lease from heartbeating in a tight loop
+	minHeartbeatInterval = 100 * time.Millisecond
+)
+
+// Runner claims deployments from the API and runs them on a local runtime.
+type Runner struct {
+	client   *Client
+	runtime  contracts.Runtime
+	workerID string
+	cfg      Config
+}
+
+// NewRunner returns a runner that claims work as workerID and deploys it with rt.
+func NewRunner(client *Client, rt contracts.Runtime, workerID string, cfg Config) *Runner {
+	return &Runner{client: client, runtime: rt, workerID: workerID, cfg: cfg.withDefaults()}
+}
+
+// Run claims and runs deployments until ctx is done and returns once every slot has stopped.
+func (r *Runner) Run(ctx context.Context) {
+	// Deploys outlive ctx by up to the drain timeout so a shutdown does not cut them short
+	deployCtx, interrupt := context.WithCancel(context.WithoutCancel(ctx))
+	defer interrupt()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-deployCtx.Done():
+			return
+		}
+		timer := time.NewTimer(r.cfg.DrainTimeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			interrupt()
+		case <-deployCtx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := range r.cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.loop(ctx, deployCtx, i+1)
+		}()
+	}
+	wg.Wait()
+}
+
+// loop claims and runs deployments one at a time until ctx is done.
+// deployCtx is what the deploys run under; it ends after ctx does.
+func (r *Runner) loop(ctx, deployCtx context.Context, n int) {
+	var backoff time.Duration
+	for ctx.Err() == nil {
+		claim, err := r.client.Claim(ctx, r.workerID)
+		switch {
+		case claim != nil:
+			r.deploy(deployCtx, n, claim)
+			backoff = 0
+			continue
+		case err != nil && ctx.Err() == nil:
+			log.Printf("worker %d: %v", n, err)
+		}
+
+		backoff = min(max(backoff*2, r.cfg.IdleBackoff), r.cfg.MaxBackoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// deploy runs one claimed deployment, heartbeating while it runs, and reports the result.
+func (r *Runner) deploy(ctx context.Context, n int, c *Claim) {
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	p := newProgress()
+	var lost bool
+	beats := make(chan struct{})
+	go func() {
+		defer close(beats)
+		// Runs after stop so a switch waiting on a lost claim sees the deploy canceled
+		defer p.release()
+		if errors.Is(r.heartbeat(runCtx, c, p), ErrClaimLost) {
+			lost = true
+			stop()
+		}
+	}()
+
+	url, err := r.runtime.Deploy(runCtx, c.App, p)
+	stop()
+	<-beats
+	if lost {
+		if err == nil {
+			// Only a lapsed lease takes the claim once the switch is recorded; the next claim redeploys
+			log.Printf("worker %d: deployment %s: claim lost after the switch", n, c.DeploymentID)
+			return
+		}
+		// The canceled deploy removed the container it started before it returned
+		log.Printf("worker %d: deployment %s: claim lost, deploy stopped and its new container removed", n, c.DeploymentID)
+		return
+	}
+
+	var res Result
+	switch {
+	case err == nil:
+		res.URL = url
+	case ctx.Err() != nil:
+		// Shutdown cut the deploy short; another worker picks it up again
+		res.Interrupted = true
+	default:
+		res.Error = err.Error()
+	}
+	if err := r.report(ctx, c, p.take(), res); err != nil {
+		log.Printf("worker %d: deployment %s: report: %v", n, c.DeploymentID, err)
+	}
+}
+
+// heartbeat renews the claim until ctx is done, sending progress as it builds up.
+// It returns ErrClaimLost when the API refuses the claim and nil otherwise.
+func (r *Runner) heartbeat(ctx context.Context, c *Claim, p *progress) error {
+	interval := r.cfg.HeartbeatInterval
+	if interval <= 0 {
+		interval = max(c.Lease/3, minHeartbeatInterval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-p.notify:
+		}
+		batch := p.take()
+		err := r.client.Heartbeat(ctx, c.DeploymentID, r.workerID, batch)
+		switch {
+		case err == nil:
+			p.sent()
+		case errors.Is(err, ErrClaimLost):
+			return err
+		case err != nil:
+			// Keep the progress for the next heartbeat or the report
+			p.restore(batch)
+			if ctx.Err() == nil {
+				log.Printf("deployment %s: heartbeat: %v", c.DeploymentID, err)
+			}
+		}
+	}
+}
+
+// report sends a deploy's result, retrying a few times. It still runs once ctx is done.
+func (r *Runner) report(ctx context.Context, c *Claim, p Progress, res Result) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reportTimeout)
+	defer cancel()
+
+	var err error
+	for attempt := range reportAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(reportRetryWait):
+			}
+		}
+		err = r.client.Report(ctx, c.DeploymentID, r.workerID, p, res)
+		if err == nil || errors.Is(err, ErrClaimLost) {
+			return err
+		}
+	}
+	return err
+}
+
+// withDefaults fills unset fields.
+func (c Config) withDefaults() Config {
+	if c.Concurrency <= 0 {
+		c.Concurrency = defaultConcurrency
+	}
+	if c.IdleBackoff <= 0 {
+		c.IdleBackoff = defaultIdleBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+	if c.MaxBackoff < c.IdleBackoff {
+		c.MaxBackoff = c.IdleBackoff
+	}
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = defaultDrainTimeout
+	}
+	return c
+}
+
+// progress collects what the runtime reports until the next heartbeat takes it.
+type progress struct {
+	mu      sync.Mutex
+	pending Progress
+	// notify asks for an early heartbeat when a step changes or the log builds up
+	notify chan struct{}
+	// waiting are closed once the steps queued with them reach the API; sending belong to the batch in flight
+	waiting, sending []chan struct{}
+	// released is set once no more heartbeats go out
+	released bool
+}
+
+// newProgress returns an empty progress buffer.
+func newProgress() *progress {
+	return &progress{notify: make(chan struct{}, 1)}
+}
+
+// StepStarted queues the step start and asks for a heartbeat.
+// The switch to the new container also waits until a heartbeat has delivered it or the deploy is over.
+func (p *progress) StepStarted(step domain.StepID) {
+	if step != domain.StepReplaceContainer {
+		p.step(StepEvent{Step: step})
+		return
+	}
+	delivered := make(chan struct{})
+	p.mu.Lock()
+	p.pending.Steps = append(p.pending.Steps, StepEvent{Step: step})
+	if p.released {
+		close(delivered)
+	} else {
+		p.waiting = append(p.waiting, delivered)
+	}
+	p.mu.Unlock()
+	p.signal()
+	<-delivered
+}
+
+// StepFinished queues the step's end and asks for a heartbeat.
+func (p *progress) StepFinished(step domain.StepID, err error) {
+	e := StepEvent{Step: step, Finished: true}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	p.step(e)
+}
+
+// Log queues a build log line, asking for a heartbeat once a batch is ready.
+func (p *progress) Log(line domain.LogEntry) {
+	p.mu.Lock()
+	p.pending.Logs = append(p.pending.Logs, line)
+	full := len(p.pending.Logs) >= logBatchLines
+	p.mu.Unlock()
+	if full {
+		p.signal()
+	}
+}
+
+// PortResolved queues the port; it goes out with the step that resolved it.
+func (p *progress) PortResolved(port int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending.Port = &port
+}
+
+// step queues a step event and asks for a heartbeat.
+func (p *progress) step(e StepEvent) {
+	p.mu.Lock()
+	p.pending.Steps = append(p.pending.Steps, e)
+	p.mu.Unlock()
+	p.signal()
+}
+
+// signal asks for a heartbeat without waiting for one.
+func (p *progress) signal() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the queued progress and empties the buffer.
+func (p *progress) take() Progress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := p.pending
+	p.pending = Progress{}
+	p.sending = append(p.sending, p.waiting...)
+	p.waiting = nil
+	return out
+}
+
+// restore puts progress that could not be sent back in front of anything queued since.
+func (p *progress) restore(b Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending.Steps = append(b.Steps, p.pending.Steps...)
+	p.pending.Logs = append(b.Logs, p.pending.Logs...)
+	if p.pending.Port == nil {
+		p.pending.Port = b.Port
+	}
+	p.waiting = append(p.sending, p.waiting...)
+	p.sending = nil
+}
+
+// sent wakes the steps waiting on the batch the API just took.
+func (p *progress) sent() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ch := range p.sending {
+		close(ch)
+	}
+	p.sending = nil
+}
+
+// release wakes every waiting step once heartbeats have stopped.
+func (p *progress) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ch := range append(p.sending, p.waiting...) {
+		close(ch)
+	}
+	p.sending, p.waiting = nil, nil
+	p.released = true
+}
@@ -0,0 +1,306 @@
+// Tests for the worker that runs deploys claimed from the API
+// Tests verify a deploy's steps, build log and URL reach the API through heartbeats and the report
+// Tests verify a failed deploy is reported with its error
+// Tests verify canceling the deployment through the API stops the deploy, unless it began switching traffic
+// Tests verify stopping the runner hands an unfinished deploy back to the queue
+
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t0gun/spacescale/internal/adapters/store"
+	"github.com/t0gun/spacescale/internal/contracts"
+	"github.com/t0gun/spacescale/internal/domain"
+	"github.com/t0gun/spacescale/internal/http_api"
+	"github.com/t0gun/spacescale/internal/service"
+	"github.com/t0gun/spacescale/internal/worker"
+)
+
+// scriptedRuntime runs deploy for every Deploy call; it supports nothing else.
+type scriptedRuntime struct {
+	contracts.Runtime
+	deploy func(ctx context.Context, app domain.App, progress contracts.DeployProgress) (*string, error)
+}
+
+// Deploy runs the scripted deploy.
+func (r scriptedRuntime) Deploy(ctx context.Context, app domain.App, progress contracts.DeployProgress) (*string, error) {
+	return r.deploy(ctx, app, progress)
+}
+
+// blockingRuntime returns a runtime whose deploys report started and wait for their context to end.
+func blockingRuntime(started chan<- string) scriptedRuntime {
+	return scriptedRuntime{deploy: func(ctx context.Context, app domain.App, _ contracts.DeployProgress) (*string, error) {
+		started <- app.ID
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+}
+
+// testAPI is an API with an in-memory store served over HTTP.
+type testAPI struct {
+	svc   *service.AppService
+	store contracts.Store
+	url   string
+}
+
+// newTestAPI starts an API that accepts the worker token "secret".
+func newTestAPI(t *testing.T) testAPI {
+	t.Helper()
+	st := store.NewMemoryStore()
+	svc := service.NewAppService(st, service.WithLease(time.Minute))
+	srv := httptest.NewServer(http_api.NewServer(svc, "secret").Router())
+	t.Cleanup(srv.Close)
+	return testAPI{svc: svc, store: st, url: srv.URL}
+}
+
+// queue creates an app and queues a deployment of it.
+func (a testAPI) queue(t *testing.T) domain.Deployment {
+	t.Helper()
+	ctx := context.Background()
+	app, err := a.svc.CreateApp(ctx, service.CreateAppParams{Name: "hello", Image: "nginx:latest", Env: map[string]string{"A": "1"}})
+	require.NoError(t, err)
+	dep, err := a.svc.DeployApp(ctx, service.DeployAppParams{AppID: app.ID})
+	require.NoError(t, err)
+	return dep
+}
+
+// waitStatus waits for a deployment to reach want and returns it.
+func (a testAPI) waitStatus(t *testing.T, id string, want domain.DeploymentStatus) domain.Deployment {
+	t.Helper()
+	var dep domain.Deployment
+	assert.Eventually(t, func() bool {
+		var err error
+		dep, err = a.store.GetDeploymentByID(context.Background(), id)
+		return err == nil && dep.Status == want
+	}, 2*time.Second, 5*time.Millisecond, "deployment %s never became %s", id, want)
+	return dep
+}
+
+// start runs r in the background and returns a channel closed when it stops.
+func start(ctx context.Context, r *worker.Runner) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+	return done
+}
+
+// TestRunner verifies a successful deploy ends RUNNING with its URL, steps and build log.
+func TestRunner(t *testing.T) {
+	api := newTestAPI(t)
+	dep := api.queue(t)
+
+	url := "https://hello.example.com"
+	var gotEnv map[string]string
+	rt := scriptedRuntime{deploy: func(_ context.Context, app domain.App, progress contracts.DeployProgress) (*string, error) {
+		gotEnv = app.Env
+		progress.StepStarted(domain.StepPullImage)
+		for i := range 60 {
+			progress.Log(domain.LogEntry{Timestamp: time.Now().UTC(), Level: domain.LogLevelInfo, Message: fmt.Sprintf("layer %d", i), Source: "pull"})
+		}
+		progress.StepFinished(domain.StepPullImage, nil)
+		progress.StepStarted(domain.StepResolvePort)
+		progress.PortResolved(80)
+		progress.StepFinished(domain.StepResolvePort, nil)
+		return &url, nil
+	}}
+	runner := worker.NewRunner(worker.NewClient(api.url, "secret"), rt, "worker-1", worker.Config{IdleBackoff: 5 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := start(ctx, runner)
+	got := api.waitStatus(t, dep.ID, domain.DeploymentStatusRunning)
+	cancel()
+	<-done
+
+	assert.Equal(t, map[string]string{"A": "1"}, gotEnv)
+	if assert.NotNil(t, got.URL) {
+		assert.Equal(t, url, *got.URL)
+	}
+	assert.Equal(t, domain.StepStatusCompleted, got.Steps[0].Status)
+	if assert.NotNil(t, got.RuntimePort) {
+		assert.Equal(t, 80, *got.RuntimePort)
+	}
+	logs, err := api.store.ListDeploymentLogs(context.Background(), dep.ID, contracts.Page{Limit: 100})
+	require.NoError(t, err)
+	if assert.Len(t, logs, 60) {
+		assert.Equal(t, "layer 0", logs[0].Message)
+		assert.Equal(t, "layer 59", logs[59].Message)
+	}
+}
+
+// TestRunner_Failure verifies a failed deploy is recorded with the runtime's error.
+func TestRunner_Failure(t *testing.T) {
+	api := newTestAPI(t)
+	dep := api.queue(t)
+
+	rt := scriptedRuntime{deploy: func(_ context.Context, _ domain.App, progress contracts.DeployProgress) (*string, error) {
+		err := errors.New("manifest unknown")
+		progress.StepStarted(domain.StepPullImage)
+		progress.StepFinished(domain.StepPullImage, err)
+		return nil, fmt.Errorf("pull image: %w", err)
+	}}
+	runner := worker.NewRunner(worker.NewClient(api.url, "secret"), rt, "worker-1", worker.Config{IdleBackoff: 5 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := start(ctx, runner)
+	got := api.waitStatus(t, dep.ID, domain.DeploymentStatusFailed)
+	cancel()
+	<-done
+
+	if assert.NotNil(t, got.Error) {
+		assert.Equal(t, "pull image: manifest unknown", *got.Error)
+	}
+	assert.Equal(t, domain.StepStatusFailed, got.Steps[0].Status)
+}
+
+// TestRunner_Cancel verifies canceling the deployment stops the deploy at the next heartbeat.
+func TestRunner_Cancel(t *testing.T) {
+	api := newTestAPI(t)
+	dep := api.queue(t)
+
+	started := make(chan string, 1)
+	runner := worker.NewRunner(worker.NewClient(api.url, "secret"), blockingRuntime(started), "worker-1", worker.Config{
+		IdleBackoff:       5 * time.Millisecond,
+		HeartbeatInterval: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := start(ctx, runner)
+	<-started
+	_, err := api.svc.CancelDeployment(context.Background(), service.CancelDeploymentParams{DeploymentID: dep.ID})
+	require.NoError(t, err)
+
+	// The deploy returning lets the runner stop right away, without waiting out the drain timeout
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("canceled deploy kept running")
+	}
+	api.waitStatus(t, dep.ID, domain.DeploymentStatusCanceled)
+}
+
+// TestRunner_CancelSwitch verifies a cancel before the switch stops the deploy and one during it is refused.
+func TestRunner_CancelSwitch(t *testing.T) {
+	tests := []struct {
+		label string
+		// during cancels once the switch has started instead of just before it
+		during bool
+		want   domain.DeploymentStatus
+	}{
+		{label: "before", want: domain.DeploymentStatusCanceled},
+		{label: "during", during: true, want: domain.DeploymentStatusRunning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			api := newTestAPI(t)
+			dep := api.queue(t)
+
+			reached, resume := make(chan struct{}), make(chan struct{})
+			switched := make(chan bool, 1)
+			rt := scriptedRuntime{deploy: func(ctx context.Context, _ domain.App, progress contracts.DeployProgress) (*string, error) {
+				if !tt.during {
+					reached <- struct{}{}
+					<-resume
+				}
+				progress.StepStarted(domain.StepReplaceContainer)
+				if err := ctx.Err(); err != nil {
+					switched <- false
+					return nil, err
+				}
+				if tt.during {
+					reached <- struct{}{}
+					<-resume
+				}
+				switched <- true
+				url := "https://hello.example.com"
+				return &url, nil
+			}}
+			runner := worker.NewRunner(worker.NewClient(api.url, "secret"), rt, "worker-1", worker.Config{
+				IdleBackoff:       5 * time.Millisecond,
+				HeartbeatInterval: time.Hour,
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := start(ctx, runner)
+			<-reached
+			_, err := api.svc.CancelDeployment(context.Background(), service.CancelDeploymentParams{DeploymentID: dep.ID})
+			if tt.during {
+				assert.ErrorIs(t, err, service.ErrConflict)
+			} else {
+				require.NoError(t, err)
+			}
+			close(resume)
+
+			assert.Equal(t, tt.during, <-switched)
+			api.waitStatus(t, dep.ID, tt.want)
+			cancel()
+			<-done
+		})
+	}
+}
+
+// TestRunner_Interrupt verifies a deploy still running after the drain timeout goes back in the queue.
+func TestRunner_Interrupt(t *testing.T) {
+	api := newTestAPI(t)
+	dep := api.queue(t)
+
+	started := make(chan string, 1)
+	runner := worker.NewRunner(worker.NewClient(api.url, "secret"), blockingRuntime(started), "worker-1", worker.Config{
+		IdleBackoff:  5 * time.Millisecond,
+		DrainTimeout: 20 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := start(ctx, runner)
+	<-started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runner did not stop after the drain timeout")
+	}
+
+	got := api.waitStatus(t, dep.ID, domain.DeploymentStatusQueued)
+	assert.Nil(t, got.ClaimedBy)
+	assert.Nil(t, got.Error)
+}
+
+// TestClient verifies claims need the worker token and an empty queue is no claim.
+func TestClient(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	claim, err := worker.NewClient(api.url, "secret").Claim(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.Nil(t, claim)
+
+	_, err = worker.NewClient(api.url, "wrong").Claim(ctx, "worker-1")
+	assert.Error(t, err)
+
+	dep := api.queue(t)
+	_, err = worker.NewClient(api.url, "").Claim(ctx, "worker-1")
+	assert.Error(t, err)
+	claim, err = worker.NewClient(api.url+"/", "secret").Claim(ctx, "worker-1")
+	require.NoError(t, err)
+	if assert.NotNil(t, claim) {
+		assert.Equal(t, dep.ID, claim.DeploymentID)
+		assert.Equal(t, "hello", claim.App.Name)
+		assert.Equal(t, time.Minute, claim.Lease)
+	}
+
+	err = worker.NewClient(api.url, "secret").Heartbeat(ctx, dep.ID, "worker-2", worker.Progress{})
+	assert.ErrorIs(t, err, worker.ErrClaimLost)
+}